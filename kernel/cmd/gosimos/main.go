@@ -0,0 +1,826 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosimos/ipc"
+	"gosimos/sched"
+)
+
+const (
+	ansiReset   = "\033[0m"
+	ansiBold    = "\033[1m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiCyan    = "\033[36m"
+	ansiMagenta = "\033[35m"
+)
+
+func main() {
+	var quantumMs int
+	var demo bool
+	var runSecs int
+
+	var procCount int
+	var minUnits int
+	var maxUnits int
+	var randomize bool
+	var seedVal int64
+
+	var scenario string
+	var deadlockEvery int
+	var recoverDeadlock bool
+	var bankers bool
+	var shell bool
+	var live bool
+	var checkpoint, restore string
+	var record, replay string
+	var serve string
+	var policy string
+	var stracePID int
+	var syscallCost bool
+	var dmesg string
+	var slogOn bool
+	var logLevel string
+	var swap sched.SwapConfig
+
+	flag.IntVar(&procCount, "procs", 16, "number of processes to spawn")
+	flag.IntVar(&minUnits, "min", 2, "min work units per process")
+	flag.IntVar(&maxUnits, "max", 12, "max work units per process")
+	flag.BoolVar(&randomize, "random", true, "randomize names/workloads")
+	flag.Int64Var(&seedVal, "seed", time.Now().UnixNano(), "rng seed (0 = deterministic)")
+
+	flag.StringVar(&scenario, "scenario", "", "canned workload instead of random procs: "+strings.Join(sched.Scenarios, ", "))
+	flag.IntVar(&deadlockEvery, "deadlock-every", 10, "run deadlock detection every N dispatches (0 = off)")
+	flag.BoolVar(&recoverDeadlock, "recover", false, "kill a victim process to break each detected deadlock")
+	flag.StringVar(&policy, "policy", "priority", "CPU scheduling policy: priority, edf, rm or cfs")
+	flag.BoolVar(&bankers, "bankers", false, "Banker's-algorithm avoidance for declared resource claims")
+	flag.IntVar(&stracePID, "strace", 0, "print this PID's system call trace after the summary")
+	flag.BoolVar(&syscallCost, "syscall-cost", true, "charge every system call its default cost in simulated time")
+	flag.StringVar(&dmesg, "dmesg", "", "save the kernel log to this file when the run ends")
+	flag.BoolVar(&slogOn, "slog", false, "also write kernel log entries to stderr as structured slog records")
+	flag.IntVar(&swap.Memory, "memory", 0, "physical memory in bytes; past it processes are swapped out to disk (0 = unlimited)")
+	flag.IntVar(&swap.Size, "swap", 0, "swap area size in bytes (0 = unlimited)")
+	flag.DurationVar(&swap.Cost, "swap-cost", 0, "disk time per byte swapped (0 = the disk's own)")
+	flag.StringVar(&logLevel, "loglevel", "info", "lowest kernel log level for -slog and -dmesg: debug, info, notice, warn or err")
+
+	flag.BoolVar(&shell, "shell", false, "interactive shell (pause/step/break) instead of running for -secs")
+	flag.BoolVar(&live, "tui", false, "live full-screen dashboard while the simulation runs")
+	flag.StringVar(&checkpoint, "checkpoint", "", "save a snapshot of the simulation to this file when the run ends")
+	flag.StringVar(&record, "record", "", "log every input and scheduling decision of the run to this file")
+	flag.StringVar(&replay, "replay", "", "replay a -record log and report where the run diverges from it")
+	flag.StringVar(&serve, "serve", "", "serve the HTTP/JSON API on this address while running, e.g. :8080")
+	flag.StringVar(&restore, "restore", "", "resume a snapshot saved with -checkpoint instead of spawning a workload")
+
+	flag.IntVar(&quantumMs, "quantum", 100, "CPU quantum in ms")
+	flag.BoolVar(&demo, "demo", true, "run test scenario")
+	flag.IntVar(&runSecs, "secs", 6, "Max. seconds")
+	flag.Parse()
+	pol, err := sched.ParsePolicy(policy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	level, err := sched.ParseLogLevel(logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var logger *slog.Logger
+	if slogOn {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level.Slog()}))
+	}
+
+	if demo {
+		runDemo(time.Duration(quantumMs)*time.Millisecond,
+			time.Duration(runSecs)*time.Second,
+			procCount, minUnits, maxUnits, randomize, seedVal,
+			scenario, deadlockEvery, recoverDeadlock, bankers, shell, live, checkpoint, restore, record, replay, serve, pol,
+			stracePID, syscallCost, dmesg, logger, level, swap)
+		return
+	}
+	fmt.Println("No mode selected. Use -demo or own process")
+}
+
+func runDemo(quantum time.Duration, maxRun time.Duration, procCount, minUnits, maxUnits int, randomize bool, seedVal int64,
+	scenario string, deadlockEvery int, recoverDeadlock, bankers, shell, live bool, checkpoint, restore, record, replay, serve string, policy sched.Policy,
+	stracePID int, syscallCost bool, dmesg string, logger *slog.Logger, logLevel sched.LogLevel, swap sched.SwapConfig) {
+	start := time.Now()
+
+	opts := []sched.Option{sched.WithQuantum(quantum), sched.WithSeed(uint64(seedVal)),
+		sched.WithDeadlockDetection(deadlockEvery, recoverDeadlock), sched.WithPolicy(policy), sched.WithLogger(logger),
+		sched.WithSwap(swap)}
+	if bankers {
+		opts = append(opts, sched.WithBankers())
+	}
+	if syscallCost {
+		const unit = 100 * time.Millisecond // NewScheduler's default
+		opts = append(opts, sched.WithSyscallCosts(sched.DefaultSyscallCosts(unit)))
+	}
+	s := sched.NewScheduler(opts...)
+	s.Logf(sched.LogInfo, "init", "OS starting: quantum %v, max run %v", quantum, maxRun)
+	if replay != "" {
+		rec, err := sched.LoadRecording(replay)
+		if err != nil {
+			log.Fatalf("replay: %v", err)
+		}
+		s, err = sched.Replay(rec)
+		if s == nil {
+			log.Fatalf("replay: %v", err)
+		}
+		s.SetLogger(logger)
+		if err != nil {
+			log.Printf("%sreplay: %v%s\n", ansiRed, err, ansiReset)
+		} else {
+			s.Logf(sched.LogNotice, "init", "replayed %d events from %s, no divergence", len(rec.Events), replay)
+		}
+		printSummary(s, start)
+		printStrace(s, stracePID)
+		saveDmesg(s, dmesg, logLevel)
+		return
+	}
+	if restore != "" {
+		var err error
+		if s, err = sched.LoadCheckpoint(restore); err != nil {
+			log.Fatalf("restore: %v", err)
+		}
+		s.SetLogger(logger)
+		s.Logf(sched.LogInfo, "init", "restored %s at dispatch %d", restore, s.Dispatches())
+		procCount = 0
+	}
+	if record != "" {
+		if err := s.StartRecording(record); err != nil {
+			log.Fatalf("record: %v", err)
+		}
+	}
+	if restore == "" {
+		if sched.SpawnScenario(s, scenario) {
+			procCount = 0
+		}
+	}
+
+	names := []string{"worker", "io", "net", "db", "logger", "ipc", "fs", "cache"}
+
+	for i := 0; i < procCount; i++ {
+		name := fmt.Sprintf("proc-%02d", i+1)
+		behavior := sched.BehaviorCompute
+		journal := false
+		prio := s.Intn(3)
+
+		if randomize {
+			r := s.Intn(100)
+			if r < 10 {
+				behavior = sched.BehaviorFSWriter
+			} else if r < 30 {
+				behavior = sched.BehaviorIPCSender
+			} else if r < 40 {
+				journal = true
+			}
+		} else {
+			if i%7 == 0 {
+				behavior = sched.BehaviorFSWriter
+			} else if i%5 == 0 {
+				behavior = sched.BehaviorIPCSender
+			} else if i%4 == 0 {
+				journal = true
+			}
+		}
+
+		wu := minUnits
+		if maxUnits > minUnits {
+			wu = minUnits + s.Intn(maxUnits-minUnits+1)
+		}
+		spec := &sched.ProcessSpec{
+			Name:      fmt.Sprintf("%s-%s", names[i%len(names)], name),
+			Priority:  prio,
+			WorkUnits: wu,
+			Behavior:  behavior,
+		}
+		if journal {
+			spec.Program = sched.JournalProgram(spec.Name, wu)
+		}
+		s.Spawn(spec)
+	}
+
+	clearScreenIfTTY()
+	printBanner()
+	fmt.Printf("%s🖥️  %sGoSimOS%s — lightweight kernel simulator\n", ansiBold, ansiCyan, ansiReset)
+	fmt.Printf("%sQuantum:%s %s | %sMaxRun:%s %s\n\n", ansiBold, ansiReset, quantum, ansiBold, ansiReset, maxRun)
+
+	s.Start()
+	if serve != "" {
+		stop := make(chan struct{})
+		defer close(stop)
+		go serveAPI(s, serve, stop)
+	}
+	if shell {
+		runShell(s, os.Stdin)
+	} else if live {
+		runTUI(s, os.Stdin, os.Stdout, maxRun)
+	} else {
+		time.Sleep(maxRun)
+	}
+	s.Stop()
+	if err := s.StopRecording(); err != nil {
+		log.Printf("record: %v", err)
+	}
+	if checkpoint != "" {
+		if err := s.SaveCheckpoint(checkpoint); err != nil {
+			log.Printf("checkpoint: %v", err)
+		} else {
+			log.Printf("Checkpoint saved to %s\n", checkpoint)
+		}
+	}
+
+	printSummary(s, start)
+	printStrace(s, stracePID)
+	saveDmesg(s, dmesg, logLevel)
+}
+
+func saveDmesg(s *sched.Scheduler, path string, level sched.LogLevel) {
+	if path == "" {
+		return
+	}
+	if err := s.SaveLog(path, sched.LogFilter{Level: level}); err != nil {
+		log.Printf("dmesg: %v", err)
+	}
+}
+
+// printSummary prints the end-of-run report and saves a plain copy.
+func printSummary(s *sched.Scheduler, start time.Time) {
+	elapsed := time.Since(start)
+
+	fmt.Println()
+	printDivider()
+	fmt.Printf("%sProcess Summary%s\n", ansiBold, ansiReset)
+	printDivider()
+	printProcessTable(s.Stats())
+	fmt.Println()
+
+	printDivider()
+	fmt.Printf("%sMailboxes%s\n", ansiBold, ansiReset)
+	printDivider()
+	printMailboxes(s.DumpMailboxes())
+	printMailStats(s.MailboxStats())
+	fmt.Println()
+
+	printDivider()
+	fmt.Printf("%sSynchronization%s\n", ansiBold, ansiReset)
+	printDivider()
+	printSync(s.SyncStats())
+	fmt.Println()
+
+	pipes, segs := s.PipeStats(), s.SegmentStats()
+	if len(pipes)+len(segs) > 0 {
+		printDivider()
+		fmt.Printf("%sPipes & Shared Memory%s\n", ansiBold, ansiReset)
+		printDivider()
+		printIPC(pipes, segs, time.Since(start))
+		fmt.Println()
+	}
+
+	if dl := s.Deadlocks(); len(dl) > 0 {
+		printDivider()
+		fmt.Printf("%sDeadlocks%s\n", ansiBold, ansiReset)
+		printDivider()
+		printDeadlocks(dl)
+		fmt.Println()
+	}
+
+	if rt := s.RTStats(); len(rt) > 0 {
+		printDivider()
+		fmt.Printf("%sReal-Time Tasks (%s)%s\n", ansiBold, s.Policy(), ansiReset)
+		printDivider()
+		printRT(rt)
+		fmt.Println()
+	}
+
+	if irqs := s.IRQStats(); slices.ContainsFunc(irqs, func(st sched.IRQStat) bool { return st.Count > 0 }) {
+		printDivider()
+		fmt.Printf("%sInterrupts%s\n", ansiBold, ansiReset)
+		printDivider()
+		printIRQs(irqs)
+		fmt.Println()
+	}
+
+	if sc := s.SyscallStats(); len(sc) > 0 {
+		printDivider()
+		fmt.Printf("%sSystem Calls%s\n", ansiBold, ansiReset)
+		printDivider()
+		printSyscalls(sc)
+		fmt.Println()
+	}
+
+	if kl := s.Dmesg(sched.LogFilter{Level: sched.LogNotice}); len(kl) > 0 {
+		printDivider()
+		fmt.Printf("%sKernel Log%s\n", ansiBold, ansiReset)
+		printDivider()
+		printDmesg(kl, 20)
+		fmt.Println()
+	}
+
+	if tp := threadedProcs(s); len(tp) > 0 {
+		printDivider()
+		fmt.Printf("%sThreads%s\n", ansiBold, ansiReset)
+		printDivider()
+		for _, pid := range tp {
+			printThreads(s, pid)
+		}
+		fmt.Println()
+	}
+
+	if sw := s.SwapStats(); sw.Memory > 0 {
+		printDivider()
+		fmt.Printf("%sMemory and Swap%s\n", ansiBold, ansiReset)
+		printDivider()
+		printSwap(sw, s.Stats())
+		fmt.Println()
+	}
+
+	if cg := s.CgroupStats(); len(cg) > 0 {
+		printDivider()
+		fmt.Printf("%sControl Groups%s\n", ansiBold, ansiReset)
+		printDivider()
+		printCgroups(cg)
+		fmt.Println()
+	}
+
+	printDivider()
+	fmt.Printf("%sVirtual FS%s\n", ansiBold, ansiReset)
+	printDivider()
+	printFS(s.DumpFS())
+	fmt.Println()
+
+	printDivider()
+	fmt.Printf("%s✅ Simulation finished%s  (elapsed: %s, processes: %d)\n",
+		ansiGreen, ansiReset, elapsed.Round(time.Millisecond), len(s.Stats()))
+	fmt.Println()
+	_ = os.WriteFile("gosimos_summary.txt", []byte(plainSummary(s, elapsed)), 0644)
+	fmt.Printf("%sSaved text summary to gosimos_summary.txt%s\n", ansiYellow, ansiReset)
+}
+
+func clearScreenIfTTY() {
+	fmt.Print("\033[2J\033[H")
+}
+
+func printBanner() {
+	b := `
+  ____        ____  _ 
+ / ___| ___  / ___|(_)__    __
+| |  _ / _ \ \___ \| ||\\  //||
+| |_| | |_| | ___) | || \\// ||
+ \____|\___/ |____/|_||	     ||
+`
+	fmt.Printf("%s%s%s\n", ansiMagenta, b, ansiReset)
+}
+
+func printDivider() {
+	fmt.Println(strings.Repeat("─", 60))
+}
+
+func printProcessTable(stats []sched.ProcessStat) {
+	fmt.Printf("%s%3s  %-16s  %-7s  %-8s  %s%s\n", ansiBold, "PID", "Name", "Priority", "CPU", "Status", ansiReset)
+	for _, st := range stats {
+		status := fmt.Sprintf("%sCompleted%s", ansiGreen, ansiReset)
+		if st.ExitReason != "" {
+			status = fmt.Sprintf("%sKilled (%s)%s", ansiRed, st.ExitReason, ansiReset)
+		} else if st.State == sched.StateBlocked {
+			status = fmt.Sprintf("%sBlocked on %s%s", ansiRed, st.WaitingOn, ansiReset)
+		} else if st.Remaining > 0 {
+			status = fmt.Sprintf("%sRunning (%d left)%s", ansiYellow, st.Remaining, ansiReset)
+		}
+		priColor := ansiCyan
+		if st.Priority == 0 {
+			priColor = ansiRed
+		}
+		fmt.Printf(" %3d  %-16s  %s%-7d%s  %6s  %s\n",
+			st.ID,
+			truncate(st.Name, 16),
+			priColor, st.Priority, ansiReset,
+			st.TotalCPU.Round(time.Millisecond),
+			status)
+	}
+}
+
+func printMailboxes(m map[int][]ipc.Message) {
+	if len(m) == 0 {
+		fmt.Println(" (none)")
+		return
+	}
+	for pid, msgs := range m {
+		if len(msgs) == 0 {
+			fmt.Printf(" PID %2d  ←  %s—%s\n", pid, ansiYellow, ansiReset)
+			continue
+		}
+		preview := msgsPreview(msgs)
+		fmt.Printf(" PID %2d  ←  %s%d message(s)%s  preview: %s\n",
+			pid, ansiGreen, len(msgs), ansiReset, preview)
+	}
+}
+
+func printMailStats(stats []ipc.MailboxStat) {
+	for _, st := range stats {
+		if len(st.ByType) == 0 {
+			continue
+		}
+		types := make([]string, 0, len(st.ByType))
+		for typ := range st.ByType {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		parts := make([]string, 0, len(types))
+		for _, typ := range types {
+			ts := st.ByType[typ]
+			parts = append(parts, fmt.Sprintf("%s %d/%d avg %v max %v",
+				typ, ts.Received, ts.Delivered, ts.AvgDelay(), ts.MaxDelay))
+		}
+		fmt.Printf(" PID %2d  %srecv/delivered%s  %s\n", st.PID, ansiCyan, ansiReset, strings.Join(parts, " | "))
+	}
+}
+
+func msgsPreview(msgs []ipc.Message) string {
+	n := len(msgs)
+	if n == 0 {
+		return "—"
+	}
+	limit := 3
+	if n < limit {
+		limit = n
+	}
+	parts := make([]string, 0, limit)
+	for i := 0; i < limit; i++ {
+		parts = append(parts, fmt.Sprintf("[%d→%d %s:%s]", msgs[i].From, msgs[i].To, msgs[i].Type, truncate(string(msgs[i].Payload), 20)))
+	}
+	if n > limit {
+		parts = append(parts, fmt.Sprintf("…(+%d)", n-limit))
+	}
+	return strings.Join(parts, " ")
+}
+
+func printSync(stats []sched.SyncStat) {
+	if len(stats) == 0 {
+		fmt.Println(" (none)")
+		return
+	}
+	fmt.Printf("%s %-6s  %-16s  %-9s  %-12s  %8s  %9s%s\n", ansiBold, "Kind", "Name", "Holders", "Waiters", "Acquires", "Contended", ansiReset)
+	for _, st := range stats {
+		holders := pidList(st.Holders)
+		if st.Kind == "sem" {
+			holders = fmt.Sprintf("value=%d", st.Value)
+		}
+		waitColor := ansiGreen
+		if len(st.Waiters) > 0 {
+			waitColor = ansiRed
+		}
+		fmt.Printf(" %-6s  %-16s  %-9s  %s%-12s%s  %8d  %9d\n",
+			st.Kind, truncate(st.Name, 16), holders,
+			waitColor, truncate(pidList(st.Waiters), 12), ansiReset,
+			st.Acquires, st.Contended)
+	}
+}
+
+func printIPC(pipes []ipc.PipeStat, segs []ipc.SegmentStat, elapsed time.Duration) {
+	secs := elapsed.Seconds()
+	for _, p := range pipes {
+		fmt.Printf(" %s%-14s%s  buf %3d/%-3d  r/w %d/%d  in %5dB  out %5dB  stalls r%d w%d  %s%.0f B/s%s\n",
+			ansiBold, truncate(p.Name, 14), ansiReset, p.Buffered, p.Cap, p.Readers, p.Writers,
+			p.BytesIn, p.BytesOut, p.ReadStall, p.WriteStall, ansiGreen, float64(p.BytesOut)/secs, ansiReset)
+	}
+	for _, sg := range segs {
+		fmt.Printf(" %sshm:%-10s%s  size %-5d  attached %-8s  in %5dB  out %5dB  %s%.0f B/s%s\n",
+			ansiBold, truncate(sg.Name, 10), ansiReset, sg.Size, pidList(sg.Attached),
+			sg.BytesIn, sg.BytesOut, ansiGreen, float64(sg.BytesOut)/secs, ansiReset)
+	}
+}
+
+func printDeadlocks(reports []sched.DeadlockReport) {
+	for _, r := range reports {
+		victim := "none (detection only)"
+		if r.Victim != 0 {
+			victim = fmt.Sprintf("killed PID %d", r.Victim)
+		}
+		fmt.Printf(" %s⚠ cycle%s  PIDs %s  at dispatch %d  →  %s\n",
+			ansiRed, ansiReset, pidList(r.PIDs), r.Dispatch, victim)
+	}
+}
+
+func pidList(ids []int) string {
+	if len(ids) == 0 {
+		return "—"
+	}
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprint(id))
+	}
+	return strings.Join(parts, ",")
+}
+
+func printRT(stats []sched.RTStat) {
+	fmt.Printf("%-4s %-12s %8s %8s %8s %5s %6s %8s %8s %8s\n",
+		"PID", "NAME", "PERIOD", "WCET", "DEADLINE", "JOBS", "MISSES", "AVG-RESP", "MAX-RESP", "JITTER")
+	for _, st := range stats {
+		misses := fmt.Sprintf("%6d", st.Misses)
+		if st.Misses > 0 {
+			misses = ansiRed + misses + ansiReset
+		}
+		fmt.Printf("%-4d %-12s %8v %8v %8v %5d %s %8v %8v %8v\n",
+			st.PID, truncate(st.Name, 12), st.Period, st.WCET, st.Deadline, st.Jobs, misses,
+			st.AvgResponse.Round(time.Millisecond), st.MaxResponse, st.Jitter)
+	}
+}
+
+func printIRQs(stats []sched.IRQStat) {
+	fmt.Printf("%-8s %8s %12s %12s\n", "DEVICE", "IRQS", "HANDLER-CPU", "MAX-DELAY")
+	for _, st := range stats {
+		fmt.Printf("%-8s %8d %12v %12v\n", st.Device, st.Count, st.Time, st.MaxDelay)
+	}
+}
+
+func printSyscalls(stats []sched.SyscallStat) {
+	fmt.Printf("%3s  %-14s %8s %7s %10s\n", "NR", "CALL", "CALLS", "ERRORS", "SYS-TIME")
+	for _, st := range stats {
+		errs := fmt.Sprintf("%7d", st.Errors)
+		if st.Errors > 0 {
+			errs = ansiRed + errs + ansiReset
+		}
+		fmt.Printf("%3d  %-14s %8d %s %10v\n", st.Nr, st.Name, st.Calls, errs, st.Time)
+	}
+}
+
+// printStrace prints pid's system calls one per line, strace style with
+// the simulated time in front. pid 0 prints nothing.
+func printStrace(s *sched.Scheduler, pid int) {
+	if pid == 0 {
+		return
+	}
+	recs, err := s.Strace(pid)
+	if err != nil {
+		fmt.Printf(" strace: %v: %d\n", err, pid)
+		return
+	}
+	if len(recs) == 0 {
+		fmt.Println(" (no system calls)")
+	}
+	for _, r := range recs {
+		fmt.Printf(" %12v  %s\n", r.Time, r)
+	}
+}
+
+// threadedProcs lists the processes that started threads of either model.
+func threadedProcs(s *sched.Scheduler) []int {
+	var out []int
+	for _, st := range s.Stats() {
+		if ts, _ := s.Threads(st.ID); st.TGID == st.ID && len(ts) > 1 {
+			out = append(out, st.ID)
+		}
+	}
+	return out
+}
+
+func printThreads(s *sched.Scheduler, pid int) {
+	ts, err := s.Threads(pid)
+	if err != nil {
+		fmt.Printf(" threads: %v: %d\n", err, pid)
+		return
+	}
+	lead := ts[0].TID // a kernel thread's TID is its PID
+	if ts[0].Model == sched.UserThreads {
+		lead = pid
+	}
+	fmt.Printf(" %sPID %d, %s threads%s\n", ansiBold, lead, ts[0].Model, ansiReset)
+	for _, t := range ts {
+		fmt.Printf(" %5d %-16s %-8s %10v %5d runs\n", t.TID, truncate(t.Name, 16), t.State, t.CPU, t.Runs)
+	}
+}
+
+// printDmesg prints the last n entries, colouring warnings and errors.
+func printDmesg(entries []sched.LogEntry, n int) {
+	if len(entries) > n {
+		fmt.Printf(" (%d earlier entries)\n", len(entries)-n)
+		entries = entries[len(entries)-n:]
+	}
+	for _, e := range entries {
+		color := ""
+		switch {
+		case e.Level >= sched.LogErr:
+			color = ansiRed
+		case e.Level == sched.LogWarn:
+			color = ansiYellow
+		}
+		if color == "" {
+			fmt.Printf(" %s\n", e)
+		} else {
+			fmt.Printf(" %s%s%s\n", color, e, ansiReset)
+		}
+	}
+}
+
+// logFilter builds a kernel log filter from dmesg-style arguments:
+// -l level, -s subsystem and -p pid. It returns what is left.
+func logFilter(args []string) (sched.LogFilter, []string, error) {
+	var f sched.LogFilter
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		if flag != "-l" && flag != "-s" && flag != "-p" {
+			rest = append(rest, flag)
+			continue
+		}
+		if i++; i == len(args) {
+			return f, nil, fmt.Errorf("%s needs a value", flag)
+		}
+		var err error
+		switch flag {
+		case "-l":
+			f.Level, err = sched.ParseLogLevel(args[i])
+		case "-s":
+			f.Subsys = args[i]
+		case "-p":
+			f.PID, err = strconv.Atoi(args[i])
+		}
+		if err != nil {
+			return f, nil, err
+		}
+	}
+	return f, rest, nil
+}
+
+func printSwap(st sched.SwapStat, procs []sched.ProcessStat) {
+	size := "unlimited"
+	if st.Size > 0 {
+		size = fmt.Sprintf("%d B", st.Size)
+	}
+	fmt.Printf(" memory %d B, %d resident; swap %s, %d used\n", st.Memory, st.Resident, size, st.Used)
+	fmt.Printf(" %d swap-ins, %d swap-outs, %v of disk time, load %.2f\n", st.Ins, st.Outs, st.Time, st.Load)
+	if st.Episodes > 0 {
+		fmt.Printf(" %sthrashing %d time(s), first at %v: load average %.2f, %d processes%s\n",
+			ansiRed, st.Episodes, st.ThrashAt, st.ThrashLoad, st.ThrashProcs, ansiReset)
+	}
+	for _, p := range procs {
+		if p.SwapOuts > 0 {
+			fmt.Printf(" %5d %-16s %3d in %3d out %10v swapped out\n", p.ID, truncate(p.Name, 16), p.SwapIns, p.SwapOuts, p.SwapTime)
+		}
+	}
+}
+
+func printCgroups(stats []sched.CgroupStat) {
+	fmt.Printf("%-12s %5s %8s %10s %9s %10s %9s %9s %4s %8s\n",
+		"GROUP", "PROCS", "CPU", "QUOTA", "THROTTLED", "THR-TIME", "MEM-PEAK", "MEM-MAX", "OOM", "REJECTED")
+	for _, st := range stats {
+		quota, memMax := "-", "-"
+		if st.Quota > 0 {
+			quota = fmt.Sprintf("%v/%v", st.Quota, st.Period)
+		}
+		if st.MemLimit > 0 {
+			memMax = fmt.Sprint(st.MemLimit)
+		}
+		oom := fmt.Sprintf("%4d", st.OOMKills)
+		if st.OOMKills > 0 {
+			oom = ansiRed + oom + ansiReset
+		}
+		fmt.Printf("%-12s %5d %8v %10s %9d %10v %9d %9s %s %8d\n",
+			truncate(st.Name, 12), st.Procs, st.CPU, quota, st.Throttled, st.ThrottledTime,
+			st.MemPeak, memMax, oom, st.Rejected)
+	}
+}
+
+func printFS(fs map[string]string) {
+	if len(fs) == 0 {
+		fmt.Println(" (empty)")
+		return
+	}
+	for name, content := range fs {
+		fmt.Printf(" %s%s%s  →  %q\n", ansiBold, name, ansiReset, truncate(content, 60))
+	}
+}
+
+func printLs(s *sched.Scheduler) {
+	files, info := s.DumpFS(), s.FileInfo()
+	if len(info) == 0 {
+		fmt.Println(" (empty)")
+		return
+	}
+	for _, name := range slices.Sorted(maps.Keys(info)) {
+		fi := info[name]
+		size := "fifo"
+		if content, ok := files[name]; ok {
+			size = fmt.Sprint(len(content))
+		}
+		fmt.Printf(" %s  %-8s %4d  %5s  %s\n", fi.Mode, s.UserName(fi.UID), fi.GID, size, name)
+	}
+}
+
+func printUsers(users []sched.User) {
+	fmt.Printf("%s %-12s %5s %5s  %s%s\n", ansiBold, "User", "UID", "GID", "Groups", ansiReset)
+	for _, u := range users {
+		fmt.Printf(" %-12s %5d %5d  %v\n", u.Name, u.UID, u.GID, u.Groups)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func plainSummary(s *sched.Scheduler, elapsed time.Duration) string {
+	sb := &strings.Builder{}
+	sb.WriteString("GoSimOS — simulation summary\n")
+	sb.WriteString(strings.Repeat("-", 40) + "\n")
+	sb.WriteString(fmt.Sprintf("Quantum: %v  Elapsed: %v\n\n", s.Quantum(), elapsed))
+	sb.WriteString("Processes:\n")
+	for _, st := range s.Stats() {
+		sb.WriteString(fmt.Sprintf(" PID=%d name=%s prio=%d cpu=%v remaining=%d state=%s",
+			st.ID, st.Name, st.Priority, st.TotalCPU.Round(time.Millisecond), st.Remaining, st.State))
+		if st.Parent != 0 {
+			sb.WriteString(fmt.Sprintf(" ppid=%d", st.Parent))
+		}
+		if st.TGID != st.ID {
+			sb.WriteString(fmt.Sprintf(" tgid=%d", st.TGID))
+		}
+		if st.UID != 0 {
+			sb.WriteString(fmt.Sprintf(" user=%s", st.User))
+		}
+		if st.ExitReason != "" {
+			sb.WriteString(fmt.Sprintf(" reason=%q", st.ExitReason))
+		}
+		if st.SwapOuts > 0 {
+			sb.WriteString(fmt.Sprintf(" swaps=%d/%d swapped=%v", st.SwapIns, st.SwapOuts, st.SwapTime))
+		}
+		if len(st.Syscalls) > 0 {
+			n := 0
+			for _, c := range st.Syscalls {
+				n += c
+			}
+			sb.WriteString(fmt.Sprintf(" syscalls=%d sys=%v", n, st.SysTime))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nMailboxes:\n")
+	for _, st := range s.MailboxStats() {
+		sb.WriteString(fmt.Sprintf(" PID=%d messages=%d", st.PID, st.Pending))
+		types := make([]string, 0, len(st.ByType))
+		for typ := range st.ByType {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		for _, typ := range types {
+			ts := st.ByType[typ]
+			sb.WriteString(fmt.Sprintf(" %s=%d/%d avg=%v max=%v", typ, ts.Received, ts.Delivered, ts.AvgDelay(), ts.MaxDelay))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nSynchronization:\n")
+	for _, st := range s.SyncStats() {
+		sb.WriteString(fmt.Sprintf(" %s %s value=%d holders=%v waiters=%v acquires=%d contended=%d\n",
+			st.Kind, st.Name, st.Value, st.Holders, st.Waiters, st.Acquires, st.Contended))
+	}
+	sb.WriteString("\nPipes:\n")
+	for _, p := range s.PipeStats() {
+		sb.WriteString(fmt.Sprintf(" %s cap=%d buffered=%d readers=%d writers=%d in=%d out=%d read_stalls=%d write_stalls=%d\n",
+			p.Name, p.Cap, p.Buffered, p.Readers, p.Writers, p.BytesIn, p.BytesOut, p.ReadStall, p.WriteStall))
+	}
+	sb.WriteString("\nShared memory:\n")
+	for _, sg := range s.SegmentStats() {
+		sb.WriteString(fmt.Sprintf(" %s size=%d attached=%v in=%d out=%d\n", sg.Name, sg.Size, sg.Attached, sg.BytesIn, sg.BytesOut))
+	}
+	sb.WriteString("\nSystem calls:\n")
+	for _, st := range s.SyscallStats() {
+		sb.WriteString(fmt.Sprintf(" %s nr=%d calls=%d errors=%d time=%v\n", st.Name, st.Nr, st.Calls, st.Errors, st.Time))
+	}
+	if sw := s.SwapStats(); sw.Memory > 0 {
+		sb.WriteString(fmt.Sprintf("\nSwap:\n memory=%d resident=%d size=%d used=%d ins=%d outs=%d time=%v thrashing=%d",
+			sw.Memory, sw.Resident, sw.Size, sw.Used, sw.Ins, sw.Outs, sw.Time, sw.Episodes))
+		if sw.Episodes > 0 {
+			sb.WriteString(fmt.Sprintf(" onset=%v load=%.2f procs=%d", sw.ThrashAt, sw.ThrashLoad, sw.ThrashProcs))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nDeadlocks:\n")
+	for _, r := range s.Deadlocks() {
+		sb.WriteString(fmt.Sprintf(" dispatch=%d pids=%v victim=%d\n", r.Dispatch, r.PIDs, r.Victim))
+	}
+	sb.WriteString("\nKernel log:\n")
+	for _, e := range s.Dmesg(sched.LogFilter{Level: sched.LogNotice}) {
+		sb.WriteString(" " + e.String() + "\n")
+	}
+	sb.WriteString("\nFiles:\n")
+	for k, v := range s.DumpFS() {
+		sb.WriteString(fmt.Sprintf(" %s -> %q\n", k, v))
+	}
+	return sb.String()
+}
+
+//Moderate: go run . -demo -procs 32 -min 3 -max 12 -secs 10
+//Heavy: go run . -demo -procs 128 -min 5 -max 30 -secs 40
+//Deterministic: go run . -demo -procs 64 -min 3 -max 10 -seed 12345 -random=false -secs 12
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosimos/sched"
+)
+
+// metrics serves the scheduler's counters in the Prometheus text format.
+func (a *api) metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, a.s)
+}
+
+// writeMetrics renders every metric in a fixed order. Times are simulated
+// seconds, so two runs of the same seeded workload export the same text.
+func writeMetrics(w io.Writer, s *sched.Scheduler) {
+	m := &promWriter{w: w}
+
+	m.family("gosimos_sim_time_seconds", "gauge", "Simulated clock.")
+	m.sample("gosimos_sim_time_seconds", "", seconds(s.Now()))
+	m.family("gosimos_paused", "gauge", "1 while the scheduler loop is paused.")
+	m.sample("gosimos_paused", "", boolValue(s.Paused()))
+	m.family("gosimos_dispatches_total", "counter", "Processes dispatched onto the CPU.")
+	m.sample("gosimos_dispatches_total", "", float64(s.Dispatches()))
+	m.family("gosimos_context_switches_total", "counter", "Dispatches that ran a different process from the previous one.")
+	m.sample("gosimos_context_switches_total", "", float64(s.ContextSwitches()))
+
+	stats := s.Stats()
+	m.family("gosimos_processes", "gauge", "Processes by state.")
+	for _, st := range []sched.ProcState{sched.StateReady, sched.StateRunning, sched.StateBlocked, sched.StateExited} {
+		n := 0
+		for _, p := range stats {
+			if p.State == st {
+				n++
+			}
+		}
+		m.sample("gosimos_processes", label("state", st.String()), float64(n))
+	}
+
+	queued := make(map[int]int) // ready processes per priority level
+	prioOf := make(map[int]int, len(stats))
+	for _, p := range stats {
+		prioOf[p.ID] = p.Priority
+		if _, ok := queued[p.Priority]; !ok {
+			queued[p.Priority] = 0
+		}
+	}
+	for _, pid := range s.ReadyQueue() {
+		queued[prioOf[pid]]++
+	}
+	m.family("gosimos_ready_queue_length", "gauge", "Ready processes per scheduling policy and priority level.")
+	for _, lvl := range slices.Sorted(maps.Keys(queued)) {
+		m.sample("gosimos_ready_queue_length", label("policy", s.Policy().String())+","+label("priority", strconv.Itoa(lvl)), float64(queued[lvl]))
+	}
+
+	m.histogram("gosimos_ready_wait_seconds", "Time spent in the ready queue before each dispatch.", s.ReadyWait())
+	m.histogram("gosimos_block_wait_seconds", "Time from blocking to the wake-up.", s.BlockWait())
+
+	m.family("gosimos_mailbox_depth", "gauge", "Messages waiting in each process's mailbox.")
+	delivered := make(map[string]int)
+	for _, mb := range s.MailboxStats() {
+		m.sample("gosimos_mailbox_depth", label("pid", strconv.Itoa(mb.PID)), float64(mb.Pending))
+		for typ, ts := range mb.ByType {
+			delivered[typ] += ts.Delivered
+		}
+	}
+	m.family("gosimos_messages_delivered_total", "counter", "Messages delivered, by type.")
+	for _, typ := range slices.Sorted(maps.Keys(delivered)) {
+		m.sample("gosimos_messages_delivered_total", label("type", typ), float64(delivered[typ]))
+	}
+
+	ops := s.FSOps()
+	m.family("gosimos_fs_ops_total", "counter", "Virtual file system calls, by operation.")
+	for _, op := range []string{"read", "write", "mkfifo"} {
+		m.sample("gosimos_fs_ops_total", label("op", op), float64(ops[op]))
+	}
+
+	irqs := s.IRQStats()
+	m.family("gosimos_interrupts_total", "counter", "Device interrupts handled, by device.")
+	for _, st := range irqs {
+		m.sample("gosimos_interrupts_total", label("device", st.Device), float64(st.Count))
+	}
+	m.family("gosimos_interrupt_seconds_total", "counter", "Simulated CPU time spent in interrupt handlers, by device.")
+	for _, st := range irqs {
+		m.sample("gosimos_interrupt_seconds_total", label("device", st.Device), seconds(st.Time))
+	}
+
+	sw := s.SwapStats()
+	m.family("gosimos_memory_resident_bytes", "gauge", "Memory of the processes not swapped out.")
+	m.sample("gosimos_memory_resident_bytes", "", float64(sw.Resident))
+	m.family("gosimos_swap_used_bytes", "gauge", "Memory in the swap area.")
+	m.sample("gosimos_swap_used_bytes", "", float64(sw.Used))
+	m.family("gosimos_swaps_total", "counter", "Processes swapped in and out.")
+	m.sample("gosimos_swaps_total", label("direction", "in"), float64(sw.Ins))
+	m.sample("gosimos_swaps_total", label("direction", "out"), float64(sw.Outs))
+	m.family("gosimos_page_faults_total", "counter", "Page faults, by type. A major fault is a process swapped back in from disk.")
+	m.sample("gosimos_page_faults_total", label("type", "major"), float64(sw.Ins))
+	m.family("gosimos_thrashing", "gauge", "1 while swapping takes more of the disk than the thrashing threshold.")
+	m.sample("gosimos_thrashing", "", boolValue(sw.Thrashing))
+
+	calls := s.SyscallStats()
+	m.family("gosimos_syscalls_total", "counter", "System calls made, by call.")
+	for _, st := range calls {
+		m.sample("gosimos_syscalls_total", label("call", st.Name), float64(st.Calls))
+	}
+	m.family("gosimos_syscall_errors_total", "counter", "System calls that returned an error, by call.")
+	for _, st := range calls {
+		m.sample("gosimos_syscall_errors_total", label("call", st.Name), float64(st.Errors))
+	}
+
+	m.family("gosimos_deadlocks_total", "counter", "Deadlock cycles detected.")
+	m.sample("gosimos_deadlocks_total", "", float64(len(s.Deadlocks())))
+}
+
+type promWriter struct {
+	w io.Writer
+}
+
+func (m *promWriter) family(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m *promWriter) sample(name, labels string, v float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	fmt.Fprintf(m.w, "%s %s\n", name, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (m *promWriter) histogram(name, help string, h sched.Histogram) {
+	m.family(name, "histogram", help)
+	cum := 0
+	for i, bound := range h.Bounds {
+		cum += h.Counts[i]
+		m.sample(name+"_bucket", label("le", strconv.FormatFloat(seconds(bound), 'g', -1, 64)), float64(cum))
+	}
+	m.sample(name+"_bucket", label("le", "+Inf"), float64(h.Count))
+	m.sample(name+"_sum", "", seconds(h.Sum))
+	m.sample(name+"_count", "", float64(h.Count))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(k, v string) string {
+	return k + `="` + labelEscaper.Replace(v) + `"`
+}
+
+func seconds(d time.Duration) float64 { return d.Seconds() }
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gosimos/sched"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestMetricsGolden(t *testing.T) {
+	s := sched.NewScheduler(sched.WithSeed(1))
+	sched.RPC(s, 2, 2)
+	s.Spawn(&sched.ProcessSpec{Name: "writer", Program: []sched.Op{sched.FileWrite("out.txt", "hi"), sched.Compute(3)}})
+	s.Spawn(&sched.ProcessSpec{Name: "idle", Priority: 2, Program: []sched.Op{sched.Receive("never")}})
+	s.Step(12)
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, s)
+	golden := filepath.Join("testdata", "metrics.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("metrics differ from %s (rerun with -update to accept):\n%s", golden, buf.String())
+	}
+}
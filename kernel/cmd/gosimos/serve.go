@@ -0,0 +1,569 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gosimos/ipc"
+	"gosimos/sched"
+)
+
+const (
+	apiPoll     = 50 * time.Millisecond
+	apiEventBuf = 256
+	apiMaxStep  = 10000
+	apiMaxUnits = 1 << 20
+)
+
+// api serves a running scheduler over HTTP. Its event stream comes from
+// observing the scheduler, plus a poll every apiPoll for pause state and
+// deadlocks.
+type api struct {
+	sched.BaseObserver
+	s *sched.Scheduler
+
+	mu   sync.Mutex
+	subs map[chan apiEvent]struct{}
+
+	paused    bool
+	deadlocks int
+}
+
+// web is the browser dashboard served at /.
+//
+//go:embed web
+var web embed.FS
+
+type apiEvent struct {
+	Type   string        `json:"type"`
+	Now    time.Duration `json:"now"`
+	PID    int           `json:"pid,omitempty"`
+	Name   string        `json:"name,omitempty"`
+	Detail string        `json:"detail,omitempty"`
+}
+
+type apiProc struct {
+	PID        int           `json:"pid"`
+	Name       string        `json:"name"`
+	Priority   int           `json:"priority"`
+	State      string        `json:"state"`
+	WaitingOn  string        `json:"waiting_on,omitempty"`
+	ExitReason string        `json:"exit_reason,omitempty"`
+	Parent     int           `json:"parent,omitempty"`
+	User       string        `json:"user"`
+	RunCount   int           `json:"run_count"`
+	CPU        time.Duration `json:"cpu"`
+	Remaining  int           `json:"remaining"`
+	VRuntime   time.Duration `json:"vruntime,omitempty"`
+	Swapped    bool          `json:"swapped,omitempty"`
+	SwapIns    int           `json:"swap_ins,omitempty"`
+	SwapOuts   int           `json:"swap_outs,omitempty"`
+	TGID       int           `json:"tgid"`
+	Threads    int           `json:"threads,omitempty"`
+}
+
+type apiThread struct {
+	TID   int           `json:"tid"`
+	Name  string        `json:"name"`
+	Model string        `json:"model"`
+	State string        `json:"state"`
+	CPU   time.Duration `json:"cpu"`
+	Runs  int           `json:"runs"`
+}
+
+type apiSpawn struct {
+	Name      string         `json:"name"`
+	Priority  int            `json:"priority"`
+	WorkUnits int            `json:"work_units"`
+	Behavior  string         `json:"behavior"` // compute (default), ipc or fs
+	Journal   bool           `json:"journal"`
+	MaxNeed   map[string]int `json:"max_need"`
+}
+
+var apiBehaviors = map[string]sched.Behavior{
+	"":        sched.BehaviorCompute,
+	"compute": sched.BehaviorCompute,
+	"ipc":     sched.BehaviorIPCSender,
+	"fs":      sched.BehaviorFSWriter,
+}
+
+// newAPI registers the api as an observer of s.
+func newAPI(s *sched.Scheduler) *api {
+	a := &api{
+		s:    s,
+		subs: make(map[chan apiEvent]struct{}),
+	}
+	s.AddObserver(a)
+	return a
+}
+
+// serveAPI listens on addr until stop is closed.
+func serveAPI(s *sched.Scheduler, addr string, stop <-chan struct{}) {
+	a := newAPI(s)
+	srv := &http.Server{Addr: addr, Handler: a.routes()}
+	go a.watch(stop)
+	go func() {
+		<-stop
+		s.RemoveObserver(a)
+		_ = srv.Close()
+	}()
+	log.Printf("HTTP API on %s\n", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("serve: %v", err)
+	}
+}
+
+func (a *api) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /procs", a.procs)
+	mux.HandleFunc("GET /procs/{pid}", a.proc)
+	mux.HandleFunc("GET /procs/{pid}/strace", a.strace)
+	mux.HandleFunc("GET /procs/{pid}/threads", a.threads)
+	mux.HandleFunc("GET /ready", a.ready)
+	mux.HandleFunc("GET /mailboxes", a.mailboxes)
+	mux.HandleFunc("GET /flows", a.flows)
+	mux.HandleFunc("GET /ipc", a.ipcStats)
+	mux.HandleFunc("GET /fs/{path...}", a.fs)
+	mux.HandleFunc("GET /proc/{path...}", a.procfs)
+	mux.HandleFunc("GET /dmesg", a.dmesg)
+	mux.HandleFunc("GET /status", a.status)
+	mux.HandleFunc("GET /metrics", a.metrics)
+	mux.HandleFunc("GET /events", a.events)
+	mux.HandleFunc("POST /spawn", a.spawn)
+	mux.HandleFunc("POST /procs/{pid}/kill", a.kill)
+	mux.HandleFunc("POST /pause", a.pause)
+	mux.HandleFunc("POST /resume", a.resume)
+	mux.HandleFunc("POST /step", a.step)
+	static, _ := fs.Sub(web, "web")
+	mux.Handle("GET /", http.FileServerFS(static))
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, format string, args ...any) {
+	writeJSON(w, code, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+func toAPIProc(st sched.ProcessStat) apiProc {
+	return apiProc{
+		PID:        st.ID,
+		Name:       st.Name,
+		Priority:   st.Priority,
+		State:      st.State.String(),
+		WaitingOn:  st.WaitingOn,
+		ExitReason: st.ExitReason,
+		Parent:     st.Parent,
+		User:       st.User,
+		RunCount:   st.RunCount,
+		CPU:        st.TotalCPU,
+		Remaining:  st.Remaining,
+		VRuntime:   st.VRuntime,
+		Swapped:    st.Swapped,
+		SwapIns:    st.SwapIns,
+		SwapOuts:   st.SwapOuts,
+		TGID:       st.TGID,
+		Threads:    st.Threads,
+	}
+}
+
+func (a *api) procs(w http.ResponseWriter, r *http.Request) {
+	stats := a.s.Stats()
+	out := make([]apiProc, 0, len(stats))
+	for _, st := range stats {
+		out = append(out, toAPIProc(st))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *api) proc(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(r.PathValue("pid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad pid %q", r.PathValue("pid"))
+		return
+	}
+	for _, st := range a.s.Stats() {
+		if st.ID == pid {
+			writeJSON(w, http.StatusOK, toAPIProc(st))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "%v: %d", sched.ErrNoProcess, pid)
+}
+
+// strace exports a process's system call log as JSON, or as strace text
+// with ?format=text.
+func (a *api) strace(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(r.PathValue("pid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad pid %q", r.PathValue("pid"))
+		return
+	}
+	recs, err := a.s.Strace(pid)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "%v: %d", err, pid)
+		return
+	}
+	if r.URL.Query().Get("format") != "text" {
+		writeJSON(w, http.StatusOK, recs)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, rec := range recs {
+		fmt.Fprintf(w, "%v %s\n", rec.Time, rec)
+	}
+}
+
+// threads lists the threads of a process, the main one first.
+func (a *api) threads(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(r.PathValue("pid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad pid %q", r.PathValue("pid"))
+		return
+	}
+	ts, err := a.s.Threads(pid)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "%v: %d", err, pid)
+		return
+	}
+	out := make([]apiThread, 0, len(ts))
+	for _, t := range ts {
+		out = append(out, apiThread{TID: t.TID, Name: t.Name, Model: t.Model.String(), State: t.State.String(), CPU: t.CPU, Runs: t.Runs})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// dmesg exports the kernel log, filtered by ?level=, ?subsys= and ?pid=,
+// as JSON or as dmesg text with ?format=text.
+func (a *api) dmesg(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var args []string
+	for _, p := range [...]struct{ flag, key string }{{"-l", "level"}, {"-s", "subsys"}, {"-p", "pid"}} {
+		if v := q.Get(p.key); v != "" {
+			args = append(args, p.flag, v)
+		}
+	}
+	f, _, err := logFilter(args)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	entries := a.s.Dmesg(f)
+	if q.Get("format") != "text" {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, e := range entries {
+		fmt.Fprintln(w, e)
+	}
+}
+
+func (a *api) mailboxes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Queued map[int][]ipc.Message `json:"queued"`
+		Stats  []ipc.MailboxStat     `json:"stats"`
+	}{a.s.DumpMailboxes(), a.s.MailboxStats()})
+}
+
+func (a *api) ready(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.s.ReadyQueue())
+}
+
+func (a *api) flows(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.s.Flows())
+}
+
+func (a *api) ipcStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Pipes    []ipc.PipeStat    `json:"pipes"`
+		Segments []ipc.SegmentStat `json:"segments"`
+	}{a.s.PipeStats(), a.s.SegmentStats()})
+}
+
+// fs lists the virtual FS for /fs/ and returns one file's content for
+// /fs/<path>.
+func (a *api) fs(w http.ResponseWriter, r *http.Request) {
+	files := a.s.DumpFS()
+	path := r.PathValue("path")
+	if path == "" {
+		sizes := make(map[string]int, len(files))
+		for name, data := range files {
+			sizes[name] = len(data)
+		}
+		writeJSON(w, http.StatusOK, sizes)
+		return
+	}
+	data, ok := files[path]
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such file %q", path)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(data))
+}
+
+// procfs serves /proc, generated at the time of the request.
+func (a *api) procfs(w http.ResponseWriter, r *http.Request) {
+	path := sched.ProcDir + "/" + r.PathValue("path")
+	if r.PathValue("path") == "" {
+		writeJSON(w, http.StatusOK, a.s.ReadDir(sched.ProcDir))
+		return
+	}
+	data, err := a.s.ReadFile(path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "no such file %q", path)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(data))
+}
+
+// status is a JSON summary of the run; /metrics has the full set in
+// Prometheus format.
+func (a *api) status(w http.ResponseWriter, r *http.Request) {
+	states := make(map[string]int)
+	stats := a.s.Stats()
+	for _, st := range stats {
+		states[st.State.String()]++
+	}
+	queued := 0
+	for _, msgs := range a.s.DumpMailboxes() {
+		queued += len(msgs)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"now":        a.s.Now(),
+		"dispatches": a.s.Dispatches(),
+		"paused":     a.s.Paused(),
+		"procs":      len(stats),
+		"states":     states,
+		"queued":     queued,
+		"deadlocks":  len(a.s.Deadlocks()),
+		"files":      len(a.s.DumpFS()),
+	})
+}
+
+func (a *api) spawn(w http.ResponseWriter, r *http.Request) {
+	var req apiSpawn
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad spawn request: %v", err)
+		return
+	}
+	behavior, ok := apiBehaviors[req.Behavior]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unknown behavior %q", req.Behavior)
+		return
+	}
+	if req.WorkUnits <= 0 || req.WorkUnits > apiMaxUnits {
+		writeError(w, http.StatusBadRequest, "work_units must be between 1 and %d", apiMaxUnits)
+		return
+	}
+	if req.Name == "" {
+		req.Name = "api"
+	}
+	spec := &sched.ProcessSpec{
+		Name:      req.Name,
+		Priority:  req.Priority,
+		WorkUnits: req.WorkUnits,
+		Behavior:  behavior,
+		MaxNeed:   req.MaxNeed,
+	}
+	if req.Journal {
+		spec.Program = sched.JournalProgram(spec.Name, spec.WorkUnits)
+	}
+	a.s.RecordCommand("spawn " + spec.Name)
+	pid, err := a.s.TrySpawn(spec)
+	if err != nil {
+		writeError(w, http.StatusConflict, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]int{"pid": pid})
+}
+
+func (a *api) kill(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(r.PathValue("pid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad pid %q", r.PathValue("pid"))
+		return
+	}
+	a.s.RecordCommand(fmt.Sprintf("kill %d", pid))
+	if !a.s.Kill(pid) {
+		writeError(w, http.StatusNotFound, "no live process %d", pid)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"killed": pid})
+}
+
+func (a *api) pause(w http.ResponseWriter, r *http.Request) {
+	a.s.RecordCommand("pause")
+	a.s.Pause()
+	a.status(w, r)
+}
+
+func (a *api) resume(w http.ResponseWriter, r *http.Request) {
+	a.s.RecordCommand("resume")
+	a.s.Resume()
+	a.status(w, r)
+}
+
+// step runs ?n= dispatches (default 1) and leaves the scheduler paused.
+func (a *api) step(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > apiMaxStep {
+			writeError(w, http.StatusBadRequest, "n must be between 1 and %d", apiMaxStep)
+			return
+		}
+	}
+	a.s.RecordCommand(fmt.Sprintf("step %d", n))
+	done := a.s.Step(n)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"stepped":    done,
+		"now":        a.s.Now(),
+		"dispatches": a.s.Dispatches(),
+	})
+}
+
+// events streams scheduler events as Server-Sent Events until the client
+// goes away.
+func (a *api) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	ch := a.subscribe()
+	defer a.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+func (a *api) subscribe() chan apiEvent {
+	ch := make(chan apiEvent, apiEventBuf)
+	a.mu.Lock()
+	a.subs[ch] = struct{}{}
+	a.mu.Unlock()
+	return ch
+}
+
+func (a *api) unsubscribe(ch chan apiEvent) {
+	a.mu.Lock()
+	delete(a.subs, ch)
+	a.mu.Unlock()
+}
+
+// publish hands ev to every subscriber; a subscriber that has fallen
+// apiEventBuf events behind misses it.
+func (a *api) publish(ev apiEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for ch := range a.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (a *api) watch(stop <-chan struct{}) {
+	tick := time.NewTicker(apiPoll)
+	defer tick.Stop()
+	for {
+		a.sample()
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// The api is a scheduler observer: process events go straight to the
+// subscribers. Publishing never blocks, as the kernel lock is held.
+
+func (a *api) procEvent(typ string, now time.Duration, p sched.ProcessStat, detail string) {
+	a.publish(apiEvent{Type: typ, Now: now, PID: p.ID, Name: p.Name, Detail: detail})
+}
+
+func (a *api) OnSpawn(now time.Duration, p sched.ProcessStat) {
+	detail := ""
+	if p.Parent != 0 {
+		detail = fmt.Sprintf("parent %d", p.Parent)
+	}
+	a.procEvent("spawn", now, p, detail)
+}
+
+// OnDispatch and OnPreempt carry the simulated clock at which a process
+// got and gave up the CPU, for the dashboard's Gantt chart.
+func (a *api) OnDispatch(now time.Duration, p sched.ProcessStat) {
+	a.procEvent("dispatch", now, p, "")
+}
+
+func (a *api) OnPreempt(now time.Duration, p sched.ProcessStat) {
+	a.procEvent("preempt", now, p, "")
+}
+
+func (a *api) OnBlock(now time.Duration, p sched.ProcessStat) {
+	a.procEvent("block", now, p, p.WaitingOn)
+}
+
+func (a *api) OnWake(now time.Duration, p sched.ProcessStat) { a.procEvent("wake", now, p, "") }
+
+func (a *api) OnExit(now time.Duration, p sched.ProcessStat) {
+	a.procEvent("exit", now, p, p.ExitReason)
+}
+
+func (a *api) OnMessage(now time.Duration, m ipc.Message) {
+	a.publish(apiEvent{Type: "message", Now: now, PID: m.From, Detail: fmt.Sprintf("to %d: %s", m.To, m.Type)})
+}
+
+// sample publishes what the kernel has no event for: pause/resume and new
+// deadlocks.
+func (a *api) sample() {
+	now := a.s.Now()
+	if paused := a.s.Paused(); paused != a.paused {
+		a.paused = paused
+		ev := apiEvent{Type: "resume", Now: now}
+		if paused {
+			ev.Type = "pause"
+		}
+		a.publish(ev)
+	}
+
+	reports := a.s.Deadlocks()
+	for _, d := range reports[min(a.deadlocks, len(reports)):] {
+		detail := "PIDs " + strings.Trim(fmt.Sprint(d.PIDs), "[]")
+		if d.Victim != 0 {
+			detail += fmt.Sprintf(", killed %d", d.Victim)
+		}
+		a.publish(apiEvent{Type: "deadlock", Now: now, PID: d.Victim, Detail: detail})
+	}
+	a.deadlocks = len(reports)
+}
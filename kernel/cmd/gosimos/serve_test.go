@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gosimos/sched"
+)
+
+func TestAPIControlsScheduler(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&sched.ProcessSpec{Name: "writer", Program: []sched.Op{sched.FileWrite("out.txt", "hi")}})
+	srv := httptest.NewServer(newAPI(s).routes())
+	defer srv.Close()
+
+	call := func(method, path, body string, wantCode int, out any) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != wantCode {
+			t.Fatalf("%s %s = %d %s, want %d", method, path, resp.StatusCode, data, wantCode)
+		}
+		if out != nil {
+			if err := json.Unmarshal(data, out); err != nil {
+				if sp, ok := out.(*string); ok {
+					*sp = string(data)
+				} else {
+					t.Fatalf("%s %s: %v", method, path, err)
+				}
+			}
+		}
+	}
+
+	var spawned map[string]int
+	call("POST", "/spawn", `{"name":"cpu","priority":1,"work_units":3}`, http.StatusCreated, &spawned)
+	if spawned["pid"] != 2 {
+		t.Errorf("spawn returned %v", spawned)
+	}
+	call("POST", "/spawn", `{"work_units":1,"behavior":"gpu"}`, http.StatusBadRequest, nil)
+
+	var stepped map[string]any
+	call("POST", "/step?n=2", "", http.StatusOK, &stepped)
+	if stepped["stepped"] != float64(2) || !s.Paused() {
+		t.Errorf("step = %v, paused=%v", stepped, s.Paused())
+	}
+
+	var procs []apiProc
+	call("GET", "/procs", "", http.StatusOK, &procs)
+	if len(procs) != 2 || procs[0].State != "exited" || procs[1].Name != "cpu" {
+		t.Errorf("procs = %+v", procs)
+	}
+	var one apiProc
+	call("GET", "/procs/2", "", http.StatusOK, &one)
+	if one.PID != 2 || one.Priority != 1 || one.User != "root" || one.TGID != 2 {
+		t.Errorf("procs/2 = %+v", one)
+	}
+	call("GET", "/procs/9", "", http.StatusNotFound, nil)
+	var trace []sched.SyscallRecord
+	call("GET", "/procs/1/strace", "", http.StatusOK, &trace)
+	if len(trace) != 1 || trace[0].String() != `writefile("out.txt", "hi") = 2` {
+		t.Errorf("procs/1/strace = %+v", trace)
+	}
+	var text string
+	call("GET", "/procs/1/strace?format=text", "", http.StatusOK, &text)
+	if !strings.HasSuffix(text, "writefile(\"out.txt\", \"hi\") = 2\n") {
+		t.Errorf("procs/1/strace?format=text = %q", text)
+	}
+	call("GET", "/procs/9/strace", "", http.StatusNotFound, nil)
+	var threads []apiThread
+	call("GET", "/procs/2/threads", "", http.StatusOK, &threads)
+	if len(threads) != 1 || threads[0].TID != 2 || threads[0].Model != "1:1" {
+		t.Errorf("procs/2/threads = %+v", threads)
+	}
+	call("GET", "/procs/9/threads", "", http.StatusNotFound, nil)
+	var status1 string
+	call("GET", "/proc/2/status", "", http.StatusOK, &status1)
+	if !strings.Contains(status1, "Name:\tcpu\n") {
+		t.Errorf("proc/2/status = %q", status1)
+	}
+	var listing []string
+	call("GET", "/proc/", "", http.StatusOK, &listing)
+	if len(listing) != 10 || listing[7] != "/proc/loadavg" {
+		t.Errorf("proc/ = %q", listing)
+	}
+	call("GET", "/proc/9/status", "", http.StatusNotFound, nil)
+	var klog []sched.LogEntry
+	call("GET", "/dmesg?subsys=sched", "", http.StatusOK, &klog)
+	if len(klog) != 1 || !strings.HasPrefix(klog[0].Msg, "policy ") {
+		t.Errorf("dmesg?subsys=sched = %+v", klog)
+	}
+	call("GET", "/dmesg?level=loud", "", http.StatusBadRequest, nil)
+
+	var content string
+	call("GET", "/fs/out.txt", "", http.StatusOK, &content)
+	if content != "hi" {
+		t.Errorf("fs/out.txt = %q", content)
+	}
+	var files map[string]int
+	call("GET", "/fs/", "", http.StatusOK, &files)
+	if files["out.txt"] != 2 {
+		t.Errorf("fs listing = %v", files)
+	}
+
+	call("POST", "/procs/2/kill", "", http.StatusOK, nil)
+	call("POST", "/procs/2/kill", "", http.StatusNotFound, nil)
+	call("POST", "/resume", "", http.StatusOK, nil)
+	if s.Paused() {
+		t.Error("resume left the scheduler paused")
+	}
+	var ready []int
+	call("GET", "/ready", "", http.StatusOK, &ready)
+	if len(ready) != 0 {
+		t.Errorf("ready = %v after everything exited or was killed", ready)
+	}
+	var page string
+	call("GET", "/", "", http.StatusOK, &page)
+	if !strings.Contains(page, "<title>GoSimOS</title>") {
+		t.Errorf("dashboard page = %.80q", page)
+	}
+	call("GET", "/app.js", "", http.StatusOK, nil)
+
+	var status map[string]any
+	call("GET", "/status", "", http.StatusOK, &status)
+	if status["procs"] != float64(2) || status["files"] != float64(1) {
+		t.Errorf("status = %v", status)
+	}
+}
+
+func TestAPIEventsFollowScheduler(t *testing.T) {
+	s := newTestScheduler()
+	a := newAPI(s)
+	ch := a.subscribe()
+	s.Spawn(&sched.ProcessSpec{Name: "holder", Program: []sched.Op{sched.MutexLock("m"), sched.Compute(1), sched.MutexUnlock("m")}})
+	s.Spawn(&sched.ProcessSpec{Name: "waiter", Program: []sched.Op{sched.MutexLock("m")}})
+	a.sample()
+	s.Step(2)
+	a.sample()
+	s.Step(1)
+	a.sample()
+	s.Step(10)
+	a.sample()
+
+	var got []string
+	var evs []apiEvent
+	for len(ch) > 0 {
+		ev := <-ch
+		got = append(got, ev.Type+" "+ev.Name)
+		evs = append(evs, ev)
+	}
+	want := []string{"spawn holder", "spawn waiter", "dispatch holder", "preempt holder", "dispatch waiter", "block waiter",
+		"pause ", "dispatch holder", "wake waiter", "exit holder", "dispatch waiter", "exit waiter"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %q\nwant     %q", got, want)
+	} else if run := evs[3].Now - evs[2].Now; run != time.Millisecond {
+		t.Errorf("holder ran %v from dispatch to preempt, want its 1ms quantum of simulated time", run)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosimos/sched"
+)
+
+const shellHelp = `commands:
+  ps                      process table
+  status                  clock, dispatches, run state
+  pause | resume          stop / continue the scheduler loop
+  step [n]                run n dispatches (default 1) while paused
+  steptime <dur>          run until the simulated clock advances by dur
+  break block <pid>       pause when PID blocks
+  break exit <pid>        pause when PID exits
+  break ready <n>         pause when the ready queue grows past n
+  break at <dur>          pause when simulated time reaches dur
+  breaks                  list breakpoints
+  delete <id>             remove a breakpoint
+  kill <pid>              terminate a process
+  checkpoint <file>       save a snapshot of the simulation
+  sync | mail | fs        kernel objects, mailboxes, virtual FS
+  ls [dir]                files with their mode and owner; ls /proc
+  cat <path>              print a file, e.g. /proc/loadavg or /proc/<pid>/status
+  users                   accounts and their groups
+  type <text>             type a line on the simulated TTY
+  irq                     interrupts per device
+  strace <pid>            system calls made by a process
+  threads <pid>           threads of a process, kernel or user level
+  syscalls                system call counts and errors
+  dmesg [-l level] [-s subsys] [-p pid] [-o file]
+                          kernel log, filtered; -o saves it to a file
+  quit                    leave the shell`
+
+// runShell reads commands from in until quit or EOF. Breakpoint hits are
+// announced as they happen.
+func runShell(s *sched.Scheduler, in io.Reader) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case h := <-s.Hits():
+				fmt.Printf("\n%s⏸  breakpoint #%d (%s)%s hit by PID %d at t=%v, dispatch %d\n> ",
+					ansiYellow, h.ID, h.Desc, ansiReset, h.PID, h.Now, h.Dispatch)
+			}
+		}
+	}()
+
+	sc := bufio.NewScanner(in)
+	fmt.Print("> ")
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) != "" {
+			s.RecordCommand(sc.Text())
+		}
+		if !shellCommand(s, strings.Fields(sc.Text())) {
+			return
+		}
+		fmt.Print("> ")
+	}
+}
+
+func shellCommand(s *sched.Scheduler, args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+
+	switch args[0] {
+	case "help", "?":
+		fmt.Println(shellHelp)
+	case "quit", "exit":
+		return false
+	case "ps":
+		printProcessTable(s.Stats())
+	case "status":
+		state := "running"
+		if s.Paused() {
+			state = "paused"
+		}
+		fmt.Printf(" t=%v  dispatches=%d  %s\n", s.Now(), s.Dispatches(), state)
+	case "pause":
+		s.Pause()
+		fmt.Printf(" paused at t=%v\n", s.Now())
+	case "resume", "run", "continue":
+		s.Resume()
+	case "step":
+		n := 1
+		if arg(1) != "" {
+			v, err := strconv.Atoi(arg(1))
+			if err != nil {
+				fmt.Println(" usage: step [n]")
+				return true
+			}
+			n = v
+		}
+		done := s.Step(n)
+		fmt.Printf(" stepped %d dispatch(es), t=%v\n", done, s.Now())
+	case "steptime":
+		d, err := time.ParseDuration(arg(1))
+		if err != nil {
+			fmt.Println(" usage: steptime <duration>")
+			return true
+		}
+		done := s.StepTime(d)
+		fmt.Printf(" stepped %d dispatch(es), t=%v\n", done, s.Now())
+	case "break":
+		shellBreak(s, arg(1), arg(2))
+	case "breaks":
+		bps := s.Breakpoints()
+		if len(bps) == 0 {
+			fmt.Println(" (none)")
+		}
+		for _, bp := range bps {
+			fmt.Printf(" #%d  %-24s hits=%d\n", bp.ID, bp.Desc, bp.Hits)
+		}
+	case "delete":
+		id, _ := strconv.Atoi(arg(1))
+		if !s.ClearBreakpoint(id) {
+			fmt.Printf(" no breakpoint %q\n", arg(1))
+		}
+	case "kill":
+		pid, _ := strconv.Atoi(arg(1))
+		if !s.Kill(pid) {
+			fmt.Printf(" no live process %q\n", arg(1))
+		}
+	case "checkpoint", "save":
+		if arg(1) == "" {
+			fmt.Println(" usage: checkpoint <file>")
+			break
+		}
+		if err := s.SaveCheckpoint(arg(1)); err != nil {
+			fmt.Printf(" checkpoint: %v\n", err)
+		} else {
+			fmt.Printf(" saved t=%v, dispatch %d to %s\n", s.Now(), s.Dispatches(), arg(1))
+		}
+	case "sync":
+		printSync(s.SyncStats())
+	case "mail":
+		printMailboxes(s.DumpMailboxes())
+		printMailStats(s.MailboxStats())
+	case "fs":
+		printFS(s.DumpFS())
+	case "ls":
+		if arg(1) != "" {
+			for _, name := range s.ReadDir(arg(1)) {
+				fmt.Println(" " + name)
+			}
+			break
+		}
+		printLs(s)
+	case "cat":
+		data, err := s.ReadFile(arg(1))
+		if err != nil {
+			fmt.Printf(" cat %s: %v\n", arg(1), err)
+			break
+		}
+		fmt.Print(data)
+	case "users":
+		printUsers(s.Users())
+	case "type":
+		s.Type(strings.Join(args[1:], " ") + "\n")
+	case "irq":
+		printIRQs(s.IRQStats())
+	case "strace":
+		pid, err := strconv.Atoi(arg(1))
+		if err != nil {
+			fmt.Println(" usage: strace <pid>")
+			break
+		}
+		printStrace(s, pid)
+	case "threads":
+		pid, err := strconv.Atoi(arg(1))
+		if err != nil {
+			fmt.Println(" usage: threads <pid>")
+			break
+		}
+		printThreads(s, pid)
+	case "syscalls":
+		printSyscalls(s.SyscallStats())
+	case "dmesg":
+		f, rest, err := logFilter(args[1:])
+		switch {
+		case err != nil || (len(rest) != 0 && (len(rest) != 2 || rest[0] != "-o")):
+			fmt.Println(" usage: dmesg [-l level] [-s subsys] [-p pid] [-o file]")
+		case len(rest) == 2:
+			if err := s.SaveLog(rest[1], f); err != nil {
+				fmt.Printf(" dmesg: %v\n", err)
+			}
+		default:
+			printDmesg(s.Dmesg(f), math.MaxInt)
+		}
+	default:
+		fmt.Printf(" unknown command %q (try help)\n", args[0])
+	}
+	return true
+}
+
+func shellBreak(s *sched.Scheduler, kind, val string) {
+	var id int
+	switch kind {
+	case "block", "exit", "ready":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			fmt.Printf(" usage: break %s <n>\n", kind)
+			return
+		}
+		switch kind {
+		case "block":
+			id = s.BreakOnBlock(n)
+		case "exit":
+			id = s.BreakOnExit(n)
+		case "ready":
+			id = s.BreakOnReadyLen(n)
+		}
+	case "at":
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			fmt.Println(" usage: break at <duration>")
+			return
+		}
+		id = s.BreakAt(d)
+	default:
+		fmt.Println(" usage: break block|exit|ready|at <value>")
+		return
+	}
+	fmt.Printf(" breakpoint #%d set\n", id)
+}
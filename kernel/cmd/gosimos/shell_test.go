@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gosimos/sched"
+)
+
+func newTestScheduler() *sched.Scheduler {
+	return sched.NewScheduler(sched.WithQuantum(time.Millisecond), sched.WithUnit(time.Millisecond))
+}
+
+func TestShellDrivesScheduler(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&sched.ProcessSpec{Name: "a", WorkUnits: 5})
+
+	runShell(s, strings.NewReader("pause\nstep 2\nsteptime 2ms\nbreak at 1h\nquit\nstep 1\n"))
+	if got := s.Dispatches(); got != 4 {
+		t.Errorf("dispatches = %d, want 4", got)
+	}
+	if bps := s.Breakpoints(); len(bps) != 1 || bps[0].Desc != "t >= 1h0m0s" {
+		t.Errorf("breakpoints = %+v", bps)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosimos/sched"
+)
+
+const (
+	tuiRefresh   = 250 * time.Millisecond
+	tuiMaxProcs  = 20
+	tuiMaxFS     = 5
+	tuiMaxEvents = 8
+)
+
+var (
+	tuiSorts   = []string{"pid", "cpu", "prio", "state"}
+	tuiFilters = []string{"all", "live", "blocked"}
+)
+
+// tui is a top-style view of a running scheduler. Everything it shows is
+// derived from the public stats, diffed between frames.
+type tui struct {
+	s       *sched.Scheduler
+	sortBy  int
+	filter  int
+	killing bool
+	killBuf string
+
+	stats    []sched.ProcessStat
+	mail     map[int]int
+	cpu      map[int]float64
+	prev     map[int]sched.ProcessStat
+	prevFS   map[string]string
+	prevNow  time.Duration
+	deadlock int
+	fsLog    []string
+	events   []string
+}
+
+func newTUI(s *sched.Scheduler) *tui {
+	return &tui{
+		s:      s,
+		cpu:    make(map[int]float64),
+		prev:   make(map[int]sched.ProcessStat),
+		prevFS: make(map[string]string),
+	}
+}
+
+// runTUI draws the dashboard until q is pressed or maxRun has passed.
+// Keys are read one at a time when the terminal allows it; otherwise they
+// take effect on Enter.
+func runTUI(s *sched.Scheduler, in io.Reader, out io.Writer, maxRun time.Duration) {
+	restore := cbreakTerminal()
+	defer restore()
+	fmt.Fprint(out, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(out, "\033[?25h\033[?1049l")
+
+	done := make(chan struct{})
+	keys := readKeys(in, done)
+	defer stopKeys(in, done, keys)
+
+	t := newTUI(s)
+	tick := time.NewTicker(tuiRefresh)
+	defer tick.Stop()
+	deadline := time.After(maxRun)
+	for {
+		t.update()
+		fmt.Fprint(out, "\033[H\033[2J"+t.frame())
+		select {
+		case <-deadline:
+			return
+		case <-tick.C:
+		case h := <-s.Hits():
+			t.logEvent(fmt.Sprintf("%sbreakpoint #%d (%s) hit by PID %d%s", ansiYellow, h.ID, h.Desc, h.PID, ansiReset))
+		case k, ok := <-keys:
+			if !ok {
+				keys = nil // no keyboard, run until maxRun
+			} else if !t.key(k) {
+				return
+			}
+		}
+	}
+}
+
+// readKeys delivers the bytes read from in until done is closed or in
+// fails; the channel is closed when the reader goroutine returns.
+func readKeys(in io.Reader, done <-chan struct{}) <-chan byte {
+	keys := make(chan byte)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 1)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				return
+			}
+			if n == 1 {
+				select {
+				case keys <- buf[0]:
+				case <-done:
+					return
+				}
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+	return keys
+}
+
+// stopKeys ends readKeys's goroutine. A file that takes deadlines, such
+// as a terminal or pipe, has its pending read cut short so nothing more
+// is taken from it; other readers give up after their next byte.
+func stopKeys(in io.Reader, done chan struct{}, keys <-chan byte) {
+	close(done)
+	f, ok := in.(interface{ SetReadDeadline(time.Time) error })
+	if !ok || f.SetReadDeadline(time.Now()) != nil {
+		return
+	}
+	for range keys {
+	}
+	_ = f.SetReadDeadline(time.Time{})
+}
+
+// cbreakTerminal switches the terminal to unbuffered, silent input and
+// returns a function that undoes it. Without stty it does nothing.
+func cbreakTerminal() func() {
+	stty := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		return cmd.Output()
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return func() {}
+	}
+	if _, err := stty("cbreak", "-echo"); err != nil {
+		return func() {}
+	}
+	return func() { _, _ = stty(strings.TrimSpace(string(state))) }
+}
+
+func (t *tui) logEvent(msg string) {
+	t.events = append(t.events, fmt.Sprintf("%8v  %s", t.s.Now().Round(time.Millisecond), msg))
+	if len(t.events) > tuiMaxEvents {
+		t.events = t.events[len(t.events)-tuiMaxEvents:]
+	}
+}
+
+// update samples the scheduler and turns what changed since the last
+// frame into CPU shares, FS activity and log events. CPU shares are of
+// the simulated clock, like the timestamps in the logs.
+func (t *tui) update() {
+	t.stats = t.s.Stats()
+	now := t.s.Now()
+	elapsed := now - t.prevNow
+	for _, st := range t.stats {
+		old, seen := t.prev[st.ID]
+		switch {
+		case !seen:
+			t.logEvent(fmt.Sprintf("spawned PID %d %s", st.ID, st.Name))
+		case old.State == st.State:
+		case st.State == sched.StateExited && st.ExitReason != "":
+			t.logEvent(fmt.Sprintf("%sPID %d killed: %s%s", ansiRed, st.ID, st.ExitReason, ansiReset))
+		case st.State == sched.StateExited:
+			t.logEvent(fmt.Sprintf("%sPID %d exited%s", ansiGreen, st.ID, ansiReset))
+		case st.State == sched.StateBlocked:
+			t.logEvent(fmt.Sprintf("PID %d blocked on %s", st.ID, st.WaitingOn))
+		}
+		t.cpu[st.ID] = 0
+		if seen && elapsed > 0 {
+			t.cpu[st.ID] = 100 * float64(st.CPUTime-old.CPUTime) / float64(elapsed)
+		}
+		t.prev[st.ID] = st
+	}
+	t.prevNow = now
+
+	t.mail = make(map[int]int)
+	for _, mb := range t.s.MailboxStats() {
+		t.mail[mb.PID] = mb.Pending
+	}
+
+	fs := t.s.DumpFS()
+	var changed []string
+	for name, content := range fs {
+		if old, ok := t.prevFS[name]; !ok || old != content {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	for _, name := range changed {
+		t.fsLog = append(t.fsLog, fmt.Sprintf("%8v  write %s", t.s.Now().Round(time.Millisecond), name))
+	}
+	if len(t.fsLog) > tuiMaxFS {
+		t.fsLog = t.fsLog[len(t.fsLog)-tuiMaxFS:]
+	}
+	t.prevFS = fs
+
+	dl := t.s.Deadlocks()
+	for _, r := range dl[t.deadlock:] {
+		msg := fmt.Sprintf("%sdeadlock among %v%s", ansiRed, r.PIDs, ansiReset)
+		if r.Victim != 0 {
+			msg += fmt.Sprintf(", killed PID %d", r.Victim)
+		}
+		t.logEvent(msg)
+	}
+	t.deadlock = len(dl)
+}
+
+// key handles one keypress and reports whether the dashboard stays open.
+func (t *tui) key(k byte) bool {
+	if t.killing {
+		switch {
+		case k >= '0' && k <= '9':
+			t.killBuf += string(k)
+		case k == 127 || k == '\b':
+			if t.killBuf != "" {
+				t.killBuf = t.killBuf[:len(t.killBuf)-1]
+			}
+		case k == '\r' || k == '\n':
+			t.killing = false
+			if pid, err := strconv.Atoi(t.killBuf); err == nil && t.s.Kill(pid) {
+				t.logEvent(fmt.Sprintf("killed PID %d from the dashboard", pid))
+			}
+		case k == 27:
+			t.killing = false
+		}
+		return true
+	}
+	switch k {
+	case 'q', 'Q':
+		return false
+	case 's':
+		t.sortBy = (t.sortBy + 1) % len(tuiSorts)
+	case 'f':
+		t.filter = (t.filter + 1) % len(tuiFilters)
+	case 'p', ' ':
+		if t.s.Paused() {
+			t.s.Resume()
+		} else {
+			t.s.Pause()
+		}
+	case 'k':
+		t.killing, t.killBuf = true, ""
+	}
+	return true
+}
+
+// rows applies the current filter and sort order to the process table.
+func (t *tui) rows() []sched.ProcessStat {
+	var out []sched.ProcessStat
+	for _, st := range t.stats {
+		switch tuiFilters[t.filter] {
+		case "live":
+			if st.State == sched.StateExited {
+				continue
+			}
+		case "blocked":
+			if st.State != sched.StateBlocked {
+				continue
+			}
+		}
+		out = append(out, st)
+	}
+	less := func(a, b sched.ProcessStat) bool { return a.ID < b.ID }
+	switch tuiSorts[t.sortBy] {
+	case "cpu":
+		less = func(a, b sched.ProcessStat) bool { return t.cpu[a.ID] > t.cpu[b.ID] }
+	case "prio":
+		less = func(a, b sched.ProcessStat) bool { return a.Priority < b.Priority }
+	case "state":
+		less = func(a, b sched.ProcessStat) bool { return a.State < b.State }
+	}
+	sort.SliceStable(out, func(i, j int) bool { return less(out[i], out[j]) })
+	return out
+}
+
+func (t *tui) frame() string {
+	var b strings.Builder
+	run := ansiGreen + "running" + ansiReset
+	if t.s.Paused() {
+		run = ansiYellow + "PAUSED" + ansiReset
+	}
+	fmt.Fprintf(&b, "%s%sGoSimOS%s  t=%v  dispatches=%d  %s  sort:%s  filter:%s\n",
+		ansiBold, ansiCyan, ansiReset, t.s.Now().Round(time.Millisecond), t.s.Dispatches(), run,
+		tuiSorts[t.sortBy], tuiFilters[t.filter])
+
+	count := map[sched.ProcState]int{}
+	mail := 0
+	for _, st := range t.stats {
+		count[st.State]++
+		mail += t.mail[st.ID]
+	}
+	waiters := 0
+	for _, ss := range t.s.SyncStats() {
+		waiters += len(ss.Waiters)
+	}
+	fmt.Fprintf(&b, "procs %d  ready %d  running %d  blocked %d  exited %d  lock waiters %d  mail %d\n\n",
+		len(t.stats), count[sched.StateReady], count[sched.StateRunning], count[sched.StateBlocked], count[sched.StateExited], waiters, mail)
+
+	fmt.Fprintf(&b, "%s%4s  %-16s  %3s  %-8s  %5s  %8s  %4s  %4s  %s%s\n",
+		ansiBold, "PID", "NAME", "PRI", "STATE", "CPU%", "CPU", "LEFT", "MAIL", "WAITING", ansiReset)
+	rows := t.rows()
+	for i, st := range rows {
+		if i == tuiMaxProcs {
+			fmt.Fprintf(&b, "  ... %d more\n", len(rows)-i)
+			break
+		}
+		color := ansiReset
+		switch st.State {
+		case sched.StateRunning:
+			color = ansiGreen
+		case sched.StateBlocked:
+			color = ansiRed
+		case sched.StateExited:
+			color = ansiMagenta
+		}
+		waiting := st.WaitingOn
+		if st.ExitReason != "" {
+			waiting = st.ExitReason
+		}
+		fmt.Fprintf(&b, "%4d  %-16s  %3d  %s%-8s%s  %5.1f  %8v  %4d  %4d  %s\n",
+			st.ID, truncate(st.Name, 16), st.Priority, color, st.State, ansiReset,
+			t.cpu[st.ID], st.CPUTime.Round(time.Millisecond), st.Remaining, t.mail[st.ID], waiting)
+	}
+
+	fmt.Fprintf(&b, "\n%sFS activity%s\n", ansiBold, ansiReset)
+	for _, line := range t.fsLog {
+		fmt.Fprintf(&b, " %s\n", line)
+	}
+	fmt.Fprintf(&b, "\n%sEvents%s\n", ansiBold, ansiReset)
+	for _, line := range t.events {
+		fmt.Fprintf(&b, " %s\n", line)
+	}
+
+	b.WriteString("\n")
+	if t.killing {
+		fmt.Fprintf(&b, "%skill PID: %s_%s  (Enter to confirm, Esc to cancel)\n", ansiYellow, t.killBuf, ansiReset)
+	} else {
+		b.WriteString("keys: s sort  f filter  p pause  k kill  q quit\n")
+	}
+	return b.String()
+}
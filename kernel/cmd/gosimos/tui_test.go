@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gosimos/sched"
+)
+
+func TestTUIFrameAndKeys(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&sched.ProcessSpec{Name: "holder", Program: []sched.Op{sched.MutexLock("m"), sched.Receive("never")}})
+	s.Spawn(&sched.ProcessSpec{Name: "waiter", Priority: 1, Program: []sched.Op{sched.MutexLock("m")}})
+	s.Spawn(&sched.ProcessSpec{Name: "writer", Priority: 2, Program: []sched.Op{sched.FileWrite("out.txt", "hi")}})
+	ui := newTUI(s)
+	ui.update()
+	s.Step(3)
+	ui.update()
+	frame := ui.frame()
+	for _, want := range []string{"holder", "waiter", "spawned PID 3 writer", "PID 2 blocked on mutex:m", "write out.txt", "PID 3 exited"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame lacks %q:\n%s", want, frame)
+		}
+	}
+
+	ui.key('f') // live
+	ui.key('f') // blocked
+	if rows := ui.rows(); len(rows) != 2 || rows[1].Name != "waiter" {
+		t.Errorf("blocked filter shows %+v", rows)
+	}
+	ui.key('f')
+	ui.key('s') // cpu
+	ui.key('s') // prio
+	if rows := ui.rows(); rows[0].Priority != 0 || rows[2].Priority != 2 {
+		t.Errorf("not sorted by priority: %+v", rows)
+	}
+
+	for _, k := range []byte("k1x\r") {
+		ui.key(k)
+	}
+	if st := s.Stats()[0]; st.State != sched.StateExited || st.ExitReason != "killed" {
+		t.Errorf("kill from the dashboard left PID 1 %v (%s)", st.State, st.ExitReason)
+	}
+	ui.key('p') // Step left the scheduler paused
+	if s.Paused() {
+		t.Error("p did not resume")
+	}
+	ui.key('p')
+	if !s.Paused() {
+		t.Error("p did not pause")
+	}
+	if ui.key('q') {
+		t.Error("q did not quit")
+	}
+}
+
+func TestTUICPUShareIsOfSimulatedTime(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&sched.ProcessSpec{Name: "busy", WorkUnits: 10})
+	ui := newTUI(s)
+	ui.update()
+	s.Step(4)
+	ui.update()
+	if got := ui.cpu[1]; got != 100 {
+		t.Errorf("busy at %.1f%% CPU, want 100%% of the simulated clock", got)
+	}
+}
+
+func TestTUIStopsReadingKeysOnQuit(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	done := make(chan struct{})
+	go func() {
+		runTUI(sched.NewScheduler(), r, io.Discard, time.Minute)
+		close(done)
+	}()
+	if _, err := w.Write([]byte("q")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("q did not close the dashboard")
+	}
+
+	// Whatever comes next on the input belongs to the next reader.
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1)
+	if n, err := r.Read(buf); n != 1 || buf[0] != 'x' {
+		t.Errorf("read %q, %v after the dashboard closed, want x", buf[:n], err)
+	}
+}
@@ -0,0 +1,262 @@
+// GoSimOS dashboard. Everything comes from the JSON API next to this file:
+// state is polled; the event log and the Gantt chart are the /events
+// stream.
+"use strict";
+
+const POLL_MS = 300;
+const GANTT_WINDOW = 10e9; // simulated nanoseconds shown in the Gantt chart
+const FRAME_BYTES = 16;
+const MAX_EVENTS = 200;
+
+const state = {
+  procs: [],
+  running: null, // {pid, from} since its dispatch event
+  bars: [], // {pid, from, to} in simulated time
+  flows: new Map(),
+  hot: new Set(),
+};
+
+const $ = (id) => document.getElementById(id);
+
+function color(pid) {
+  return `hsl(${(pid * 137) % 360}, 65%, 65%)`;
+}
+
+function fmt(ns) {
+  if (ns >= 1e9) return (ns / 1e9).toFixed(2) + "s";
+  return Math.round(ns / 1e6) + "ms";
+}
+
+async function getJSON(path) {
+  const resp = await fetch(path);
+  if (!resp.ok) throw new Error(`${path}: ${resp.status}`);
+  return resp.json();
+}
+
+async function post(path) {
+  const resp = await fetch(path, { method: "POST" });
+  if (!resp.ok) throw new Error((await resp.json()).error);
+  return resp.json();
+}
+
+async function poll() {
+  try {
+    const [procs, ready, flows, ipc, status] = await Promise.all([
+      getJSON("procs"), getJSON("ready"), getJSON("flows"), getJSON("ipc"), getJSON("status"),
+    ]);
+    state.procs = procs;
+    trackFlows(flows);
+    $("status").textContent =
+      `t=${fmt(status.now)}  dispatches=${status.dispatches}  ` +
+      `procs=${status.procs}  ${status.paused ? "paused" : "running"}`;
+    drawGantt(status.now);
+    drawReady(ready);
+    drawMemory(ipc);
+    drawTree(procs);
+    drawFlows(procs);
+  } catch (err) {
+    $("status").textContent = "disconnected: " + err.message;
+  }
+}
+
+// trackRun turns the kernel's dispatch events and the preempt, block or
+// exit that ends each run into Gantt bars, stamped with simulated time.
+function trackRun(ev) {
+  if (ev.type === "dispatch") {
+    state.running = { pid: ev.pid, from: ev.now };
+    return;
+  }
+  const r = state.running;
+  if (!r || r.pid !== ev.pid) return;
+  state.running = null;
+  state.bars.push({ pid: r.pid, from: r.from, to: Math.max(ev.now, r.from) });
+  state.bars = state.bars.filter((b) => b.to > ev.now - GANTT_WINDOW);
+}
+
+function trackFlows(flows) {
+  state.hot.clear();
+  const next = new Map();
+  for (const f of flows) {
+    const key = `${f.From}>${f.To}`;
+    if ((state.flows.get(key) || 0) < f.Count) state.hot.add(key);
+    next.set(key, f.Count);
+  }
+  state.flows = next;
+}
+
+function drawGantt(now) {
+  const canvas = $("gantt");
+  const live = state.procs.filter((p) => p.state !== "exited" || state.bars.some((b) => b.pid === p.pid));
+  const rowH = 16, labelW = 120;
+  canvas.width = canvas.clientWidth;
+  canvas.height = Math.max(60, live.length * rowH + 20);
+  const ctx = canvas.getContext("2d");
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  ctx.font = "11px monospace";
+
+  const start = Math.max(0, now - GANTT_WINDOW);
+  const x = (t) => labelW + ((t - start) / GANTT_WINDOW) * (canvas.width - labelW - 10);
+  const row = new Map(live.map((p, i) => [p.pid, i]));
+
+  live.forEach((p, i) => {
+    ctx.fillStyle = "#8b96a5";
+    ctx.fillText(`${p.pid} ${p.name}`.slice(0, 17), 2, i * rowH + 12);
+  });
+  const bars = state.running ? [...state.bars, { ...state.running, to: now }] : state.bars;
+  for (const b of bars) {
+    if (!row.has(b.pid)) continue;
+    ctx.fillStyle = color(b.pid);
+    const x0 = x(Math.max(b.from, start));
+    ctx.fillRect(x0, row.get(b.pid) * rowH + 2, Math.max(1, x(b.to) - x0), rowH - 4);
+  }
+  ctx.fillStyle = "#4b5563";
+  ctx.fillText(fmt(start), labelW, canvas.height - 4);
+  ctx.fillText(fmt(now), canvas.width - 60, canvas.height - 4);
+}
+
+function pidBox(pid, extra) {
+  const span = document.createElement("span");
+  span.className = "pid" + (extra ? " " + extra : "");
+  span.style.background = color(pid);
+  const p = state.procs.find((q) => q.pid === pid);
+  span.textContent = p ? `${pid} ${p.name}` : String(pid);
+  return span;
+}
+
+function drawReady(ready) {
+  const el = $("ready");
+  el.replaceChildren();
+  if (ready.length === 0) {
+    el.innerHTML = '<span class="empty">(empty)</span>';
+    return;
+  }
+  ready.forEach((pid, i) => el.append(pidBox(pid, i === 0 ? "head" : "")));
+}
+
+function frames(n, used, cls) {
+  const row = document.createElement("div");
+  row.className = "frames";
+  for (let i = 0; i < n; i++) {
+    const f = document.createElement("div");
+    f.className = "frame" + (i < used ? " " + cls : "");
+    row.append(f);
+  }
+  return row;
+}
+
+function drawMemory(ipc) {
+  const el = $("memory");
+  el.replaceChildren();
+  for (const sg of ipc.segments || []) {
+    const div = document.createElement("div");
+    div.className = "mem";
+    const n = Math.ceil(sg.Size / FRAME_BYTES);
+    div.textContent = `shm ${sg.Name}  ${sg.Size}B  attached: ${(sg.Attached || []).join(", ") || "—"}`;
+    div.append(frames(n, sg.Attached && sg.Attached.length ? n : 0, "used"));
+    el.append(div);
+  }
+  for (const pp of ipc.pipes || []) {
+    const div = document.createElement("div");
+    div.className = "mem";
+    div.textContent = `pipe ${pp.Name}  ${pp.Buffered}/${pp.Cap}B  r=${pp.Readers} w=${pp.Writers}`;
+    div.append(frames(Math.ceil(pp.Cap / 4), Math.ceil(pp.Buffered / 4), "pipe"));
+    el.append(div);
+  }
+  if (!el.children.length) el.innerHTML = '<span class="empty">(no segments or pipes)</span>';
+}
+
+function drawTree(procs) {
+  const kids = new Map();
+  for (const p of procs) {
+    const parent = procs.some((q) => q.pid === p.parent) ? p.parent : 0;
+    if (!kids.has(parent)) kids.set(parent, []);
+    kids.get(parent).push(p);
+  }
+  const build = (parent) => {
+    const ul = document.createElement("ul");
+    for (const p of kids.get(parent) || []) {
+      const li = document.createElement("li");
+      const name = document.createElement("span");
+      name.className = p.state;
+      name.textContent = `${p.pid} ${p.name}`;
+      const st = document.createElement("span");
+      st.className = "state";
+      st.textContent = ` ${p.state}${p.waiting_on ? " on " + p.waiting_on : ""}`;
+      li.append(name, st);
+      if (p.state !== "exited") {
+        const kill = document.createElement("button");
+        kill.textContent = "kill";
+        kill.onclick = () => post(`procs/${p.pid}/kill`).then(poll, alert);
+        li.append(kill);
+      }
+      if (kids.has(p.pid)) li.append(build(p.pid));
+      ul.append(li);
+    }
+    return ul;
+  };
+  $("tree").replaceChildren(build(0));
+}
+
+function drawFlows(procs) {
+  const svg = $("flows");
+  const ns = "http://www.w3.org/2000/svg";
+  svg.replaceChildren();
+  const pids = [...new Set([...state.flows.keys()].flatMap((k) => k.split(">").map(Number)))].sort((a, b) => a - b);
+  if (pids.length === 0) return;
+  const pos = new Map(pids.map((pid, i) => {
+    const a = (2 * Math.PI * i) / pids.length - Math.PI / 2;
+    return [pid, [130 * Math.cos(a), 130 * Math.sin(a)]];
+  }));
+  const max = Math.max(...state.flows.values());
+  for (const [key, count] of state.flows) {
+    const [from, to] = key.split(">").map(Number);
+    const line = document.createElementNS(ns, "line");
+    const [x1, y1] = pos.get(from), [x2, y2] = pos.get(to);
+    line.setAttribute("x1", x1); line.setAttribute("y1", y1);
+    line.setAttribute("x2", x2); line.setAttribute("y2", y2);
+    line.setAttribute("stroke-width", 1 + (5 * count) / max);
+    if (state.hot.has(key)) line.classList.add("hot");
+    const title = document.createElementNS(ns, "title");
+    title.textContent = `${from} → ${to}: ${count} messages`;
+    line.append(title);
+    svg.append(line);
+  }
+  for (const [pid, [x, y]] of pos) {
+    const c = document.createElementNS(ns, "circle");
+    c.setAttribute("cx", x); c.setAttribute("cy", y); c.setAttribute("r", 13);
+    c.setAttribute("fill", color(pid));
+    const t = document.createElementNS(ns, "text");
+    t.setAttribute("x", x); t.setAttribute("y", y);
+    t.style.fill = "#111418";
+    t.textContent = pid;
+    svg.append(c, t);
+  }
+}
+
+function listen() {
+  const src = new EventSource("events");
+  const log = $("events");
+  const add = (e) => {
+    const ev = JSON.parse(e.data);
+    const li = document.createElement("li");
+    li.className = ev.type;
+    const who = ev.pid ? ` PID ${ev.pid}${ev.name ? " " + ev.name : ""}` : "";
+    li.textContent = `${fmt(ev.now).padStart(8)}  ${ev.type}${who}${ev.detail ? "  " + ev.detail : ""}`;
+    log.prepend(li);
+    while (log.children.length > MAX_EVENTS) log.lastChild.remove();
+  };
+  for (const type of ["spawn", "block", "wake", "exit", "pause", "resume", "deadlock"]) {
+    src.addEventListener(type, add);
+  }
+  for (const type of ["dispatch", "preempt", "block", "exit"]) {
+    src.addEventListener(type, (e) => trackRun(JSON.parse(e.data)));
+  }
+}
+
+$("play").onclick = () => post("resume").then(poll, alert);
+$("pause").onclick = () => post("pause").then(poll, alert);
+$("step").onclick = () => post(`step?n=${Math.max(1, Number($("stepn").value) || 1)}`).then(poll, alert);
+
+listen();
+poll();
+setInterval(poll, POLL_MS);
@@ -0,0 +1,327 @@
+// Package fs is the GoSimOS virtual file system: an in-memory map of file
+// contents plus the named FIFOs created with mkfifo, each with an owner
+// and Unix permission bits. Read-only synthetic file systems such as
+// /proc can be mounted on top.
+package fs
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"gosimos/ipc"
+)
+
+var (
+	ErrNotExist   = errors.New("no such file or directory")
+	ErrPermission = errors.New("permission denied")
+	ErrNotOwner   = errors.New("operation not permitted")
+)
+
+// Mode is a file's permission bits as chmod(1) takes them: 0640 is
+// rw-r-----.
+type Mode uint16
+
+const (
+	PermRead  Mode = 4
+	PermWrite Mode = 2
+	PermExec  Mode = 1
+
+	// DefaultMode is what new files and FIFOs get.
+	DefaultMode Mode = 0644
+)
+
+func (m Mode) String() string {
+	b := []byte("rwxrwxrwx")
+	for i := range b {
+		if m&(1<<(8-i)) == 0 {
+			b[i] = '-'
+		}
+	}
+	return string(b)
+}
+
+// Cred is who is asking: a user, their primary group and any
+// supplementary groups. UID 0 is root and passes every check.
+type Cred struct {
+	UID    int
+	GID    int
+	Groups []int `json:",omitempty"`
+}
+
+// Root is the superuser's credential.
+var Root = Cred{}
+
+func (c Cred) inGroup(gid int) bool {
+	return c.GID == gid || slices.Contains(c.Groups, gid)
+}
+
+// Info is a file's owner and permission bits.
+type Info struct {
+	UID  int
+	GID  int
+	Mode Mode
+}
+
+// allows reports whether c may access a file with info in every way
+// want asks for (a combination of PermRead, PermWrite, PermExec).
+func (info Info) allows(c Cred, want Mode) bool {
+	switch {
+	case c.UID == 0:
+		return true
+	case c.UID == info.UID:
+		return info.Mode>>6&want == want
+	case c.inGroup(info.GID):
+		return info.Mode>>3&want == want
+	}
+	return info.Mode&want == want
+}
+
+// Source generates the files of a mount, afresh on every read. Names are
+// relative to the mount point.
+type Source interface {
+	Read(name string) (string, bool)
+	List() []string
+}
+
+type SimFS struct {
+	mu     sync.Mutex
+	files  map[string]string
+	fifos  map[string]*ipc.Pipe
+	info   map[string]Info // files and FIFOs
+	ops    map[string]int
+	mounts map[string]Source
+}
+
+func NewSimFS() *SimFS {
+	return &SimFS{
+		files:  make(map[string]string),
+		fifos:  make(map[string]*ipc.Pipe),
+		info:   make(map[string]Info),
+		ops:    make(map[string]int),
+		mounts: make(map[string]Source),
+	}
+}
+
+// Mount serves the files under dir from src. They are read-only, are
+// not part of Dump and are not counted by Size. A dir that already has a
+// mount keeps it, and Mount reports false.
+func (f *SimFS) Mount(dir string, src Source) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.mounts[dir]; ok {
+		return false
+	}
+	f.mounts[dir] = src
+	return true
+}
+
+// mountLocked finds the mount name lives on and name relative to it.
+func (f *SimFS) mountLocked(name string) (Source, string) {
+	for dir, src := range f.mounts {
+		if rel, ok := strings.CutPrefix(name, dir+"/"); ok {
+			return src, rel
+		}
+	}
+	return nil, ""
+}
+
+// Mounted reports whether name lives on a mount.
+func (f *SimFS) Mounted(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	src, _ := f.mountLocked(name)
+	return src != nil
+}
+
+// WriteFile writes name as root.
+func (f *SimFS) WriteFile(name, content string) error {
+	return f.WriteFileAs(Root, name, content)
+}
+
+// WriteFileAs writes name on behalf of c. A new file is created owned by
+// c with DefaultMode; an existing one needs write permission.
+func (f *SimFS) WriteFileAs(c Cred, name, content string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if src, _ := f.mountLocked(name); src != nil {
+		return ErrPermission
+	}
+	if info, ok := f.info[name]; ok && !info.allows(c, PermWrite) {
+		return ErrPermission
+	} else if !ok {
+		f.info[name] = Info{UID: c.UID, GID: c.GID, Mode: DefaultMode}
+	}
+	f.files[name] = content
+	f.ops["write"]++
+	return nil
+}
+
+// Access checks that c may use name in every way want asks for. Anyone
+// may read a mount; whether the file is there is up to the read.
+func (f *SimFS) Access(c Cred, name string, want Mode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if src, _ := f.mountLocked(name); src != nil {
+		if want&^PermRead != 0 {
+			return ErrPermission
+		}
+		return nil
+	}
+	info, ok := f.info[name]
+	switch {
+	case !ok:
+		return ErrNotExist
+	case !info.allows(c, want):
+		return ErrPermission
+	}
+	return nil
+}
+
+// Stat returns name's owner and mode.
+func (f *SimFS) Stat(name string) (Info, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, ok := f.info[name]
+	return info, ok
+}
+
+// Chmod sets name's permission bits; only its owner or root may.
+func (f *SimFS) Chmod(c Cred, name string, mode Mode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, ok := f.info[name]
+	switch {
+	case !ok:
+		if src, _ := f.mountLocked(name); src != nil {
+			return ErrNotOwner
+		}
+		return ErrNotExist
+	case c.UID != 0 && c.UID != info.UID:
+		return ErrNotOwner
+	}
+	info.Mode = mode & 0777
+	f.info[name] = info
+	f.ops["chmod"]++
+	return nil
+}
+
+// Infos returns the owner and mode of every file and FIFO.
+func (f *SimFS) Infos() map[string]Info {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return maps.Clone(f.info)
+}
+
+// SetInfo replaces name's owner and mode, for restoring a checkpoint.
+func (f *SimFS) SetInfo(name string, info Info) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.info[name] = info
+}
+
+func (f *SimFS) ReadFile(name string) (string, bool) {
+	f.mu.Lock()
+	f.ops["read"]++
+	f.mu.Unlock()
+	return f.Lookup(name)
+}
+
+// Lookup is ReadFile without counting an operation, for the operator's
+// tools rather than simulated processes.
+func (f *SimFS) Lookup(name string) (string, bool) {
+	f.mu.Lock()
+	src, rel := f.mountLocked(name)
+	content, ok := f.files[name]
+	f.mu.Unlock()
+	if src != nil {
+		return src.Read(rel)
+	}
+	return content, ok
+}
+
+// List names the files and FIFOs in dir and below, sorted; "" lists
+// everything stored, and a mount point the files its source has now.
+func (f *SimFS) List(dir string) []string {
+	f.mu.Lock()
+	src := f.mounts[dir]
+	var out []string
+	if src == nil {
+		for name := range f.info {
+			if dir == "" || strings.HasPrefix(name, dir+"/") {
+				out = append(out, name)
+			}
+		}
+	}
+	f.mu.Unlock()
+	if src != nil {
+		for _, name := range src.List() {
+			out = append(out, dir+"/"+name)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Size is the length of a file's content, 0 if it does not exist. It is
+// not counted as an operation.
+func (f *SimFS) Size(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.files[name])
+}
+
+// Mkfifo creates a root-owned FIFO.
+func (f *SimFS) Mkfifo(name string, pp *ipc.Pipe) {
+	f.MkfifoAs(Root, name, pp)
+}
+
+// MkfifoAs creates a FIFO owned by c with DefaultMode.
+func (f *SimFS) MkfifoAs(c Cred, name string, pp *ipc.Pipe) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fifos[name] = pp
+	f.info[name] = Info{UID: c.UID, GID: c.GID, Mode: DefaultMode}
+	f.ops["mkfifo"]++
+}
+
+func (f *SimFS) Fifo(name string) *ipc.Pipe {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fifos[name]
+}
+
+func (f *SimFS) Dump() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dup := make(map[string]string, len(f.files))
+	for k, v := range f.files {
+		dup[k] = v
+	}
+
+	return dup
+}
+
+func (f *SimFS) Fifos() map[string]*ipc.Pipe {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return maps.Clone(f.fifos)
+}
+
+// Ops counts file system calls by kind: read, write, mkfifo and chmod.
+func (f *SimFS) Ops() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return maps.Clone(f.ops)
+}
+
+// SetOps replaces the call counters, for restoring a checkpoint.
+func (f *SimFS) SetOps(ops map[string]int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ops = make(map[string]int, len(ops))
+	maps.Copy(f.ops, ops)
+}
@@ -1,3 +1,3 @@
-module os_kernel
+module gosimos
 
 go 1.25.4
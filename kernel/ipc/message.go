@@ -0,0 +1,44 @@
+package ipc
+
+import "time"
+
+// Message is a typed IPC message. Seq is assigned by the kernel on send;
+// a reply carries the request's Seq as its CorrID. SentAt and RecvAt are
+// simulated clock readings.
+type Message struct {
+	From    int
+	To      int
+	Type    string
+	Payload []byte
+	Seq     uint64
+	CorrID  uint64
+	SentAt  time.Duration
+	RecvAt  time.Duration
+}
+
+type TypeStat struct {
+	Delivered  int
+	Received   int
+	TotalDelay time.Duration
+	MaxDelay   time.Duration
+}
+
+func (ts TypeStat) AvgDelay() time.Duration {
+	if ts.Received == 0 {
+		return 0
+	}
+	return ts.TotalDelay / time.Duration(ts.Received)
+}
+
+type MailboxStat struct {
+	PID     int
+	Pending int
+	ByType  map[string]TypeStat
+}
+
+// Flow counts the messages delivered from one PID to another.
+type Flow struct {
+	From  int
+	To    int
+	Count int
+}
@@ -0,0 +1,293 @@
+// Package ipc holds the data structures behind GoSimOS inter-process
+// communication: typed messages, pipes and shared-memory segments. Blocking
+// and waking processes is left to the scheduler.
+package ipc
+
+import (
+	"maps"
+	"slices"
+)
+
+const DefaultPipeCap = 64
+
+// Pipe is a bounded byte buffer shared by the read and write ends of an
+// anonymous pipe or a FIFO. Blocked processes are kept as PIDs; waking them
+// is up to the scheduler.
+type Pipe struct {
+	Name      string
+	buf       []byte
+	cap       int
+	readers   int
+	writers   int
+	hadReader bool
+	hadWriter bool
+	readWait  []int
+	writeWait []int
+
+	bytesIn    int
+	bytesOut   int
+	readStall  int
+	writeStall int
+}
+
+// NewPipe creates an empty pipe; capacity 0 selects DefaultPipeCap.
+func NewPipe(name string, capacity int) *Pipe {
+	if capacity <= 0 {
+		capacity = DefaultPipeCap
+	}
+	return &Pipe{Name: name, cap: capacity}
+}
+
+func (pp *Pipe) Open(write bool) {
+	if write {
+		pp.writers++
+		pp.hadWriter = true
+	} else {
+		pp.readers++
+		pp.hadReader = true
+	}
+}
+
+func (pp *Pipe) Close(write bool) {
+	if write {
+		pp.writers--
+	} else {
+		pp.readers--
+	}
+}
+
+func (pp *Pipe) Readers() int    { return pp.readers }
+func (pp *Pipe) Writers() int    { return pp.writers }
+func (pp *Pipe) HadReader() bool { return pp.hadReader }
+func (pp *Pipe) Buffered() int   { return len(pp.buf) }
+
+// Write accepts as much of data as fits and reports how much that was.
+func (pp *Pipe) Write(data string) int {
+	n := min(len(data), pp.cap-len(pp.buf))
+	pp.buf = append(pp.buf, data[:n]...)
+	pp.bytesIn += n
+	return n
+}
+
+// Read takes up to max buffered bytes (all of them when max is 0).
+func (pp *Pipe) Read(max int) string {
+	n := len(pp.buf)
+	if max > 0 && max < n {
+		n = max
+	}
+	out := string(pp.buf[:n])
+	pp.buf = pp.buf[n:]
+	pp.bytesOut += n
+	return out
+}
+
+// EOF is true once every writer has gone and the buffer is drained.
+func (pp *Pipe) EOF() bool {
+	return len(pp.buf) == 0 && pp.hadWriter && pp.writers == 0
+}
+
+// Broken is true when nobody will ever read what is written.
+func (pp *Pipe) Broken() bool {
+	return pp.hadReader && pp.readers == 0
+}
+
+// WaitRead and WaitWrite queue a PID that found the pipe empty or full.
+func (pp *Pipe) WaitRead(pid int) {
+	pp.readStall++
+	pp.readWait = append(pp.readWait, pid)
+}
+
+func (pp *Pipe) WaitWrite(pid int) {
+	pp.writeStall++
+	pp.writeWait = append(pp.writeWait, pid)
+}
+
+// TakeReaders and TakeWriters empty a wait queue and return who was on it.
+func (pp *Pipe) TakeReaders() []int {
+	out := pp.readWait
+	pp.readWait = nil
+	return out
+}
+
+func (pp *Pipe) TakeWriters() []int {
+	out := pp.writeWait
+	pp.writeWait = nil
+	return out
+}
+
+// Forget drops pid from both wait queues.
+func (pp *Pipe) Forget(pid int) {
+	drop := func(ids []int) []int {
+		out := ids[:0]
+		for _, id := range ids {
+			if id != pid {
+				out = append(out, id)
+			}
+		}
+		return out
+	}
+	pp.readWait = drop(pp.readWait)
+	pp.writeWait = drop(pp.writeWait)
+}
+
+func (pp *Pipe) Stat() PipeStat {
+	return PipeStat{
+		Name:       pp.Name,
+		Cap:        pp.cap,
+		Buffered:   len(pp.buf),
+		Readers:    pp.readers,
+		Writers:    pp.writers,
+		BytesIn:    pp.bytesIn,
+		BytesOut:   pp.bytesOut,
+		ReadStall:  pp.readStall,
+		WriteStall: pp.writeStall,
+		Waiters:    append(append([]int{}, pp.readWait...), pp.writeWait...),
+	}
+}
+
+// PipeState is everything a Pipe holds, for checkpoints.
+type PipeState struct {
+	Name       string
+	Buf        []byte
+	Cap        int
+	Readers    int
+	Writers    int
+	HadReader  bool
+	HadWriter  bool
+	ReadWait   []int
+	WriteWait  []int
+	BytesIn    int
+	BytesOut   int
+	ReadStall  int
+	WriteStall int
+}
+
+func (pp *Pipe) State() PipeState {
+	return PipeState{
+		Name:       pp.Name,
+		Buf:        slices.Clone(pp.buf),
+		Cap:        pp.cap,
+		Readers:    pp.readers,
+		Writers:    pp.writers,
+		HadReader:  pp.hadReader,
+		HadWriter:  pp.hadWriter,
+		ReadWait:   slices.Clone(pp.readWait),
+		WriteWait:  slices.Clone(pp.writeWait),
+		BytesIn:    pp.bytesIn,
+		BytesOut:   pp.bytesOut,
+		ReadStall:  pp.readStall,
+		WriteStall: pp.writeStall,
+	}
+}
+
+func RestorePipe(st PipeState) *Pipe {
+	return &Pipe{
+		Name:       st.Name,
+		buf:        slices.Clone(st.Buf),
+		cap:        st.Cap,
+		readers:    st.Readers,
+		writers:    st.Writers,
+		hadReader:  st.HadReader,
+		hadWriter:  st.HadWriter,
+		readWait:   slices.Clone(st.ReadWait),
+		writeWait:  slices.Clone(st.WriteWait),
+		bytesIn:    st.BytesIn,
+		bytesOut:   st.BytesOut,
+		readStall:  st.ReadStall,
+		writeStall: st.WriteStall,
+	}
+}
+
+// Segment is a named shared-memory region. Access is only allowed to
+// attached PIDs; the scheduler checks that before reading or writing.
+type Segment struct {
+	Name     string
+	data     []byte
+	attached map[int]bool
+	bytesIn  int
+	bytesOut int
+}
+
+func NewSegment(name string, size int) *Segment {
+	return &Segment{Name: name, data: make([]byte, size), attached: make(map[int]bool)}
+}
+
+func (sg *Segment) Attach(pid int)          { sg.attached[pid] = true }
+func (sg *Segment) Detach(pid int)          { delete(sg.attached, pid) }
+func (sg *Segment) IsAttached(pid int) bool { return sg.attached[pid] }
+
+func (sg *Segment) Write(off int, data string) bool {
+	if off < 0 || off+len(data) > len(sg.data) {
+		return false
+	}
+	copy(sg.data[off:], data)
+	sg.bytesIn += len(data)
+	return true
+}
+
+func (sg *Segment) Read(off, n int) (string, bool) {
+	if off < 0 || n < 0 || off+n > len(sg.data) {
+		return "", false
+	}
+	sg.bytesOut += n
+	return string(sg.data[off : off+n]), true
+}
+
+func (sg *Segment) Stat() SegmentStat {
+	return SegmentStat{
+		Name:     sg.Name,
+		Size:     len(sg.data),
+		Attached: slices.Sorted(maps.Keys(sg.attached)),
+		BytesIn:  sg.bytesIn,
+		BytesOut: sg.bytesOut,
+	}
+}
+
+// SegmentState is everything a Segment holds, for checkpoints.
+type SegmentState struct {
+	Name     string
+	Data     []byte
+	Attached []int
+	BytesIn  int
+	BytesOut int
+}
+
+func (sg *Segment) State() SegmentState {
+	return SegmentState{
+		Name:     sg.Name,
+		Data:     slices.Clone(sg.data),
+		Attached: slices.Sorted(maps.Keys(sg.attached)),
+		BytesIn:  sg.bytesIn,
+		BytesOut: sg.bytesOut,
+	}
+}
+
+func RestoreSegment(st SegmentState) *Segment {
+	sg := &Segment{Name: st.Name, data: slices.Clone(st.Data), attached: make(map[int]bool),
+		bytesIn: st.BytesIn, bytesOut: st.BytesOut}
+	for _, pid := range st.Attached {
+		sg.attached[pid] = true
+	}
+	return sg
+}
+
+type PipeStat struct {
+	Name       string
+	Cap        int
+	Buffered   int
+	Readers    int
+	Writers    int
+	BytesIn    int
+	BytesOut   int
+	ReadStall  int
+	WriteStall int
+	Waiters    []int
+}
+
+type SegmentStat struct {
+	Name     string
+	Size     int
+	Attached []int
+	BytesIn  int
+	BytesOut int
+}
@@ -0,0 +1,196 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrExceedsCapacity = errors.New("declared maximum exceeds resource capacity")
+
+type pendingReq struct {
+	p    *Process
+	kind string
+	name string
+}
+
+// EnableBankers switches the kernel to Banker's-algorithm avoidance:
+// requests for resources named in a process's ProcessSpec.MaxNeed are only
+// granted when the resulting state is safe, otherwise the caller waits.
+// Claims of processes spawned before the switch are managed too.
+func (s *Scheduler) EnableBankers() {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvBankers})
+	s.bankers = true
+	for _, p := range s.procs {
+		for name := range p.maxNeed {
+			s.managed[name] = true
+		}
+	}
+}
+
+// TrySpawn is Spawn with admission control: in Banker's mode a process
+// whose declared maximum exceeds the capacity of a resource is rejected,
+// a real-time task must pass the policy's schedulability test, and the
+// process's cgroup must exist and have room.
+func (s *Scheduler) TrySpawn(spec *ProcessSpec) (int, error) {
+	s.mu.Lock()
+	if s.bankers {
+		for name, max := range spec.MaxNeed {
+			if max > s.capacityLocked(name) {
+				s.mu.Unlock()
+				return 0, fmt.Errorf("%s: %w (%s wants %d, capacity %d)",
+					spec.Name, ErrExceedsCapacity, name, max, s.capacityLocked(name))
+			}
+		}
+	}
+	s.mu.Unlock()
+	return s.spawn(spec)
+}
+
+func (s *Scheduler) capacityLocked(name string) int {
+	if sem, ok := s.sems[name]; ok {
+		return sem.total
+	}
+	return 1
+}
+
+func (s *Scheduler) availableLocked(name string) int {
+	if sem, ok := s.sems[name]; ok {
+		return sem.count
+	}
+	if m, ok := s.mutexes[name]; ok && m.owner != nil {
+		return 0
+	}
+	return 1
+}
+
+func (s *Scheduler) allocatedLocked(p *Process, name string) int {
+	if sem, ok := s.sems[name]; ok {
+		return sem.held[p.ID]
+	}
+	if m, ok := s.mutexes[name]; ok && m.owner == p {
+		return 1
+	}
+	return 0
+}
+
+// claimOKLocked enforces the declared maximum: a process that asks for more
+// than it claimed is killed, as the algorithm's invariants no longer hold.
+// One that declared nothing for name has a claim of 0 left to ask for, so
+// its request goes through the safety check and waits like any other.
+func (s *Scheduler) claimOKLocked(p *Process, name string) bool {
+	if !s.managed[name] {
+		return true
+	}
+	max, declared := p.maxNeed[name]
+	if !declared || s.allocatedLocked(p, name)+1 <= max {
+		return true
+	}
+	s.killLocked(p, fmt.Sprintf("exceeded declared maximum for %s", name))
+	return false
+}
+
+func (s *Scheduler) bankersAllowLocked(p *Process, name string) bool {
+	if !s.managed[name] {
+		return true
+	}
+	return s.safeAfterGrantLocked(p, name)
+}
+
+// safeAfterGrantLocked runs the Banker's safety algorithm on the state
+// that would result from giving one unit of name to p.
+func (s *Scheduler) safeAfterGrantLocked(p *Process, name string) bool {
+	resources := make([]string, 0, len(s.managed))
+	for r, on := range s.managed {
+		if on {
+			resources = append(resources, r)
+		}
+	}
+	sort.Strings(resources)
+
+	work := make(map[string]int, len(resources))
+	for _, r := range resources {
+		work[r] = s.availableLocked(r)
+	}
+	work[name]--
+
+	type row struct {
+		alloc map[string]int
+		need  map[string]int
+	}
+	rows := []row{}
+	for _, q := range s.procs {
+		if q.state == StateExited {
+			continue
+		}
+		rw := row{alloc: map[string]int{}, need: map[string]int{}}
+		for _, r := range resources {
+			a := s.allocatedLocked(q, r)
+			if q == p && r == name {
+				a++
+			}
+			rw.alloc[r] = a
+			if n := q.maxNeed[r] - a; n > 0 {
+				rw.need[r] = n
+			}
+		}
+		rows = append(rows, rw)
+	}
+
+	finished := make([]bool, len(rows))
+	for progress := true; progress; {
+		progress = false
+		for i, rw := range rows {
+			if finished[i] {
+				continue
+			}
+			fits := true
+			for r, n := range rw.need {
+				if n > work[r] {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				for r, a := range rw.alloc {
+					work[r] += a
+				}
+				finished[i] = true
+				progress = true
+			}
+		}
+	}
+	for _, f := range finished {
+		if !f {
+			return false
+		}
+	}
+	return true
+}
+
+// retryPendingLocked grants deferred Banker's requests, oldest first, as
+// long as some request can be satisfied safely.
+func (s *Scheduler) retryPendingLocked() {
+	for progress := true; progress; {
+		progress = false
+		for i, r := range s.pending {
+			ok := false
+			switch r.kind {
+			case "mutex":
+				ok = s.grantMutexLocked(r.p, r.name)
+			case "sem":
+				ok = s.grantSemLocked(r.p, r.name)
+			}
+			if ok {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				s.wake(r.p)
+				progress = true
+				break
+			}
+		}
+	}
+}
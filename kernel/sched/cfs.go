@@ -0,0 +1,296 @@
+package sched
+
+import (
+	"cmp"
+	"time"
+)
+
+// niceWeights maps nice -20..19 to a load weight, as in Linux: each step is
+// about 10% of CPU, and nice 0 weighs 1024.
+var niceWeights = [40]int64{
+	88761, 71755, 56483, 46273, 36291,
+	29154, 23254, 18705, 14949, 11916,
+	9548, 7620, 6100, 4904, 3906,
+	3121, 2501, 1991, 1586, 1277,
+	1024, 820, 655, 526, 423,
+	335, 272, 215, 172, 137,
+	110, 87, 70, 56, 45,
+	36, 29, 23, 18, 15,
+}
+
+const nice0Weight = 1024
+
+// Nice is the nice value CFS uses for p: its Priority, clamped to
+// [-20, 19].
+func (p *Process) Nice() int {
+	return min(max(p.Priority, -20), 19)
+}
+
+// weight is p's CFS load weight: the one for its nice value, scaled by its
+// cgroup's shares split between the group's processes.
+func (p *Process) weight() int64 {
+	w := niceWeights[p.Nice()+20]
+	if g := p.cgroup; g != nil && g.Shares > 0 {
+		w = max(w*int64(g.Shares)/nice0Weight/int64(max(g.procs, 1)), 1)
+	}
+	return w
+}
+
+// cfsLatencyLocked and cfsGranularityLocked default to six quanta and one.
+func (s *Scheduler) cfsLatencyLocked() time.Duration {
+	return cmp.Or(s.cfsLatency, 6*s.quantum)
+}
+
+func (s *Scheduler) cfsGranularityLocked() time.Duration {
+	return cmp.Or(s.cfsGranularity, s.quantum)
+}
+
+// sliceLocked is how long p, just taken off the run queue, may run: its
+// weighted share of the target latency, which stretches so that every
+// runnable process gets at least the minimum granularity.
+func (s *Scheduler) sliceLocked(p *Process) time.Duration {
+	if s.policy != PolicyCFS {
+		return s.quantum
+	}
+	gran := s.cfsGranularityLocked()
+	rq := s.runq.(*runTree)
+	period := max(s.cfsLatencyLocked(), time.Duration(rq.len()+1)*gran)
+	total := rq.load + p.weight()
+	return max(time.Duration(int64(period)*p.weight()/total), gran)
+}
+
+// chargeLocked adds ran, scaled by p's weight, to p's virtual runtime and
+// moves min_vruntime forward.
+func (s *Scheduler) chargeLocked(p *Process, ran time.Duration) {
+	if s.policy != PolicyCFS {
+		return
+	}
+	p.vruntime += time.Duration(int64(ran) * nice0Weight / p.weight())
+	low, ok := p.vruntime, p.state == StateRunning
+	if first := s.runq.peek(); first != nil && (!ok || first.vruntime < low) {
+		low, ok = first.vruntime, true
+	}
+	if ok {
+		s.minVruntime = max(s.minVruntime, low)
+	}
+}
+
+// placeLocked sets the vruntime of a process joining the run queue: a new
+// one starts at min_vruntime, a woken one keeps its own unless it slept so
+// long that it would monopolise the CPU, in which case it gets half a
+// latency of credit.
+func (s *Scheduler) placeLocked(p *Process, woken bool) {
+	if s.policy != PolicyCFS {
+		return
+	}
+	if !woken {
+		p.vruntime = s.minVruntime
+		return
+	}
+	p.vruntime = max(p.vruntime, s.minVruntime-s.cfsLatencyLocked()/2)
+}
+
+// runTree is the CFS run queue: a left-leaning red-black tree of ready
+// processes ordered by less. load is the sum of their weights.
+type runTree struct {
+	root *rbNode
+	n    int
+	load int64
+	less func(a, b *Process) bool
+}
+
+type rbNode struct {
+	p           *Process
+	left, right *rbNode
+	red         bool
+}
+
+func (t *runTree) len() int { return t.n }
+
+func (t *runTree) peek() *Process {
+	h := t.root
+	if h == nil {
+		return nil
+	}
+	for h.left != nil {
+		h = h.left
+	}
+	return h.p
+}
+
+func (t *runTree) push(p *Process) {
+	t.root = t.insertAt(t.root, p)
+	t.root.red = false
+	t.n++
+	p.rqWeight = p.weight()
+	t.load += p.rqWeight
+}
+
+func (t *runTree) insertAt(h *rbNode, p *Process) *rbNode {
+	if h == nil {
+		return &rbNode{p: p, red: true}
+	}
+	if t.less(p, h.p) {
+		h.left = t.insertAt(h.left, p)
+	} else {
+		h.right = t.insertAt(h.right, p)
+	}
+	return fixUp(h)
+}
+
+// pop removes and returns the leftmost process, nil if empty.
+func (t *runTree) pop() *Process {
+	p := t.peek()
+	if p == nil {
+		return nil
+	}
+	if !isRed(t.root.left) && !isRed(t.root.right) {
+		t.root.red = true
+	}
+	t.root = deleteMin(t.root)
+	if t.root != nil {
+		t.root.red = false
+	}
+	t.n--
+	t.load -= p.rqWeight
+	return p
+}
+
+// remove takes p out of the tree and reports whether it was there.
+func (t *runTree) remove(p *Process) bool {
+	if !t.contains(p) {
+		return false
+	}
+	if !isRed(t.root.left) && !isRed(t.root.right) {
+		t.root.red = true
+	}
+	t.root = t.removeAt(t.root, p)
+	if t.root != nil {
+		t.root.red = false
+	}
+	t.n--
+	t.load -= p.rqWeight
+	return true
+}
+
+func (t *runTree) contains(p *Process) bool {
+	for h := t.root; h != nil; {
+		switch {
+		case h.p == p:
+			return true
+		case t.less(p, h.p):
+			h = h.left
+		default:
+			h = h.right
+		}
+	}
+	return false
+}
+
+func (t *runTree) removeAt(h *rbNode, p *Process) *rbNode {
+	if h.p != p && t.less(p, h.p) {
+		if !isRed(h.left) && !isRed(h.left.left) {
+			h = moveRedLeft(h)
+		}
+		h.left = t.removeAt(h.left, p)
+		return fixUp(h)
+	}
+	if isRed(h.left) {
+		h = rotateRight(h)
+	}
+	if h.p == p && h.right == nil {
+		return nil
+	}
+	if !isRed(h.right) && !isRed(h.right.left) {
+		h = moveRedRight(h)
+	}
+	if h.p == p {
+		m := h.right
+		for m.left != nil {
+			m = m.left
+		}
+		h.p = m.p
+		h.right = deleteMin(h.right)
+	} else {
+		h.right = t.removeAt(h.right, p)
+	}
+	return fixUp(h)
+}
+
+// each calls f on every process in order.
+func (t *runTree) each(f func(*Process)) {
+	var walk func(*rbNode)
+	walk = func(h *rbNode) {
+		if h != nil {
+			walk(h.left)
+			f(h.p)
+			walk(h.right)
+		}
+	}
+	walk(t.root)
+}
+
+func isRed(h *rbNode) bool { return h != nil && h.red }
+
+func rotateLeft(h *rbNode) *rbNode {
+	x := h.right
+	h.right, x.left = x.left, h
+	x.red, h.red = h.red, true
+	return x
+}
+
+func rotateRight(h *rbNode) *rbNode {
+	x := h.left
+	h.left, x.right = x.right, h
+	x.red, h.red = h.red, true
+	return x
+}
+
+func flipColors(h *rbNode) {
+	h.red = !h.red
+	h.left.red = !h.left.red
+	h.right.red = !h.right.red
+}
+
+func fixUp(h *rbNode) *rbNode {
+	if isRed(h.right) && !isRed(h.left) {
+		h = rotateLeft(h)
+	}
+	if isRed(h.left) && isRed(h.left.left) {
+		h = rotateRight(h)
+	}
+	if isRed(h.left) && isRed(h.right) {
+		flipColors(h)
+	}
+	return h
+}
+
+func moveRedLeft(h *rbNode) *rbNode {
+	flipColors(h)
+	if isRed(h.right.left) {
+		h.right = rotateRight(h.right)
+		h = rotateLeft(h)
+		flipColors(h)
+	}
+	return h
+}
+
+func moveRedRight(h *rbNode) *rbNode {
+	flipColors(h)
+	if isRed(h.left.left) {
+		h = rotateRight(h)
+		flipColors(h)
+	}
+	return h
+}
+
+func deleteMin(h *rbNode) *rbNode {
+	if h.left == nil {
+		return nil
+	}
+	if !isRed(h.left) && !isRed(h.left.left) {
+		h = moveRedLeft(h)
+	}
+	h.left = deleteMin(h.left)
+	return fixUp(h)
+}
@@ -0,0 +1,165 @@
+package sched
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// checkRB verifies the left-leaning red-black invariants and returns the
+// black height of h.
+func checkRB(t *testing.T, h *rbNode, less func(a, b *Process) bool) int {
+	t.Helper()
+	if h == nil {
+		return 1
+	}
+	if isRed(h.right) || (isRed(h) && isRed(h.left)) {
+		t.Fatalf("red link violation at PID %d", h.p.ID)
+	}
+	if (h.left != nil && less(h.p, h.left.p)) || (h.right != nil && less(h.right.p, h.p)) {
+		t.Fatalf("order violation at PID %d", h.p.ID)
+	}
+	l, r := checkRB(t, h.left, less), checkRB(t, h.right, less)
+	if l != r {
+		t.Fatalf("black height %d != %d at PID %d", l, r, h.p.ID)
+	}
+	if !h.red {
+		l++
+	}
+	return l
+}
+
+func TestRunTreeMatchesSortedSlice(t *testing.T) {
+	less := func(a, b *Process) bool {
+		if a.vruntime != b.vruntime {
+			return a.vruntime < b.vruntime
+		}
+		return a.ID < b.ID
+	}
+	tree := runTree{less: less}
+	var want []*Process
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 1; i <= 2000; i++ {
+		switch op := r.IntN(4); {
+		case op < 2 || len(want) == 0:
+			p := &Process{ID: i, vruntime: time.Duration(r.IntN(50))}
+			tree.push(p)
+			want = append(want, p)
+		case op == 2:
+			victim := want[r.IntN(len(want))]
+			if !tree.remove(victim) {
+				t.Fatalf("PID %d not found", victim.ID)
+			}
+			want = slices.DeleteFunc(want, func(p *Process) bool { return p == victim })
+		default:
+			slices.SortFunc(want, func(a, b *Process) int {
+				if less(a, b) {
+					return -1
+				}
+				return 1
+			})
+			if got := tree.pop(); got != want[0] {
+				t.Fatalf("pop = PID %d, want %d", got.ID, want[0].ID)
+			}
+			want = want[1:]
+		}
+		checkRB(t, tree.root, less)
+	}
+	slices.SortFunc(want, func(a, b *Process) int {
+		if less(a, b) {
+			return -1
+		}
+		return 1
+	})
+	var got []*Process
+	tree.each(func(p *Process) { got = append(got, p) })
+	if !slices.Equal(got, want) || tree.len() != len(want) {
+		t.Fatalf("in-order walk has %d processes, want %d", len(got), len(want))
+	}
+	if tree.remove(&Process{ID: -1}) {
+		t.Fatal("removed a process that was never inserted")
+	}
+}
+
+func newCFSScheduler() *Scheduler {
+	return NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond),
+		WithPolicy(PolicyCFS), WithCFS(6*time.Millisecond, time.Millisecond))
+}
+
+func TestCFSSharesCPUByWeight(t *testing.T) {
+	s := newCFSScheduler()
+	for _, nice := range []int{0, 0, 5} {
+		s.Spawn(&ProcessSpec{Name: "hog", Priority: nice, WorkUnits: 1000})
+	}
+	s.Step(60)
+	stats := s.Stats()
+	done := make([]int, len(stats))
+	for i, st := range stats {
+		done[i] = 1000 - st.Remaining
+	}
+	// Weights 1024, 1024 and 335: the nice-5 process gets about a third
+	// of what each nice-0 one gets.
+	if d := done[0] - done[1]; d > 3 || d < -3 || done[2]*2 > done[0] || done[2]*4 < done[0] {
+		t.Errorf("units run = %v, want two equal shares and about a third of one", done)
+	}
+	for _, st := range stats[1:] {
+		if d := st.VRuntime - stats[0].VRuntime; d > 6*time.Millisecond || d < -6*time.Millisecond {
+			t.Errorf("PID %d vruntime %v is more than a latency from %v", st.ID, st.VRuntime, stats[0].VRuntime)
+		}
+	}
+}
+
+func TestCFSSliceFollowsLatencyAndGranularity(t *testing.T) {
+	s := newCFSScheduler()
+	for i := 0; i < 2; i++ {
+		s.Spawn(&ProcessSpec{Name: "hog", WorkUnits: 100})
+	}
+	var lens []int
+	for i := 0; i < 6; i++ {
+		before := s.Now()
+		s.Step(1)
+		lens = append(lens, int((s.Now()-before)/time.Millisecond))
+	}
+	// Two equal processes split the 6ms latency into 3ms slices.
+	if !slices.Equal(lens, []int{3, 3, 3, 3, 3, 3}) {
+		t.Errorf("slices with 2 ready = %v", lens)
+	}
+
+	s = newCFSScheduler()
+	for i := 0; i < 10; i++ {
+		s.Spawn(&ProcessSpec{Name: "hog", WorkUnits: 100})
+	}
+	before := s.Now()
+	s.Step(10)
+	// Ten processes stretch the period to 10ms: one granule each.
+	if got := s.Now() - before; got != 10*time.Millisecond {
+		t.Errorf("10 dispatches with 10 ready took %v, want 10ms", got)
+	}
+	for _, st := range s.Stats() {
+		if st.RunCount != 1 {
+			t.Errorf("PID %d ran %d times in the first period", st.ID, st.RunCount)
+		}
+	}
+}
+
+func TestCFSSleeperDoesNotMonopolise(t *testing.T) {
+	s := newCFSScheduler()
+	s.NewSemaphore("go", 0)
+	s.Spawn(&ProcessSpec{Name: "hog", WorkUnits: 500})
+	sleeper := s.Spawn(&ProcessSpec{Name: "sleeper", Program: []Op{SemWait("go"), Compute(100)}})
+	s.Spawn(&ProcessSpec{Name: "waker", Program: []Op{Compute(60), SemPost("go")}})
+	for s.Stats()[sleeper-1].State != StateBlocked {
+		s.Step(1)
+	}
+	for s.Stats()[sleeper-1].State == StateBlocked {
+		s.Step(1)
+	}
+	s.Step(10)
+	// Woken with half a latency of credit, the sleeper catches up within a
+	// slice and then takes turns with the hog instead of running until its
+	// vruntime reaches the hog's.
+	if ran := 100 - s.Stats()[sleeper-1].Remaining; ran > 20 {
+		t.Errorf("sleeper ran %d of the 10 dispatches' 30 units after waking", ran)
+	}
+}
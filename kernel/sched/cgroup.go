@@ -0,0 +1,276 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	ErrNoCgroup  = errors.New("no such cgroup")
+	ErrPidsLimit = errors.New("cgroup process limit reached")
+)
+
+// CgroupSpec describes a control group. Zero fields mean no limit.
+type CgroupSpec struct {
+	Name string
+	// Shares is the group's CPU weight under PolicyCFS, relative to 1024
+	// for processes outside any group and spread over its live processes.
+	Shares int
+	// Quota is how much CPU time the group may use per Period (default ten
+	// quanta) under any policy; past it, its processes wait for the next
+	// period.
+	Quota  time.Duration
+	Period time.Duration
+	// MemLimit caps the group's resident memory in bytes. Going over it
+	// OOM-kills every process in the group.
+	MemLimit int
+	// PidsMax caps the group's live processes; spawns past it fail.
+	PidsMax int
+}
+
+// CgroupStat is a group's limits and what its processes used.
+type CgroupStat struct {
+	CgroupSpec
+	Procs         int
+	CPU           time.Duration // simulated CPU time
+	Throttled     int           // periods in which processes waited for quota
+	ThrottledTime time.Duration
+	Memory        int
+	MemPeak       int
+	OOMKills      int // processes killed for going over MemLimit
+	Rejected      int // spawns refused by PidsMax
+}
+
+type cgroup struct {
+	CgroupSpec
+	procs       int
+	periodStart time.Duration
+	used        time.Duration // CPU time in the current period
+	throttled   bool          // quota used up for this period
+	throttledAt time.Duration // when the first process was parked
+	parked      []*Process    // ready while throttled, in enqueue order
+
+	cpu           time.Duration
+	nrThrottled   int
+	throttledTime time.Duration
+	memory        int
+	memPeak       int
+	oomKills      int
+	rejected      int
+}
+
+// NewCgroup creates a control group that processes can be spawned into
+// with ProcessSpec.Cgroup.
+func (s *Scheduler) NewCgroup(spec CgroupSpec) error {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case spec.Name == "":
+		return errors.New("cgroup needs a name")
+	case s.cgroupLocked(spec.Name) != nil:
+		return fmt.Errorf("cgroup %s already exists", spec.Name)
+	case spec.Shares < 0 || spec.Quota < 0 || spec.Period < 0 || spec.MemLimit < 0 || spec.PidsMax < 0:
+		return fmt.Errorf("cgroup %s: negative limit", spec.Name)
+	}
+	if spec.Period == 0 {
+		spec.Period = 10 * s.quantum
+	}
+	s.recordLocked(Event{Kind: EvCgrp, Cgroup: &spec})
+	s.cgroups = append(s.cgroups, &cgroup{CgroupSpec: spec, periodStart: s.now})
+	s.klogLocked(LogInfo, "cgroup", 0, "created %s", spec.Name)
+	return nil
+}
+
+func (s *Scheduler) cgroupLocked(name string) *cgroup {
+	for _, g := range s.cgroups {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// joinCgroupLocked admits a new process into the group spec names, or
+// into inherit, its parent's, when spec names none.
+func (s *Scheduler) joinCgroupLocked(spec *ProcessSpec, inherit *cgroup) (*cgroup, error) {
+	g := inherit
+	if spec.Cgroup != "" {
+		if g = s.cgroupLocked(spec.Cgroup); g == nil {
+			return nil, fmt.Errorf("%s: %w %q", spec.Name, ErrNoCgroup, spec.Cgroup)
+		}
+	}
+	if g == nil {
+		return nil, nil
+	}
+	if g.PidsMax > 0 && g.procs >= g.PidsMax {
+		g.rejected++
+		return nil, fmt.Errorf("%s: %w (%s has %d)", spec.Name, ErrPidsLimit, g.Name, g.procs)
+	}
+	g.procs++
+	return g, nil
+}
+
+// leaveCgroupLocked releases what an exiting process held in its group.
+func (s *Scheduler) leaveCgroupLocked(p *Process) {
+	if g := p.cgroup; g != nil {
+		g.procs--
+		g.memory -= p.mem
+	}
+	p.mem = 0
+}
+
+// memChargeLocked adds n bytes (negative to free) to p's resident memory,
+// OOM-kills p's group if that takes it over its limit and swaps others
+// out if it takes the system over physical memory.
+func (s *Scheduler) memChargeLocked(p *Process, n int) {
+	n = max(n, -p.mem)
+	p.mem += n
+	if n > 0 {
+		defer s.memPressureLocked(p)
+	}
+	g := p.cgroup
+	if g == nil {
+		return
+	}
+	g.memory += n
+	g.memPeak = max(g.memPeak, g.memory)
+	if g.MemLimit > 0 && g.memory > g.MemLimit {
+		reason := fmt.Sprintf("oom-killed: cgroup %s over %d bytes", g.Name, g.MemLimit)
+		for _, pid := range slices.Sorted(maps.Keys(s.procs)) {
+			if q := s.procs[pid]; q.cgroup == g && q.state != StateExited && q.exitReason == "" {
+				g.oomKills++
+				s.klogLocked(LogErr, "mm", q.ID, "out of memory in cgroup %s (%d of %d bytes), killing %q", g.Name, g.memory, g.MemLimit, q.Name)
+				s.killLocked(q, reason)
+			}
+		}
+	}
+}
+
+// memAlloc is a process allocating (or, with n < 0, freeing) memory. It
+// reports false if that got the process OOM-killed.
+func (s *Scheduler) memAlloc(p *Process, n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.memChargeLocked(p.proc(), n)
+	return p.exitReason == ""
+}
+
+// cgroupSliceLocked caps slice at what is left of p's group quota.
+func (s *Scheduler) cgroupSliceLocked(p *Process, slice time.Duration) time.Duration {
+	if g := p.cgroup; g != nil && g.Quota > 0 {
+		return max(min(slice, g.Quota-g.used), 0)
+	}
+	return slice
+}
+
+// cgroupChargeLocked bills ran to p's group and throttles the group if
+// that used up its quota: its ready processes are parked until the next
+// period.
+func (s *Scheduler) cgroupChargeLocked(p *Process, ran time.Duration) {
+	g := p.cgroup
+	if g == nil {
+		return
+	}
+	g.cpu += ran
+	if g.Quota == 0 {
+		return
+	}
+	s.cgroupTickLocked()
+	g.used += ran
+	if g.used < g.Quota || g.throttled {
+		return
+	}
+	g.throttled = true
+	s.klogLocked(LogDebug, "cgroup", 0, "%s throttled: used %v of its %v quota", g.Name, g.used, g.Quota)
+	for _, q := range s.readyListLocked() {
+		if q.cgroup == g {
+			s.runq.remove(q)
+			g.park(q, s.now)
+		}
+	}
+}
+
+func (g *cgroup) park(p *Process, now time.Duration) {
+	if len(g.parked) == 0 {
+		g.nrThrottled++
+		g.throttledAt = now
+	}
+	g.parked = append(g.parked, p)
+}
+
+// cgroupTickLocked starts a new quota period for every group whose period
+// has ended, putting the processes of throttled groups back in the ready
+// queue.
+func (s *Scheduler) cgroupTickLocked() {
+	for _, g := range s.cgroups {
+		if g.Quota == 0 || s.now < g.periodStart+g.Period {
+			continue
+		}
+		g.periodStart += (s.now - g.periodStart) / g.Period * g.Period
+		g.used = 0
+		g.throttled = false
+		if len(g.parked) > 0 {
+			g.throttledTime += s.now - g.throttledAt
+			for _, q := range g.parked {
+				s.runq.push(q)
+			}
+			g.parked = nil
+		}
+	}
+}
+
+// nextUnthrottleLocked is when the first throttled group gets new quota.
+func (s *Scheduler) nextUnthrottleLocked() (time.Duration, bool) {
+	next, ok := time.Duration(math.MaxInt64), false
+	for _, g := range s.cgroups {
+		if len(g.parked) > 0 {
+			next, ok = min(next, g.periodStart+g.Period), true
+		}
+	}
+	return next, ok
+}
+
+// unparkLocked takes p off its group's parked list, for a kill.
+func (s *Scheduler) unparkLocked(p *Process) bool {
+	g := p.cgroup
+	if g == nil {
+		return false
+	}
+	i := slices.Index(g.parked, p)
+	if i < 0 {
+		return false
+	}
+	g.parked = slices.Delete(g.parked, i, i+1)
+	return true
+}
+
+// CgroupStats reports every control group in creation order.
+func (s *Scheduler) CgroupStats() []CgroupStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]CgroupStat, 0, len(s.cgroups))
+	for _, g := range s.cgroups {
+		tt := g.throttledTime
+		if len(g.parked) > 0 {
+			tt += s.now - g.throttledAt
+		}
+		out = append(out, CgroupStat{
+			CgroupSpec:    g.CgroupSpec,
+			Procs:         g.procs,
+			CPU:           g.cpu,
+			Throttled:     g.nrThrottled,
+			ThrottledTime: tt,
+			Memory:        g.memory,
+			MemPeak:       g.memPeak,
+			OOMKills:      g.oomKills,
+			Rejected:      g.rejected,
+		})
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package sched
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCgroupQuotaThrottles(t *testing.T) {
+	s := newTestScheduler()
+	ms := time.Millisecond
+	if err := s.NewCgroup(CgroupSpec{Name: "capped", Quota: 2 * ms, Period: 10 * ms}); err != nil {
+		t.Fatal(err)
+	}
+	capped := s.Spawn(&ProcessSpec{Name: "capped", Cgroup: "capped", WorkUnits: 10})
+	free := s.Spawn(&ProcessSpec{Name: "free", WorkUnits: 4})
+	runToEnd(s)
+
+	// 2ms of every 10ms period: the last 2ms run in the fifth period, and
+	// the ungrouped process fills the gaps until it is done. The first
+	// period is shared with it, so the quota runs out at 3ms; the fifth
+	// ends with nothing left to park and does not count.
+	if got := s.Now(); got != 42*ms {
+		t.Errorf("finished at %v, want 42ms", got)
+	}
+	st := s.CgroupStats()[0]
+	if st.CPU != 10*ms || st.Throttled != 4 || st.ThrottledTime != 31*ms {
+		t.Errorf("stats = %+v, want 10ms CPU, throttled 4 times for 31ms", st)
+	}
+	stats := s.Stats()
+	if stats[capped-1].State != StateExited || stats[free-1].State != StateExited {
+		t.Errorf("not everything finished: %+v", stats)
+	}
+}
+
+func TestCgroupPidsMax(t *testing.T) {
+	s := newTestScheduler()
+	if err := s.NewCgroup(CgroupSpec{Name: "small", PidsMax: 2}); err != nil {
+		t.Fatal(err)
+	}
+	child := &ProcessSpec{Name: "child", WorkUnits: 1}
+	s.Spawn(&ProcessSpec{Name: "parent", Cgroup: "small", Program: []Op{SpawnChild(child), SpawnChild(child), Compute(1)}})
+	runToEnd(s)
+	st := s.CgroupStats()[0]
+	if len(s.Stats()) != 2 || st.Rejected != 1 || st.Procs != 0 {
+		t.Errorf("%d processes, stats %+v: want the second fork refused", len(s.Stats()), st)
+	}
+
+	if _, err := s.TrySpawn(&ProcessSpec{Name: "x", Cgroup: "small", WorkUnits: 5}); err != nil {
+		t.Fatal(err)
+	}
+	s.Spawn(&ProcessSpec{Name: "y", Cgroup: "small", WorkUnits: 5})
+	if _, err := s.TrySpawn(&ProcessSpec{Name: "z", Cgroup: "small"}); !errors.Is(err, ErrPidsLimit) {
+		t.Errorf("third spawn: %v, want ErrPidsLimit", err)
+	}
+	if pid := s.Spawn(&ProcessSpec{Name: "z", Cgroup: "missing"}); pid != 0 {
+		t.Errorf("spawned PID %d into a missing cgroup", pid)
+	}
+	if _, err := s.TrySpawn(&ProcessSpec{Name: "z", Cgroup: "missing"}); !errors.Is(err, ErrNoCgroup) {
+		t.Errorf("missing cgroup: %v", err)
+	}
+}
+
+func TestCgroupOOMKillsGroup(t *testing.T) {
+	s := newTestScheduler()
+	if err := s.NewCgroup(CgroupSpec{Name: "web", MemLimit: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	idle := s.Spawn(&ProcessSpec{Name: "idle", Cgroup: "web", Memory: 400, Program: []Op{Receive("never")}})
+	leaky := s.Spawn(&ProcessSpec{Name: "leaky", Cgroup: "web", Memory: 400, Program: Repeat(5, Compute(1), Alloc(100))})
+	other := s.Spawn(&ProcessSpec{Name: "other", Memory: 5000, WorkUnits: 3})
+	runToEnd(s)
+
+	stats := s.Stats()
+	for _, pid := range []int{idle, leaky} {
+		if st := stats[pid-1]; st.State != StateExited || !strings.HasPrefix(st.ExitReason, "oom-killed") {
+			t.Errorf("%s: %v %q, want oom-killed", st.Name, st.State, st.ExitReason)
+		}
+	}
+	if st := stats[other-1]; st.ExitReason != "" {
+		t.Errorf("process outside the group was killed: %q", st.ExitReason)
+	}
+	// The third allocation takes the group to 1100 bytes.
+	if st := s.CgroupStats()[0]; st.OOMKills != 2 || st.MemPeak != 1100 || st.Memory != 0 || st.Procs != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestCgroupSharesUnderCFS(t *testing.T) {
+	s := newCFSScheduler()
+	if err := s.NewCgroup(CgroupSpec{Name: "team", Shares: 1024}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		s.Spawn(&ProcessSpec{Name: "member", Cgroup: "team", WorkUnits: 1000})
+	}
+	solo := s.Spawn(&ProcessSpec{Name: "solo", WorkUnits: 1000})
+	s.Step(80)
+
+	// The group as a whole weighs as much as the one process outside it.
+	team := s.CgroupStats()[0].CPU
+	alone := time.Duration(1000-s.Stats()[solo-1].Remaining) * time.Millisecond
+	if d := team - alone; d > 6*time.Millisecond || d < -6*time.Millisecond {
+		t.Errorf("team got %v, solo %v: want equal halves", team, alone)
+	}
+}
@@ -0,0 +1,813 @@
+package sched
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"time"
+
+	"gosimos/fs"
+	"gosimos/ipc"
+)
+
+const snapshotVersion = 1
+
+var ErrSnapshot = errors.New("bad snapshot")
+
+// Snapshot is the complete state of a simulation between two dispatches.
+// Processes and kernel objects refer to each other by PID, descriptors to
+// pipes by their index in Pipes. Breakpoints and whether the loop was
+// running are debugger state and are not saved.
+type Snapshot struct {
+	Version    int
+	Quantum    time.Duration
+	Policy     Policy
+	CFS        [2]time.Duration // target latency, min granularity
+	Unit       time.Duration
+	Now        time.Duration
+	Dispatches int
+	Switches   int
+	LastPID    int
+	LoadAvg    [3]float64
+	LoadAt     time.Duration
+	MinVrun    time.Duration
+	NextPID    int
+	MsgSeq     uint64
+	PipeSeq    int
+	RNG        []byte
+
+	Ready     []int
+	Procs     []procSnap
+	Mailboxes map[int][]ipc.Message
+	MailStats map[int]map[string]ipc.TypeStat
+	Flows     []ipc.Flow
+	Groups    map[string][]int
+
+	Files    map[string]string
+	FSInfo   map[string]fs.Info
+	FSOps    map[string]int
+	Fifos    map[string]int
+	Pipes    []ipc.PipeState
+	Segments []ipc.SegmentState
+
+	Mutexes []mutexSnap
+	Sems    []semSnap
+	Conds   []condSnap
+	Flocks  []flockSnap
+	Cgroups []cgroupSnap
+	Users   []User
+	Pending []pendingSnap
+
+	Devices    DeviceConfig
+	IRQs       []irq
+	IRQSeq     uint64
+	IRQStats   [numDevices]irqCount
+	DiskBusy   time.Duration
+	TTYBuf     string
+	TTYWaiters []int
+
+	Swap      SwapConfig
+	SwapQueue []int
+	SwapStats swapCount
+
+	SyscallCosts map[Sysno]time.Duration
+	SysStats     [numSyscalls]sysCount
+
+	Managed map[string]bool
+	Bankers bool
+
+	DeadlockEvery   int
+	DeadlockRecover bool
+	Deadlocks       []DeadlockReport
+	ReportedCycles  []string
+
+	ReadyWait Histogram
+	BlockWait Histogram
+
+	Log     []LogEntry
+	LogSize int
+	LogSeq  uint64
+}
+
+type procSnap struct {
+	ID         int
+	Name       string
+	Priority   int
+	Behavior   Behavior
+	Program    []Op
+	WorkUnits  int
+	TotalUnits int
+	RunCount   int
+	TotalCPU   time.Duration
+	CPUTime    time.Duration
+	State      ProcState
+	WaitingOn  string
+	ExitReason string
+	MaxNeed    map[string]int
+	Parent     int
+	Fds        []fdSnap
+	RT         *rtState
+	VRuntime   time.Duration
+	Cgroup     string
+	Memory     int
+	User       string
+	Cred       fs.Cred
+	IO         *ioReq
+	Swap       procSwap
+	Syscalls   map[Sysno]int
+	SysTime    time.Duration
+	Strace     []SyscallRecord
+	Recv       *recvSnap
+	SpawnedAt  time.Duration
+	ReadyAt    time.Duration
+	BlockedAt  time.Duration
+	Leader     int // main thread, for a kernel thread; it has no Fds of its own
+	Model      ThreadModel
+	UThreads   []uthread
+	UCur       int
+
+	PC       int
+	OpLeft   int
+	Acc      string
+	LastMsg  *ipc.Message
+	CallSeq  uint64
+	SysOp    *Op
+	FSWrites []string
+}
+
+type fdSnap struct {
+	Fd    int
+	Pipe  int
+	Write bool
+}
+
+type recvSnap struct {
+	Type string
+	Corr uint64
+}
+
+type mutexSnap struct {
+	Name      string
+	Owner     int
+	Waiters   []int
+	Acquires  int
+	Contended int
+	Misuses   int
+}
+
+type semSnap struct {
+	Name      string
+	Count     int
+	Total     int
+	Held      map[int]int
+	Waiters   []int
+	Acquires  int
+	Contended int
+}
+
+type condSnap struct {
+	Name    string
+	Waiters []condWaiterSnap
+	Waits   int
+	Signals int
+}
+
+type condWaiterSnap struct {
+	PID   int
+	Mutex string
+}
+
+type cgroupSnap struct {
+	CgroupSpec
+	Procs         int
+	PeriodStart   time.Duration
+	Used          time.Duration
+	Throttled     bool
+	ThrottledAt   time.Duration
+	Parked        []int
+	CPU           time.Duration
+	NrThrottled   int
+	ThrottledTime time.Duration
+	Memory        int
+	MemPeak       int
+	OOMKills      int
+	Rejected      int
+}
+
+type flockSnap struct {
+	Name      string
+	Readers   []int
+	Writer    int
+	Waiters   []flockWaiterSnap
+	Acquires  int
+	Contended int
+	Misuses   int
+}
+
+type flockWaiterSnap struct {
+	PID  int
+	Mode LockMode
+}
+
+type pendingSnap struct {
+	PID  int
+	Kind string
+	Name string
+}
+
+func pidOf(p *Process) int {
+	if p == nil {
+		return 0
+	}
+	return p.ID
+}
+
+// Checkpoint captures the simulation between two dispatches. It waits for
+// the quantum in flight, if any, so it is safe while the loop is running.
+func (s *Scheduler) Checkpoint() *Snapshot {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpointLocked()
+}
+
+// checkpointLocked is Checkpoint for callers holding s.exec and s.mu.
+func (s *Scheduler) checkpointLocked() *Snapshot {
+	rng, _ := s.rng.MarshalBinary()
+	snap := &Snapshot{
+		Version:    snapshotVersion,
+		Quantum:    s.quantum,
+		Policy:     s.policy,
+		CFS:        [2]time.Duration{s.cfsLatency, s.cfsGranularity},
+		Unit:       s.unit,
+		Now:        s.now,
+		Dispatches: s.dispatches,
+		Switches:   s.switches,
+		LastPID:    s.lastPID,
+		LoadAvg:    s.loadAvg,
+		LoadAt:     s.loadAt,
+		MinVrun:    s.minVruntime,
+		NextPID:    s.nextPID,
+		MsgSeq:     s.msgSeq,
+		PipeSeq:    s.pipeSeq,
+		RNG:        rng,
+
+		Ready:     pids(s.readyListLocked()),
+		Mailboxes: make(map[int][]ipc.Message, len(s.mailboxes)),
+		MailStats: make(map[int]map[string]ipc.TypeStat, len(s.mailStats)),
+		Flows:     s.flowsLocked(),
+		Groups:    make(map[string][]int, len(s.groups)),
+
+		Files:  s.fs.Dump(),
+		FSInfo: s.fs.Infos(),
+		FSOps:  s.fs.Ops(),
+		Fifos:  make(map[string]int),
+
+		Managed: maps.Clone(s.managed),
+		Bankers: s.bankers,
+
+		DeadlockEvery:   s.deadlockEvery,
+		DeadlockRecover: s.deadlockRecover,
+		Deadlocks:       append([]DeadlockReport(nil), s.deadlocks...),
+		ReportedCycles:  slices.Sorted(maps.Keys(s.reportedCycles)),
+
+		Devices:    s.devices,
+		IRQs:       slices.Clone(s.irqs),
+		IRQSeq:     s.irqSeq,
+		IRQStats:   s.irqStats,
+		DiskBusy:   s.diskBusy,
+		TTYBuf:     s.ttyBuf,
+		TTYWaiters: pids(s.ttyWaiters),
+
+		Swap:      s.swap,
+		SwapQueue: pids(s.swapQ),
+		SwapStats: s.swapStats,
+
+		SyscallCosts: maps.Clone(s.sysCosts),
+		SysStats:     s.sysStats,
+
+		ReadyWait: s.readyWait.clone(),
+		BlockWait: s.blockWait.clone(),
+
+		Log:     slices.Clone(s.klog),
+		LogSize: s.klogSize,
+		LogSeq:  s.klogSeq,
+	}
+	for i, ev := range snap.IRQs {
+		if ev.Msg != nil {
+			m := cloneMessage(*ev.Msg)
+			snap.IRQs[i].Msg = &m
+		}
+	}
+
+	pipeIdx := make(map[*ipc.Pipe]int, len(s.pipes))
+	for i, pp := range s.pipes {
+		pipeIdx[pp] = i
+		snap.Pipes = append(snap.Pipes, pp.State())
+	}
+	for path, pp := range s.fs.Fifos() {
+		snap.Fifos[path] = pipeIdx[pp]
+	}
+
+	for _, pid := range slices.Sorted(maps.Keys(s.procs)) {
+		p := s.procs[pid]
+		ps := procSnap{
+			ID:         p.ID,
+			Name:       p.Name,
+			Priority:   p.Priority,
+			Behavior:   p.Behavior,
+			Program:    p.Program,
+			WorkUnits:  p.WorkUnits,
+			TotalUnits: p.totalUnits,
+			RunCount:   p.RunCount,
+			TotalCPU:   p.TotalCPU,
+			CPUTime:    p.cpuTime,
+			State:      p.state,
+			WaitingOn:  p.waitingOn,
+			ExitReason: p.exitReason,
+			MaxNeed:    maps.Clone(p.maxNeed),
+			Parent:     p.parentID,
+			SpawnedAt:  p.spawnedAt,
+			ReadyAt:    p.readyAt,
+			RT:         cloneRT(p.rt),
+			VRuntime:   p.vruntime,
+			Cgroup:     p.cgroupName(),
+			Memory:     p.mem,
+			User:       p.user,
+			Cred:       cloneCred(p.cred),
+			IO:         cloneIO(p.io),
+			Swap:       p.swap,
+			Syscalls:   maps.Clone(p.sysCalls),
+			SysTime:    p.sysTime,
+			Strace:     slices.Clone(p.strace),
+			BlockedAt:  p.blockedAt,
+			PC:         p.pc,
+			OpLeft:     p.opLeft,
+			Acc:        p.acc,
+			CallSeq:    p.callSeq,
+			SysOp:      cloneOp(p.sysOp),
+			FSWrites:   slices.Clone(p.fsWrites),
+			Leader:     pidOf(p.leader),
+			Model:      p.model,
+			UCur:       p.ucur,
+		}
+		for _, t := range p.uts {
+			ps.UThreads = append(ps.UThreads, *t)
+		}
+		for _, fd := range slices.Sorted(maps.Keys(p.fds)) {
+			if p.leader != nil {
+				break
+			}
+			d := p.fds[fd]
+			ps.Fds = append(ps.Fds, fdSnap{Fd: fd, Pipe: pipeIdx[d.pipe], Write: d.write})
+		}
+		if p.recv != nil {
+			ps.Recv = &recvSnap{Type: p.recv.typ, Corr: p.recv.corr}
+		}
+		if p.lastMsg != nil {
+			m := cloneMessage(*p.lastMsg)
+			ps.LastMsg = &m
+		}
+		snap.Procs = append(snap.Procs, ps)
+	}
+
+	for pid, box := range s.mailboxes {
+		out := make([]ipc.Message, 0, len(box))
+		for _, m := range box {
+			out = append(out, cloneMessage(m))
+		}
+		snap.Mailboxes[pid] = out
+	}
+	for pid, byType := range s.mailStats {
+		out := make(map[string]ipc.TypeStat, len(byType))
+		for typ, ts := range byType {
+			out[typ] = *ts
+		}
+		snap.MailStats[pid] = out
+	}
+	for name, members := range s.groups {
+		snap.Groups[name] = slices.Sorted(maps.Keys(members))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.segments)) {
+		snap.Segments = append(snap.Segments, s.segments[name].State())
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.mutexes)) {
+		m := s.mutexes[name]
+		snap.Mutexes = append(snap.Mutexes, mutexSnap{
+			Name: name, Owner: pidOf(m.owner), Waiters: pids(m.waiters),
+			Acquires: m.acquires, Contended: m.contended, Misuses: m.misuses,
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.sems)) {
+		sem := s.sems[name]
+		snap.Sems = append(snap.Sems, semSnap{
+			Name: name, Count: sem.count, Total: sem.total, Held: maps.Clone(sem.held),
+			Waiters: pids(sem.waiters), Acquires: sem.acquires, Contended: sem.contended,
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.conds)) {
+		c := s.conds[name]
+		cs := condSnap{Name: name, Waits: c.waits, Signals: c.signals}
+		for _, w := range c.waiters {
+			cs.Waiters = append(cs.Waiters, condWaiterSnap{PID: w.p.ID, Mutex: w.mutex})
+		}
+		snap.Conds = append(snap.Conds, cs)
+	}
+	for _, path := range slices.Sorted(maps.Keys(s.flocks)) {
+		l := s.flocks[path]
+		fl := flockSnap{
+			Name: path, Readers: slices.Sorted(maps.Keys(l.readers)), Writer: pidOf(l.writer),
+			Acquires: l.acquires, Contended: l.contended, Misuses: l.misuses,
+		}
+		for _, w := range l.waiters {
+			fl.Waiters = append(fl.Waiters, flockWaiterSnap{PID: w.p.ID, Mode: w.mode})
+		}
+		snap.Flocks = append(snap.Flocks, fl)
+	}
+	for _, r := range s.pending {
+		snap.Pending = append(snap.Pending, pendingSnap{PID: r.p.ID, Kind: r.kind, Name: r.name})
+	}
+	for _, u := range s.users {
+		u.Groups = slices.Clone(u.Groups)
+		snap.Users = append(snap.Users, u)
+	}
+	for _, g := range s.cgroups {
+		snap.Cgroups = append(snap.Cgroups, cgroupSnap{
+			CgroupSpec: g.CgroupSpec, Procs: g.procs, PeriodStart: g.periodStart, Used: g.used,
+			Throttled: g.throttled, ThrottledAt: g.throttledAt, Parked: pids(g.parked),
+			CPU: g.cpu, NrThrottled: g.nrThrottled, ThrottledTime: g.throttledTime,
+			Memory: g.memory, MemPeak: g.memPeak, OOMKills: g.oomKills, Rejected: g.rejected,
+		})
+	}
+	return snap
+}
+
+func cloneMessage(m ipc.Message) ipc.Message {
+	m.Payload = slices.Clone(m.Payload)
+	return m
+}
+
+// SaveCheckpoint writes a checkpoint of s to path as JSON.
+func (s *Scheduler) SaveCheckpoint(path string) error {
+	data, err := json.MarshalIndent(s.Checkpoint(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadCheckpoint reads a snapshot written by SaveCheckpoint and restores it.
+func LoadCheckpoint(path string) (*Scheduler, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return Restore(&snap)
+}
+
+// Fork returns an independent copy of s that continues from the same
+// point, for trying out what-if variants of a run.
+func (s *Scheduler) Fork() (*Scheduler, error) {
+	return Restore(s.Checkpoint())
+}
+
+// Restore builds a stopped scheduler from snap. Continuing it with Start
+// or Step gives the same result as the run the snapshot was taken from.
+func Restore(snap *Snapshot) (*Scheduler, error) {
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w: version %d, want %d", ErrSnapshot, snap.Version, snapshotVersion)
+	}
+	s := NewScheduler(WithQuantum(snap.Quantum), WithUnit(snap.Unit), WithPolicy(snap.Policy),
+		WithCFS(snap.CFS[0], snap.CFS[1]), WithDevices(snap.Devices),
+		WithSyscallCosts(snap.SyscallCosts), WithLogSize(snap.LogSize), WithSwap(snap.Swap))
+	s.klog, s.klogSeq = slices.Clone(snap.Log), snap.LogSeq
+	s.now = snap.Now
+	s.dispatches = snap.Dispatches
+	s.switches = snap.Switches
+	s.lastPID = snap.LastPID
+	s.loadAvg, s.loadAt = snap.LoadAvg, snap.LoadAt
+	s.minVruntime = snap.MinVrun
+	s.nextPID = snap.NextPID
+	s.msgSeq = snap.MsgSeq
+	s.pipeSeq = snap.PipeSeq
+	s.irqSeq = snap.IRQSeq
+	s.irqStats = snap.IRQStats
+	s.diskBusy = snap.DiskBusy
+	s.swapStats = snap.SwapStats
+	s.ttyBuf = snap.TTYBuf
+	s.sysStats = snap.SysStats
+	for _, ev := range snap.IRQs {
+		if ev.Msg != nil {
+			m := cloneMessage(*ev.Msg)
+			ev.Msg = &m
+		}
+		s.irqs = append(s.irqs, ev)
+	}
+	if err := s.rng.UnmarshalBinary(snap.RNG); err != nil {
+		return nil, fmt.Errorf("%w: rng: %v", ErrSnapshot, err)
+	}
+	s.rand = rand.New(s.rng)
+	s.bankers = snap.Bankers
+	s.deadlockEvery = snap.DeadlockEvery
+	s.deadlockRecover = snap.DeadlockRecover
+	s.deadlocks = append(s.deadlocks, snap.Deadlocks...)
+	for _, key := range snap.ReportedCycles {
+		s.reportedCycles[key] = true
+	}
+	maps.Copy(s.managed, snap.Managed)
+	for _, h := range []struct {
+		dst  **Histogram
+		snap Histogram
+	}{{&s.readyWait, snap.ReadyWait}, {&s.blockWait, snap.BlockWait}} {
+		if len(h.snap.Counts) == 0 {
+			continue
+		}
+		if len(h.snap.Counts) != len(h.snap.Bounds)+1 {
+			return nil, fmt.Errorf("%w: histogram has %d counts for %d bounds", ErrSnapshot, len(h.snap.Counts), len(h.snap.Bounds))
+		}
+		c := h.snap.clone()
+		*h.dst = &c
+	}
+
+	for _, ps := range snap.Pipes {
+		s.pipes = append(s.pipes, ipc.RestorePipe(ps))
+	}
+	pipe := func(i int) (*ipc.Pipe, error) {
+		if i < 0 || i >= len(s.pipes) {
+			return nil, fmt.Errorf("%w: no pipe %d", ErrSnapshot, i)
+		}
+		return s.pipes[i], nil
+	}
+	for name, content := range snap.Files {
+		_ = s.fs.WriteFile(name, content)
+	}
+	for path, i := range snap.Fifos {
+		pp, err := pipe(i)
+		if err != nil {
+			return nil, err
+		}
+		s.fs.Mkfifo(path, pp)
+	}
+	for name, info := range snap.FSInfo {
+		s.fs.SetInfo(name, info)
+	}
+	s.fs.SetOps(snap.FSOps)
+	if len(snap.Users) > 0 {
+		s.users = s.users[:0]
+		for _, u := range snap.Users {
+			u.Groups = slices.Clone(u.Groups)
+			s.users = append(s.users, u)
+		}
+	}
+
+	for _, gs := range snap.Cgroups {
+		s.cgroups = append(s.cgroups, &cgroup{
+			CgroupSpec: gs.CgroupSpec, procs: gs.Procs, periodStart: gs.PeriodStart, used: gs.Used,
+			throttled: gs.Throttled, throttledAt: gs.ThrottledAt,
+			cpu: gs.CPU, nrThrottled: gs.NrThrottled, throttledTime: gs.ThrottledTime,
+			memory: gs.Memory, memPeak: gs.MemPeak, oomKills: gs.OOMKills, rejected: gs.Rejected,
+		})
+	}
+
+	for _, ps := range snap.Procs {
+		p := &Process{
+			ID:         ps.ID,
+			Name:       ps.Name,
+			Priority:   ps.Priority,
+			Behavior:   ps.Behavior,
+			Program:    ps.Program,
+			WorkUnits:  ps.WorkUnits,
+			totalUnits: ps.TotalUnits,
+			RunCount:   ps.RunCount,
+			TotalCPU:   ps.TotalCPU,
+			cpuTime:    ps.CPUTime,
+			state:      ps.State,
+			waitingOn:  ps.WaitingOn,
+			exitReason: ps.ExitReason,
+			maxNeed:    maps.Clone(ps.MaxNeed),
+			parentID:   ps.Parent,
+			fds:        make(map[int]*fdesc, len(ps.Fds)),
+			spawnedAt:  ps.SpawnedAt,
+			readyAt:    ps.ReadyAt,
+			blockedAt:  ps.BlockedAt,
+			pc:         ps.PC,
+			opLeft:     ps.OpLeft,
+			acc:        ps.Acc,
+			callSeq:    ps.CallSeq,
+			fsWrites:   slices.Clone(ps.FSWrites),
+			rt:         cloneRT(ps.RT),
+			vruntime:   ps.VRuntime,
+			mem:        ps.Memory,
+			user:       ps.User,
+			cred:       cloneCred(ps.Cred),
+			io:         cloneIO(ps.IO),
+			swap:       ps.Swap,
+			sysCalls:   maps.Clone(ps.Syscalls),
+			sysTime:    ps.SysTime,
+			strace:     slices.Clone(ps.Strace),
+			sysOp:      cloneOp(ps.SysOp),
+			model:      ps.Model,
+			ucur:       ps.UCur,
+		}
+		for _, t := range ps.UThreads {
+			p.uts = append(p.uts, &t)
+		}
+		if ps.Cgroup != "" {
+			if p.cgroup = s.cgroupLocked(ps.Cgroup); p.cgroup == nil {
+				return nil, fmt.Errorf("%w: process %d in unknown cgroup %q", ErrSnapshot, ps.ID, ps.Cgroup)
+			}
+		}
+		if p.rt != nil {
+			s.rtProcs = append(s.rtProcs, p)
+		}
+		for _, fd := range ps.Fds {
+			pp, err := pipe(fd.Pipe)
+			if err != nil {
+				return nil, err
+			}
+			p.fds[fd.Fd] = &fdesc{pipe: pp, write: fd.Write}
+		}
+		if ps.Recv != nil {
+			p.recv = &recvFilter{typ: ps.Recv.Type, corr: ps.Recv.Corr}
+		}
+		if ps.LastMsg != nil {
+			m := cloneMessage(*ps.LastMsg)
+			p.lastMsg = &m
+		}
+		s.procs[p.ID] = p
+	}
+	for _, ps := range snap.Procs {
+		if ps.Leader == 0 {
+			continue
+		}
+		p, lead := s.procs[ps.ID], s.procs[ps.Leader]
+		if lead == nil {
+			return nil, fmt.Errorf("%w: thread %d of unknown process %d", ErrSnapshot, ps.ID, ps.Leader)
+		}
+		p.leader, p.fds = lead, lead.fds
+		lead.threads = append(lead.threads, p)
+	}
+	proc := func(pid int) (*Process, error) {
+		if pid == 0 {
+			return nil, nil
+		}
+		p, ok := s.procs[pid]
+		if !ok {
+			return nil, fmt.Errorf("%w: no process %d", ErrSnapshot, pid)
+		}
+		return p, nil
+	}
+	procList := func(ids []int) ([]*Process, error) {
+		out := make([]*Process, 0, len(ids))
+		for _, pid := range ids {
+			p, err := proc(pid)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, p)
+		}
+		return out, nil
+	}
+
+	ready, err := procList(snap.Ready)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ready {
+		s.runq.push(p)
+	}
+	for i, gs := range snap.Cgroups {
+		if s.cgroups[i].parked, err = procList(gs.Parked); err != nil {
+			return nil, err
+		}
+	}
+	for pid, box := range snap.Mailboxes {
+		out := make([]ipc.Message, 0, len(box))
+		for _, m := range box {
+			out = append(out, cloneMessage(m))
+		}
+		s.mailboxes[pid] = out
+	}
+	for pid, byType := range snap.MailStats {
+		for typ, ts := range byType {
+			*s.typeStatLocked(pid, typ) = ts
+		}
+	}
+	for _, f := range snap.Flows {
+		s.flows[[2]int{f.From, f.To}] = f.Count
+	}
+	for name, members := range snap.Groups {
+		s.groups[name] = make(map[int]bool, len(members))
+		for _, pid := range members {
+			s.joinGroupLocked(pid, name)
+		}
+	}
+
+	for _, ss := range snap.Segments {
+		s.segments[ss.Name] = ipc.RestoreSegment(ss)
+	}
+	for _, ms := range snap.Mutexes {
+		m := s.getMutex(ms.Name)
+		if m.owner, err = proc(ms.Owner); err != nil {
+			return nil, err
+		}
+		if m.waiters, err = procList(ms.Waiters); err != nil {
+			return nil, err
+		}
+		m.acquires, m.contended, m.misuses = ms.Acquires, ms.Contended, ms.Misuses
+	}
+	for _, ss := range snap.Sems {
+		sem := s.getSem(ss.Name)
+		sem.count, sem.total = ss.Count, ss.Total
+		maps.Copy(sem.held, ss.Held)
+		if sem.waiters, err = procList(ss.Waiters); err != nil {
+			return nil, err
+		}
+		sem.acquires, sem.contended = ss.Acquires, ss.Contended
+	}
+	for _, cs := range snap.Conds {
+		c := s.getCond(cs.Name)
+		c.waits, c.signals = cs.Waits, cs.Signals
+		for _, w := range cs.Waiters {
+			p, err := proc(w.PID)
+			if err != nil {
+				return nil, err
+			}
+			c.waiters = append(c.waiters, condWaiter{p: p, mutex: w.Mutex})
+		}
+	}
+	for _, fl := range snap.Flocks {
+		l := s.getFlock(fl.Name)
+		if l.writer, err = proc(fl.Writer); err != nil {
+			return nil, err
+		}
+		readers, err := procList(fl.Readers)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range readers {
+			l.readers[p.ID] = p
+		}
+		for _, w := range fl.Waiters {
+			p, err := proc(w.PID)
+			if err != nil {
+				return nil, err
+			}
+			l.waiters = append(l.waiters, flockWaiter{p: p, mode: w.Mode})
+		}
+		l.acquires, l.contended, l.misuses = fl.Acquires, fl.Contended, fl.Misuses
+	}
+	if s.ttyWaiters, err = procList(snap.TTYWaiters); err != nil {
+		return nil, err
+	}
+	if s.swapQ, err = procList(snap.SwapQueue); err != nil {
+		return nil, err
+	}
+	for _, r := range snap.Pending {
+		p, err := proc(r.PID)
+		if err != nil {
+			return nil, err
+		}
+		s.pending = append(s.pending, pendingReq{p: p, kind: r.Kind, name: r.Name})
+	}
+	return s, nil
+}
+
+func cloneIO(r *ioReq) *ioReq {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
+
+func cloneCred(c fs.Cred) fs.Cred {
+	c.Groups = slices.Clone(c.Groups)
+	return c
+}
+
+func cloneOp(op *Op) *Op {
+	if op == nil {
+		return nil
+	}
+	c := *op
+	return &c
+}
+
+func cloneRT(rt *rtState) *rtState {
+	if rt == nil {
+		return nil
+	}
+	c := *rt
+	return &c
+}
@@ -0,0 +1,214 @@
+package sched
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gosimos/fs"
+	"gosimos/ipc"
+)
+
+// checkpointWorkload mixes every kind of kernel object, the legacy
+// behaviours and the scheduler's RNG, so a snapshot has plenty to lose.
+func checkpointWorkload(pol Policy) *Scheduler {
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithPolicy(pol),
+		WithSyscallCosts(DefaultSyscallCosts(time.Millisecond)), WithSwap(SwapConfig{Memory: 3072}))
+	s.SetSeed(42)
+	s.SetDeadlockDetection(3, true)
+	spawnWorkload(s)
+	return s
+}
+
+func spawnWorkload(s *Scheduler) {
+	Philosophers(s, 3, 2)
+	Pipeline(s, 4)
+	SharedMemory(s, 3)
+	RPC(s, 2, 2)
+	_ = s.NewCgroup(CgroupSpec{Name: "batch", Shares: 512, Quota: 2 * time.Millisecond, Period: 5 * time.Millisecond, MemLimit: 4096, PidsMax: 3})
+	for i := 0; i < 4; i++ {
+		s.Spawn(&ProcessSpec{Name: fmt.Sprintf("batch-%d", i), Cgroup: "batch", Memory: 1024,
+			Program: []Op{Compute(2), Alloc(512 * i), Compute(2), Free(512 * i)}})
+	}
+	IOBound(s)
+	Users(s)
+	Top(s)
+	s.Spawn(&ProcessSpec{Name: "sporadic", RT: &RTSpec{Period: 3 * time.Millisecond, WCET: time.Millisecond, Sporadic: true, Jobs: 4}})
+	for i := 0; i < 4; i++ {
+		s.Spawn(&ProcessSpec{
+			Name:      fmt.Sprintf("legacy-%d", i),
+			Priority:  s.Intn(3),
+			WorkUnits: 2 + s.Intn(4),
+			Behavior:  Behavior(i % 3),
+		})
+	}
+	Swapping(s)
+	Threading(s)
+}
+
+// runToEnd steps until the scheduler stays idle. An idle dispatch may
+// still recover from a deadlock and make a victim's resources ready.
+func runToEnd(s *Scheduler) {
+	for s.Step(1000) > 0 || s.Step(1000) > 0 {
+	}
+}
+
+type outcome struct {
+	Now        time.Duration
+	Dispatches int
+	Stats      []ProcessStat
+	Mailboxes  map[int][]ipc.Message
+	MailStats  []ipc.MailboxStat
+	FS         map[string]string
+	FileInfo   map[string]fs.Info
+	Users      []User
+	Pipes      []ipc.PipeStat
+	Segments   []ipc.SegmentStat
+	Sync       []SyncStat
+	Deadlocks  []DeadlockReport
+	Switches   int
+	ReadyWait  Histogram
+	BlockWait  Histogram
+	Flows      []ipc.Flow
+	FSOps      map[string]int
+	RT         []RTStat
+	Cgroups    []CgroupStat
+	IRQs       []IRQStat
+	Syscalls   []SyscallStat
+	Strace     map[int][]SyscallRecord
+	Log        []LogEntry
+	Swap       SwapStat
+	Threads    map[int][]ThreadStat
+	NextRandom int
+}
+
+func outcomeOf(s *Scheduler) outcome {
+	stats := s.Stats()
+	for i := range stats {
+		stats[i].TotalCPU = 0 // wall clock
+	}
+	return outcome{
+		Now:        s.Now(),
+		Dispatches: s.Dispatches(),
+		Stats:      stats,
+		Mailboxes:  s.DumpMailboxes(),
+		MailStats:  s.MailboxStats(),
+		FS:         s.DumpFS(),
+		FileInfo:   s.fs.Infos(),
+		Users:      s.Users(),
+		Pipes:      s.PipeStats(),
+		Segments:   s.SegmentStats(),
+		Sync:       s.SyncStats(),
+		Deadlocks:  s.Deadlocks(),
+		Switches:   s.ContextSwitches(),
+		ReadyWait:  s.ReadyWait(),
+		BlockWait:  s.BlockWait(),
+		Flows:      s.Flows(),
+		FSOps:      s.fs.Ops(),
+		RT:         s.RTStats(),
+		Cgroups:    s.CgroupStats(),
+		IRQs:       s.IRQStats(),
+		Syscalls:   s.SyscallStats(),
+		Strace:     straces(s),
+		Log:        s.Dmesg(LogFilter{}),
+		Swap:       s.SwapStats(),
+		Threads:    threads(s),
+		NextRandom: s.Intn(1 << 30),
+	}
+}
+
+func straces(s *Scheduler) map[int][]SyscallRecord {
+	out := make(map[int][]SyscallRecord)
+	for _, st := range s.Stats() {
+		out[st.ID], _ = s.Strace(st.ID)
+	}
+	return out
+}
+
+func threads(s *Scheduler) map[int][]ThreadStat {
+	out := make(map[int][]ThreadStat)
+	for _, st := range s.Stats() {
+		out[st.ID], _ = s.Threads(st.ID)
+	}
+	return out
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	a, b := checkpointWorkload(PolicyPriority), checkpointWorkload(PolicyPriority)
+	runToEnd(a)
+	runToEnd(b)
+	if !reflect.DeepEqual(outcomeOf(a), outcomeOf(b)) {
+		t.Fatalf("two runs of the same workload differ")
+	}
+}
+
+func TestRestoreMatchesUninterruptedRun(t *testing.T) {
+	for _, pol := range []Policy{PolicyPriority, PolicyEDF, PolicyCFS} {
+		t.Run(pol.String(), func(t *testing.T) {
+			ref := checkpointWorkload(pol)
+			runToEnd(ref)
+			want := outcomeOf(ref)
+			if want.Dispatches < 40 {
+				t.Fatalf("workload too small to be interesting: %d dispatches", want.Dispatches)
+			}
+
+			for _, k := range []int{1, 5, 17, want.Dispatches / 2, want.Dispatches - 3} {
+				s := checkpointWorkload(pol)
+				s.Step(k)
+				path := filepath.Join(t.TempDir(), "sim.json")
+				if err := s.SaveCheckpoint(path); err != nil {
+					t.Fatal(err)
+				}
+				restored, err := LoadCheckpoint(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := restored.Dispatches(); got != k {
+					t.Fatalf("restored at dispatch %d, want %d", got, k)
+				}
+				runToEnd(restored)
+				if got := outcomeOf(restored); !reflect.DeepEqual(got, want) {
+					t.Errorf("restore after %d dispatches diverged:\n got %+v\nwant %+v", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestForkIsIndependent(t *testing.T) {
+	ref := checkpointWorkload(PolicyPriority)
+	runToEnd(ref)
+	want := outcomeOf(ref)
+
+	s := checkpointWorkload(PolicyPriority)
+	s.Step(10)
+	fork, err := s.Fork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork.Kill(1)
+	runToEnd(fork)
+	runToEnd(s)
+	if got := outcomeOf(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("original changed by its fork")
+	}
+	if reflect.DeepEqual(outcomeOf(fork), want) {
+		t.Errorf("killing PID 1 in the fork made no difference")
+	}
+}
+
+func TestRestoreRejectsBadSnapshot(t *testing.T) {
+	snap := newTestScheduler().Checkpoint()
+	snap.Version = 99
+	if _, err := Restore(snap); err == nil {
+		t.Fatal("restored a snapshot with an unknown version")
+	}
+
+	snap = checkpointWorkload(PolicyPriority).Checkpoint()
+	snap.Ready = append(snap.Ready, 999)
+	if _, err := Restore(snap); err == nil {
+		t.Fatal("restored a snapshot referring to an unknown PID")
+	}
+}
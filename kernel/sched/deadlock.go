@@ -0,0 +1,278 @@
+package sched
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type DeadlockReport struct {
+	Dispatch int
+	PIDs     []int
+	Victim   int
+}
+
+// SetDeadlockDetection runs detection every n dispatches (0 disables the
+// periodic check) and, with recover set, kills one victim per cycle.
+func (s *Scheduler) SetDeadlockDetection(every int, recover bool) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev := Event{Kind: EvDetect, N: every}
+	if recover {
+		ev.Value = 1
+	}
+	s.recordLocked(ev)
+	s.deadlockEvery = every
+	s.deadlockRecover = recover
+}
+
+// waitForGraphLocked maps every blocked PID to the PIDs holding the object
+// it waits on. Condition variables add no edges: any process may signal.
+// A semaphore waiter is released by any one holder (anyOf); a mutex or
+// file lock waiter needs every conflicting holder to let go, and a thread
+// joining another waits on that thread.
+func (s *Scheduler) waitForGraphLocked() (g map[int][]int, anyOf map[int]bool) {
+	g = make(map[int][]int)
+	anyOf = make(map[int]bool)
+	for _, p := range s.procs {
+		if p.state != StateBlocked {
+			continue
+		}
+		kind, name, _ := strings.Cut(p.waitingOn, ":")
+		var holders []int
+		switch kind {
+		case "mutex":
+			if m, ok := s.mutexes[name]; ok && m.owner != nil {
+				holders = append(holders, m.owner.ID)
+			}
+		case "sem":
+			if sem, ok := s.sems[name]; ok && sem.count == 0 {
+				for pid := range sem.held {
+					holders = append(holders, pid)
+				}
+				anyOf[p.ID] = true
+			}
+		case "flock":
+			if l, ok := s.flocks[name]; ok {
+				if l.writer != nil {
+					holders = append(holders, l.writer.ID)
+				}
+				for pid := range l.readers {
+					holders = append(holders, pid)
+				}
+			}
+		case "join":
+			if t := s.threadLocked(p, name); t != nil && t.state != StateExited {
+				holders = append(holders, t.ID)
+			}
+		}
+		sort.Ints(holders)
+		for _, h := range holders {
+			if h != p.ID {
+				g[p.ID] = append(g[p.ID], h)
+			}
+		}
+	}
+	return g, anyOf
+}
+
+// stuck reduces the wait-for graph: a waiter can still make progress
+// if the holders it depends on can. What remains is deadlocked.
+func stuck(g map[int][]int, anyOf map[int]bool) map[int]bool {
+	out := make(map[int]bool, len(g))
+	for pid := range g {
+		out[pid] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for pid := range out {
+			free := 0
+			for _, h := range g[pid] {
+				if !out[h] {
+					free++
+				}
+			}
+			if (anyOf[pid] && free > 0) || (!anyOf[pid] && free == len(g[pid])) {
+				delete(out, pid)
+				changed = true
+			}
+		}
+	}
+	return out
+}
+
+// cyclesLocked returns the strongly connected components of the wait-for
+// graph among deadlocked processes, each sorted by PID.
+func (s *Scheduler) cyclesLocked() [][]int {
+	full, anyOf := s.waitForGraphLocked()
+	dead := stuck(full, anyOf)
+	g := make(map[int][]int, len(dead))
+	for pid := range dead {
+		for _, h := range full[pid] {
+			if dead[h] {
+				g[pid] = append(g[pid], h)
+			}
+		}
+	}
+	nodes := make([]int, 0, len(g))
+	for pid := range g {
+		nodes = append(nodes, pid)
+	}
+	sort.Ints(nodes)
+
+	index := 0
+	indices := map[int]int{}
+	low := map[int]int{}
+	onStack := map[int]bool{}
+	stack := []int{}
+	out := [][]int{}
+
+	var strongconnect func(v int)
+	strongconnect = func(v int) {
+		indices[v] = index
+		low[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range g[v] {
+			if _, seen := indices[w]; !seen {
+				strongconnect(w)
+				low[v] = min(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = min(low[v], indices[w])
+			}
+		}
+		if low[v] != indices[v] {
+			return
+		}
+		comp := []int{}
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			comp = append(comp, w)
+			if w == v {
+				break
+			}
+		}
+		if len(comp) > 1 {
+			sort.Ints(comp)
+			out = append(out, comp)
+		}
+	}
+	for _, v := range nodes {
+		if _, seen := indices[v]; !seen {
+			strongconnect(v)
+		}
+	}
+	return out
+}
+
+// DetectDeadlock returns the current sets of deadlocked PIDs without
+// recording or recovering from them.
+func (s *Scheduler) DetectDeadlock() [][]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cyclesLocked()
+}
+
+func (s *Scheduler) Deadlocks() []DeadlockReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeadlockReport(nil), s.deadlocks...)
+}
+
+// checkDeadlockLocked records newly found cycles and, in recovery mode,
+// kills a victim from each until the graph is acyclic.
+func (s *Scheduler) checkDeadlockLocked() {
+	for {
+		cycles := s.cyclesLocked()
+		found := false
+		for _, c := range cycles {
+			key := fmt.Sprint(c)
+			if !s.deadlockRecover && s.reportedCycles[key] {
+				continue
+			}
+			s.reportedCycles[key] = true
+			s.klogLocked(LogWarn, "deadlock", 0, "cycle among PIDs %v", c)
+			r := DeadlockReport{Dispatch: s.dispatches, PIDs: c}
+			if s.deadlockRecover {
+				victim := s.pickVictimLocked(c)
+				r.Victim = victim.ID
+				s.killLocked(victim, "deadlock victim")
+				found = true
+			}
+			s.deadlocks = append(s.deadlocks, r)
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+// pickVictimLocked prefers the lowest-priority process, then the one that
+// has done the least work, so the least progress is thrown away.
+func (s *Scheduler) pickVictimLocked(cycle []int) *Process {
+	var victim *Process
+	for _, pid := range cycle {
+		p := s.procs[pid]
+		if victim == nil ||
+			p.Priority > victim.Priority ||
+			(p.Priority == victim.Priority && p.unitsDone() < victim.unitsDone()) {
+			victim = p
+		}
+	}
+	return victim
+}
+
+// Kill terminates a process, releasing everything it holds. It waits for
+// the quantum in flight so that the kill lands between two dispatches.
+func (s *Scheduler) Kill(pid int) bool {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvKill, PID: pid})
+	p, ok := s.procs[pid]
+	if ok {
+		p = p.proc()
+	}
+	if !ok || p.state == StateExited {
+		return false
+	}
+	s.killLocked(p, "killed")
+	return true
+}
+
+func (s *Scheduler) killLocked(p *Process, reason string) {
+	p.exitReason = reason
+	switch p.state {
+	case StateRunning:
+		return
+	case StateReady:
+		s.removeReadyLocked(p)
+	case StateBlocked:
+		s.dequeueLocked(p)
+	}
+	s.exitLocked(p)
+	// p did not exit in its own dispatch, where breakpoints are checked.
+	s.checkBreakpointsLocked(p)
+}
+
+func (s *Scheduler) exitLocked(p *Process) {
+	p.state = StateExited
+	p.waitingOn = ""
+	s.releaseHeld(p, p.exitReason != "")
+	s.threadExitLocked(p)
+	s.swapFreeLocked(p)
+	s.leaveCgroupLocked(p)
+	s.closeAllLocked(p)
+	s.procEventLocked(p, Observer.OnExit)
+	if p.exitReason != "" {
+		s.klogLocked(LogNotice, "proc", p.ID, "%q killed: %s", p.Name, p.exitReason)
+	} else {
+		s.klogLocked(LogDebug, "proc", p.ID, "%q exited", p.Name)
+	}
+}
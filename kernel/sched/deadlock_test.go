@@ -0,0 +1,140 @@
+package sched
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func lockOrder(first, second string) []Op {
+	return []Op{MutexLock(first), Compute(1), MutexLock(second), Compute(1), MutexUnlock(second), MutexUnlock(first)}
+}
+
+func waitDeadlock(t *testing.T, s *Scheduler) DeadlockReport {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if dl := s.Deadlocks(); len(dl) > 0 {
+			return dl[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no deadlock detected")
+	return DeadlockReport{}
+}
+
+func TestDetectsLockOrderDeadlock(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+	s.SetDeadlockDetection(1, false)
+
+	a := s.Spawn(&ProcessSpec{Name: "ab", Program: lockOrder("a", "b")})
+	b := s.Spawn(&ProcessSpec{Name: "ba", Program: lockOrder("b", "a")})
+	s.Start()
+
+	r := waitDeadlock(t, s)
+	if len(r.PIDs) != 2 || r.PIDs[0] != a || r.PIDs[1] != b {
+		t.Errorf("deadlocked PIDs = %v, want [%d %d]", r.PIDs, a, b)
+	}
+	if r.Victim != 0 {
+		t.Errorf("victim %d killed with recovery disabled", r.Victim)
+	}
+	if got := s.DetectDeadlock(); len(got) != 1 {
+		t.Errorf("DetectDeadlock() = %v, want one cycle", got)
+	}
+}
+
+func TestDeadlockRecoveryKillsLeastProgress(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+	s.SetDeadlockDetection(1, true)
+
+	// ba holds b through a long computation, so ab has done less work
+	// by the time both are stuck.
+	low := s.Spawn(&ProcessSpec{Name: "ab", Program: append([]Op{Compute(1)}, lockOrder("a", "b")...)})
+	s.Spawn(&ProcessSpec{Name: "ba", Program: []Op{MutexLock("b"), Compute(4), MutexLock("a"), MutexUnlock("a"), MutexUnlock("b")}})
+	s.Start()
+
+	r := waitDeadlock(t, s)
+	stats := waitExited(t, s, 2*time.Second)
+	if r.Victim != low {
+		t.Errorf("victim = %d, want %d", r.Victim, low)
+	}
+	for _, st := range stats {
+		if st.ID == low && st.ExitReason != "deadlock victim" {
+			t.Errorf("victim exit reason = %q", st.ExitReason)
+		}
+		if st.ID != low && (st.ExitReason != "" || st.Remaining != 0) {
+			t.Errorf("survivor %d did not finish: %+v", st.ID, st)
+		}
+	}
+}
+
+func TestBankersAvoidsLockOrderDeadlock(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+	s.SetDeadlockDetection(1, false)
+	s.EnableBankers()
+
+	claim := map[string]int{"a": 1, "b": 1}
+	if _, err := s.TrySpawn(&ProcessSpec{Name: "ab", Program: lockOrder("a", "b"), MaxNeed: claim}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TrySpawn(&ProcessSpec{Name: "ba", Program: lockOrder("b", "a"), MaxNeed: claim}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	if dl := s.Deadlocks(); len(dl) != 0 {
+		t.Errorf("deadlock under Banker's algorithm: %+v", dl)
+	}
+}
+
+func TestBankersManagesEarlierClaimsAndUndeclaredRequests(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+	s.SetDeadlockDetection(1, false)
+
+	claim := map[string]int{"a": 1, "b": 1}
+	s.Spawn(&ProcessSpec{Name: "ab", Program: lockOrder("a", "b"), MaxNeed: claim})
+	s.Spawn(&ProcessSpec{Name: "ba", Program: lockOrder("b", "a"), MaxNeed: claim})
+	s.Spawn(&ProcessSpec{Name: "free", Program: []Op{MutexLock("a"), Compute(1), MutexUnlock("a")}})
+	s.EnableBankers()
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	if dl := s.Deadlocks(); len(dl) != 0 {
+		t.Errorf("deadlock with claims made before EnableBankers: %+v", dl)
+	}
+	for _, st := range s.Stats() {
+		if st.ExitReason != "" {
+			t.Errorf("%s: %q, want the undeclared request to wait its turn", st.Name, st.ExitReason)
+		}
+	}
+}
+
+func TestBankersRejectsClaimAboveCapacity(t *testing.T) {
+	s := newTestScheduler()
+	s.EnableBankers()
+	s.NewSemaphore("tapes", 2)
+
+	_, err := s.TrySpawn(&ProcessSpec{Name: "greedy", Program: []Op{SemWait("tapes")}, MaxNeed: map[string]int{"tapes": 3}})
+	if !errors.Is(err, ErrExceedsCapacity) {
+		t.Errorf("TrySpawn error = %v, want ErrExceedsCapacity", err)
+	}
+}
+
+func TestBankersExerciseCompletes(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+	s.SetDeadlockDetection(1, false)
+	s.EnableBankers()
+
+	BankersExercise(s)
+	s.Start()
+	waitExited(t, s, 5*time.Second)
+	if dl := s.Deadlocks(); len(dl) != 0 {
+		t.Errorf("deadlock under Banker's algorithm: %+v", dl)
+	}
+}
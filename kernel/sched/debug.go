@@ -0,0 +1,185 @@
+package sched
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Breakpoint pauses the scheduler when its condition becomes true. The
+// condition is checked with s.mu held after every dispatch, against the
+// process that just ran. Breakpoints are edge-triggered: one that stays
+// true does not fire again until it has been false.
+type Breakpoint struct {
+	ID   int
+	Desc string
+	Hits int
+	cond func(s *Scheduler, p *Process) bool
+	was  bool
+}
+
+type BreakHit struct {
+	ID       int
+	Desc     string
+	PID      int
+	Dispatch int
+	Now      time.Duration
+}
+
+func (s *Scheduler) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+func (s *Scheduler) Dispatches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dispatches
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Pause stops dispatching and returns once the quantum in flight, if any,
+// has finished.
+func (s *Scheduler) Pause() {
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+	s.exec.Lock()
+	s.exec.Unlock()
+}
+
+func (s *Scheduler) Resume() {
+	s.mu.Lock()
+	s.paused = false
+	s.mu.Unlock()
+	s.notify()
+}
+
+// Step pauses the scheduler and runs up to n dispatches in the calling
+// goroutine. It stops early when nothing is ready or a breakpoint fires,
+// and returns how many dispatches were made. Step also works on a
+// scheduler that was never started.
+func (s *Scheduler) Step(n int) int {
+	s.Pause()
+	done, _ := s.step(n)
+	return done
+}
+
+func (s *Scheduler) step(n int) (int, bool) {
+	done := 0
+	for done < n {
+		s.exec.Lock()
+		ran, hit := s.dispatch()
+		s.exec.Unlock()
+		if !ran {
+			return done, false
+		}
+		done++
+		if hit {
+			return done, true
+		}
+	}
+	return done, false
+}
+
+// StepTime is Step measured on the simulated clock: it dispatches until
+// the clock has advanced by d.
+func (s *Scheduler) StepTime(d time.Duration) int {
+	s.Pause()
+	until := s.Now() + d
+	done := 0
+	for s.Now() < until {
+		n, hit := s.step(1)
+		done += n
+		if n == 0 || hit {
+			break
+		}
+	}
+	return done
+}
+
+// Hits delivers breakpoint hits. It is buffered; hits are dropped when
+// nobody is reading.
+func (s *Scheduler) Hits() <-chan BreakHit {
+	return s.hits
+}
+
+func (s *Scheduler) addBreakpoint(desc string, cond func(s *Scheduler, p *Process) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakSeq++
+	s.breakpoints = append(s.breakpoints, &Breakpoint{ID: s.breakSeq, Desc: desc, cond: cond})
+	return s.breakSeq
+}
+
+func (s *Scheduler) BreakOnBlock(pid int) int {
+	return s.addBreakpoint(fmt.Sprintf("PID %d blocks", pid), func(s *Scheduler, p *Process) bool {
+		return p.ID == pid && p.state == StateBlocked
+	})
+}
+
+func (s *Scheduler) BreakOnExit(pid int) int {
+	return s.addBreakpoint(fmt.Sprintf("PID %d exits", pid), func(s *Scheduler, p *Process) bool {
+		return p.ID == pid && p.state == StateExited
+	})
+}
+
+func (s *Scheduler) BreakOnReadyLen(n int) int {
+	return s.addBreakpoint(fmt.Sprintf("ready queue > %d", n), func(s *Scheduler, p *Process) bool {
+		return s.readyLenLocked() > n
+	})
+}
+
+func (s *Scheduler) BreakAt(t time.Duration) int {
+	return s.addBreakpoint(fmt.Sprintf("t >= %v", t), func(s *Scheduler, p *Process) bool {
+		return s.now >= t
+	})
+}
+
+func (s *Scheduler) ClearBreakpoint(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, bp := range s.breakpoints {
+		if bp.ID == id {
+			s.breakpoints = append(s.breakpoints[:i], s.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scheduler) Breakpoints() []Breakpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Breakpoint, 0, len(s.breakpoints))
+	for _, bp := range s.breakpoints {
+		out = append(out, Breakpoint{ID: bp.ID, Desc: bp.Desc, Hits: bp.Hits})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (s *Scheduler) checkBreakpointsLocked(p *Process) bool {
+	hit := false
+	for _, bp := range s.breakpoints {
+		now := bp.cond(s, p)
+		if now && !bp.was {
+			bp.Hits++
+			hit = true
+			s.paused = true
+			select {
+			case s.hits <- BreakHit{ID: bp.ID, Desc: bp.Desc, PID: p.ID, Dispatch: s.dispatches, Now: s.now}:
+			default:
+			}
+		}
+		bp.was = now
+	}
+	return hit
+}
@@ -0,0 +1,111 @@
+package sched
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStepWithoutStart(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&ProcessSpec{Name: "a", WorkUnits: 2})
+	s.Spawn(&ProcessSpec{Name: "b", WorkUnits: 1})
+
+	if n := s.Step(2); n != 2 {
+		t.Fatalf("Step(2) = %d", n)
+	}
+	if got := s.Dispatches(); got != 2 {
+		t.Errorf("dispatches = %d, want 2", got)
+	}
+	if n := s.Step(10); n != 1 {
+		t.Errorf("Step(10) = %d, want 1 (only a's last unit is left)", n)
+	}
+	if n := s.Step(1); n != 0 {
+		t.Errorf("Step on an empty ready queue = %d", n)
+	}
+}
+
+func TestBreakOnBlockPausesLoop(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+	Philosophers(s, 3, 2)
+	s.BreakOnBlock(1)
+	s.Start()
+
+	select {
+	case h := <-s.Hits():
+		if h.PID != 1 {
+			t.Errorf("hit by PID %d, want 1", h.PID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("breakpoint never hit")
+	}
+	if !s.Paused() {
+		t.Fatal("scheduler not paused after breakpoint")
+	}
+	n := s.Dispatches()
+	time.Sleep(20 * time.Millisecond)
+	if s.Dispatches() != n {
+		t.Errorf("dispatches advanced while paused")
+	}
+	for _, st := range s.Stats() {
+		if st.ID == 1 && st.State != StateBlocked {
+			t.Errorf("PID 1 is %v at its block breakpoint", st.State)
+		}
+	}
+
+	s.Resume()
+	deadline := time.Now().Add(time.Second)
+	for s.Dispatches() == n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s.Dispatches() == n {
+		t.Errorf("Resume did not restart dispatching")
+	}
+}
+
+func TestBreakpointsStopStep(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&ProcessSpec{Name: "sh", Program: []Op{
+		SpawnChild(&ProcessSpec{Name: "c1", WorkUnits: 1}),
+		SpawnChild(&ProcessSpec{Name: "c2", WorkUnits: 1}),
+		SpawnChild(&ProcessSpec{Name: "c3", WorkUnits: 1}),
+		Compute(5),
+	}})
+	ready := s.BreakOnReadyLen(2)
+	at := s.BreakAt(4 * time.Millisecond)
+
+	if n := s.Step(100); n != 1 {
+		t.Errorf("ready-queue breakpoint: stepped %d, want 1", n)
+	}
+	if n := s.Step(100); s.Now() != 4*time.Millisecond {
+		t.Errorf("time breakpoint: stopped at t=%v after %d steps", s.Now(), n)
+	}
+	bps := s.Breakpoints()
+	if len(bps) != 2 || bps[0].ID != ready || bps[0].Hits != 1 || bps[1].ID != at || bps[1].Hits != 1 {
+		t.Errorf("breakpoints = %+v", bps)
+	}
+	if !s.ClearBreakpoint(at) || s.ClearBreakpoint(at) {
+		t.Errorf("ClearBreakpoint should succeed exactly once")
+	}
+}
+
+func TestBreakOnExitFiresOnKill(t *testing.T) {
+	s := newTestScheduler()
+	pid := s.Spawn(&ProcessSpec{Name: "waiter", Program: []Op{SemWait("never")}})
+	s.Spawn(&ProcessSpec{Name: "other", WorkUnits: 50})
+	id := s.BreakOnExit(pid)
+	s.Step(2)
+
+	s.Kill(pid)
+	select {
+	case h := <-s.Hits():
+		if h.ID != id || h.PID != pid {
+			t.Errorf("hit %+v, want breakpoint %d by PID %d", h, id, pid)
+		}
+	default:
+		t.Fatal("killing PID 1 did not hit its exit breakpoint")
+	}
+	if bps := s.Breakpoints(); bps[0].Hits != 1 {
+		t.Errorf("breakpoints = %+v", bps)
+	}
+}
@@ -0,0 +1,301 @@
+package sched
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"gosimos/ipc"
+)
+
+// Device is a piece of simulated hardware that raises interrupts.
+type Device int
+
+const (
+	DevTimer Device = iota // one-shot timers for Sleep
+	DevDisk                // one request at a time, FIFO
+	DevNet                 // packets arrive NetLatency after they are sent
+	DevTTY                 // one interrupt per keystroke
+	numDevices
+)
+
+func (d Device) String() string {
+	switch d {
+	case DevTimer:
+		return "timer"
+	case DevDisk:
+		return "disk"
+	case DevNet:
+		return "net"
+	case DevTTY:
+		return "tty"
+	}
+	return "unknown"
+}
+
+// DeviceConfig sets how long the simulated hardware takes. Zero fields
+// get defaults in work units: a 5-unit seek, a unit per 100 bytes, a
+// 2-unit network, a keystroke per unit and a tenth of a unit per handler.
+type DeviceConfig struct {
+	DiskSeek   time.Duration // per request
+	DiskByte   time.Duration // per byte transferred
+	NetLatency time.Duration // from send to the receive interrupt
+	KeyDelay   time.Duration // between keystrokes typed on the TTY
+	IRQCost    time.Duration // CPU time to run one interrupt handler
+}
+
+func (cfg DeviceConfig) withDefaults(unit time.Duration) DeviceConfig {
+	for _, f := range []struct {
+		v   *time.Duration
+		def time.Duration
+	}{
+		{&cfg.DiskSeek, 5 * unit},
+		{&cfg.DiskByte, unit / 100},
+		{&cfg.NetLatency, 2 * unit},
+		{&cfg.KeyDelay, unit},
+		{&cfg.IRQCost, unit / 10},
+	} {
+		if *f.v == 0 {
+			*f.v = f.def
+		}
+	}
+	return cfg
+}
+
+// IRQStat counts one device's interrupts. MaxDelay is the longest an
+// interrupt waited for the CPU: they are taken between dispatches, not
+// in the middle of a slice.
+type IRQStat struct {
+	Device   string
+	Count    int
+	Time     time.Duration // spent in the handler
+	MaxDelay time.Duration
+}
+
+// irq is a raised or scheduled interrupt; exported fields because it is
+// checkpointed as is.
+type irq struct {
+	At    time.Duration
+	Seq   uint64 // raise order among equal At
+	Dev   Device
+	PID   int          // process waiting for it, if any
+	Path  string       `json:",omitempty"` // disk
+	Data  string       `json:",omitempty"` // disk write, keystroke
+	Write bool         `json:",omitempty"`
+	Swap  bool         `json:",omitempty"` // disk: a process's memory, Write for out
+	Msg   *ipc.Message `json:",omitempty"` // net
+}
+
+// ioReq is a process's device request in flight. Done and Data are set
+// by the interrupt handler before it wakes the process.
+type ioReq struct {
+	Done bool
+	Data string
+}
+
+type irqCount struct {
+	Count    int
+	Time     time.Duration
+	MaxDelay time.Duration
+}
+
+// raiseLocked schedules ev's interrupt.
+func (s *Scheduler) raiseLocked(ev irq) {
+	s.irqSeq++
+	ev.Seq = s.irqSeq
+	i, _ := slices.BinarySearchFunc(s.irqs, ev, func(a, b irq) int {
+		if a.At != b.At {
+			return cmp.Compare(a.At, b.At)
+		}
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	s.irqs = slices.Insert(s.irqs, i, ev)
+}
+
+// nextIRQLocked is when the next interrupt fires.
+func (s *Scheduler) nextIRQLocked() (time.Duration, bool) {
+	if len(s.irqs) == 0 {
+		return math.MaxInt64, false
+	}
+	return s.irqs[0].At, true
+}
+
+// interruptsLocked runs the handler of every interrupt that is due, each
+// one taking IRQCost of CPU time, which can make more of them due.
+func (s *Scheduler) interruptsLocked() {
+	for len(s.irqs) > 0 && s.irqs[0].At <= s.now {
+		ev := s.irqs[0]
+		s.irqs = s.irqs[1:]
+		st := &s.irqStats[ev.Dev]
+		st.Count++
+		st.Time += s.devices.IRQCost
+		st.MaxDelay = max(st.MaxDelay, s.now-ev.At)
+		s.now += s.devices.IRQCost
+		s.handleLocked(ev)
+	}
+}
+
+func (s *Scheduler) handleLocked(ev irq) {
+	data := ""
+	switch ev.Dev {
+	case DevDisk:
+		if ev.Swap {
+			if !ev.Write {
+				s.swapInDoneLocked(s.procs[ev.PID])
+			}
+			return
+		}
+		if ev.Write {
+			_ = s.fsWriteLocked(s.procs[ev.PID], ev.Path, ev.Data) // checked when queued
+		} else {
+			data, _ = s.fs.ReadFile(ev.Path)
+			s.notifyLocked(func(o Observer) { o.OnFSOp(s.now, ev.PID, "read", ev.Path) })
+		}
+	case DevNet:
+		s.deliverLocked(*ev.Msg)
+		return
+	case DevTTY:
+		s.ttyBuf += ev.Data
+		s.ttyWakeLocked()
+		return
+	}
+	s.ioDoneLocked(s.procs[ev.PID], data)
+}
+
+// ioDoneLocked completes p's request, unless p was killed in the meantime.
+func (s *Scheduler) ioDoneLocked(p *Process, data string) {
+	if p == nil || p.io == nil || p.state == StateExited {
+		return
+	}
+	p.io.Done, p.io.Data = true, data
+	if p.state == StateBlocked {
+		s.wake(p)
+	}
+}
+
+// ttyWakeLocked hands complete lines to the processes waiting for input,
+// in the order they started waiting.
+func (s *Scheduler) ttyWakeLocked() {
+	for len(s.ttyWaiters) > 0 {
+		line, rest, ok := cutLine(s.ttyBuf)
+		if !ok {
+			return
+		}
+		p := s.ttyWaiters[0]
+		s.ttyWaiters = s.ttyWaiters[1:]
+		s.ttyBuf = rest
+		s.ioDoneLocked(p, line)
+	}
+}
+
+func cutLine(buf string) (line, rest string, ok bool) {
+	for i := range len(buf) {
+		if buf[i] == '\n' {
+			return buf[:i], buf[i+1:], true
+		}
+	}
+	return "", buf, false
+}
+
+// ioWait starts a device request for p with start, the first time round,
+// and reports whether it has completed; if so it returns the request's
+// data and clears it. Like a pipe op, the op is retried once p is woken.
+func (s *Scheduler) ioWait(p *Process, on string, start func()) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.io == nil {
+		p.io = &ioReq{}
+		start()
+	}
+	if !p.io.Done {
+		s.block(p, on)
+		return "", false
+	}
+	data := p.io.Data
+	p.io = nil
+	return data, true
+}
+
+// sleep blocks p for units of simulated time.
+func (s *Scheduler) sleep(p *Process, units int) bool {
+	_, done := s.ioWait(p, "timer", func() {
+		s.raiseLocked(irq{At: s.now + time.Duration(units)*s.unit, Dev: DevTimer, PID: p.ID})
+	})
+	return done
+}
+
+// diskIO queues a read or write of path behind the requests already on
+// the disk. A write reaches the file system when it completes.
+func (s *Scheduler) diskIO(p *Process, path, data string, write bool) (string, bool) {
+	return s.ioWait(p, "disk:"+path, func() {
+		n := len(data)
+		if !write {
+			n = s.fs.Size(path)
+		}
+		start := max(s.now, s.diskBusy)
+		s.diskBusy = start + s.devices.DiskSeek + time.Duration(n)*s.devices.DiskByte
+		s.raiseLocked(irq{At: s.diskBusy, Dev: DevDisk, PID: p.ID, Path: path, Data: data, Write: write})
+	})
+}
+
+// netSend puts a message on the wire; the receiver gets it NetLatency
+// later. The sender does not wait.
+func (s *Scheduler) netSend(p *Process, op Op, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from := p.proc().ID
+	msg := ipc.Message{From: from, To: op.Units, Type: op.Name, Payload: []byte(data)}
+	if op.Units == 0 && p.lastMsg != nil {
+		msg.To, msg.CorrID = p.lastMsg.From, p.lastMsg.Seq
+	}
+	if q, ok := s.procs[msg.To]; ok && !s.maySignalLocked(from, q) {
+		return ErrPermission
+	}
+	s.raiseLocked(irq{At: s.now + s.devices.NetLatency, Dev: DevNet, Msg: &msg})
+	return nil
+}
+
+// ttyRead takes the next line typed on the TTY, waiting for one if need be.
+func (s *Scheduler) ttyRead(p *Process) (string, bool) {
+	return s.ioWait(p, "tty", func() {
+		s.ttyWaiters = append(s.ttyWaiters, p)
+		s.ttyWakeLocked()
+	})
+}
+
+// Type enters text on the simulated keyboard, one keystroke every
+// KeyDelay from now. Processes reading the TTY get it a line at a time.
+func (s *Scheduler) Type(text string) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvTTY, Arg: text})
+	at := s.now
+	for _, ev := range s.irqs {
+		if ev.Dev == DevTTY {
+			at = max(at, ev.At) // behind what is still being typed
+		}
+	}
+	for i := range len(text) {
+		at += s.devices.KeyDelay
+		s.raiseLocked(irq{At: at, Dev: DevTTY, Data: text[i : i+1]})
+	}
+}
+
+// IRQStats reports every device's interrupts in Device order.
+func (s *Scheduler) IRQStats() []IRQStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]IRQStat, 0, numDevices)
+	for d, st := range s.irqStats {
+		out = append(out, IRQStat{Device: Device(d).String(), Count: st.Count, Time: st.Time, MaxDelay: st.MaxDelay})
+	}
+	return out
+}
+
+// Devices reports the hardware timings in use.
+func (s *Scheduler) Devices() DeviceConfig {
+	return s.devices
+}
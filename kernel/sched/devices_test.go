@@ -0,0 +1,137 @@
+package sched
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func irqStat(s *Scheduler, d Device) IRQStat {
+	return s.IRQStats()[d]
+}
+
+func TestSleepWakesOnTimerInterrupt(t *testing.T) {
+	s := newTestScheduler()
+	ms := time.Millisecond
+	sleeper := s.Spawn(&ProcessSpec{Name: "sleeper", Program: []Op{Compute(1), Sleep(5), Compute(1)}})
+	s.Step(2)
+	if st := s.Stats()[sleeper-1]; st.State != StateBlocked || st.WaitingOn != "timer" {
+		t.Fatalf("after two dispatches: %v on %q, want blocked on the timer", st.State, st.WaitingOn)
+	}
+	runToEnd(s)
+
+	// Idle from 1ms until the timer fires at 6ms, 0.1ms in the handler,
+	// then the last unit.
+	if got, want := s.Now(), 7*ms+100*time.Microsecond; got != want {
+		t.Errorf("finished at %v, want %v", got, want)
+	}
+	if st := irqStat(s, DevTimer); st.Count != 1 || st.Time != 100*time.Microsecond || st.MaxDelay != 0 {
+		t.Errorf("timer = %+v", st)
+	}
+}
+
+func TestInterruptWaitsForTheSliceInProgress(t *testing.T) {
+	s := NewScheduler(WithQuantum(4*time.Millisecond), WithUnit(time.Millisecond),
+		WithDevices(DeviceConfig{IRQCost: time.Millisecond}))
+	sleeper := s.Spawn(&ProcessSpec{Name: "sleeper", Program: []Op{Sleep(1), Compute(1)}})
+	s.Spawn(&ProcessSpec{Name: "hog", Priority: 1, WorkUnits: 8})
+	runToEnd(s)
+
+	// The timer fires at 1ms, a unit into the hog's 4ms slice. Its handler
+	// runs at 4ms and the two handler-free slices add up to 8+1+1ms.
+	if st := irqStat(s, DevTimer); st.Count != 1 || st.MaxDelay != 3*time.Millisecond {
+		t.Errorf("timer = %+v, want one interrupt delayed 3ms", st)
+	}
+	if got := s.Now(); got != 10*time.Millisecond {
+		t.Errorf("finished at %v, want 10ms", got)
+	}
+	if st := s.Stats()[sleeper-1]; st.State != StateExited {
+		t.Errorf("sleeper %v", st.State)
+	}
+}
+
+func TestDiskServesRequestsInOrder(t *testing.T) {
+	ms := time.Millisecond
+	s := NewScheduler(WithQuantum(ms), WithUnit(ms),
+		WithDevices(DeviceConfig{DiskSeek: 4 * ms, DiskByte: ms, IRQCost: time.Microsecond}))
+	s.Spawn(&ProcessSpec{Name: "a", Program: []Op{DiskWrite("f1", "xx")}})
+	s.Spawn(&ProcessSpec{Name: "b", Program: []Op{DiskWrite("f2", "xyz"), DiskRead("f1"), FileWrite("copy", "")}})
+	s.Step(2)
+	if files := s.DumpFS(); len(files) != 0 {
+		t.Fatalf("writes landed before the disk finished them: %v", files)
+	}
+	runToEnd(s)
+
+	// f1 is done at 6ms, f2 queues behind it until 13ms, and reading back
+	// f1 takes another 6ms from when b's handler is done, plus its own.
+	if got, want := s.Now(), 19*ms+2*time.Microsecond; got != want {
+		t.Errorf("finished at %v, want %v", got, want)
+	}
+	if files := s.DumpFS(); files["f2"] != "xyz" || files["copy"] != "xx" {
+		t.Errorf("files = %v", files)
+	}
+	if st := irqStat(s, DevDisk); st.Count != 3 {
+		t.Errorf("disk = %+v, want 3 interrupts", st)
+	}
+}
+
+func TestTTYLinesReachReadersOverTheNetwork(t *testing.T) {
+	s := newTestScheduler()
+	rx := s.Spawn(&ProcessSpec{Name: "rx", Program: Repeat(2, Receive("line"), FileWrite("log", ""))})
+	first := s.Spawn(&ProcessSpec{Name: "first", Program: []Op{TTYRead(), NetSend(rx, "line", "")}})
+	second := s.Spawn(&ProcessSpec{Name: "second", Program: []Op{TTYRead(), NetSend(rx, "line", "")}})
+	s.Type("hi\n")
+	s.Type("yo\n")
+	runToEnd(s)
+
+	if st := irqStat(s, DevTTY); st.Count != 6 {
+		t.Errorf("tty = %+v, want one interrupt per keystroke", st)
+	}
+	if st := irqStat(s, DevNet); st.Count != 2 {
+		t.Errorf("net = %+v, want 2 packets", st)
+	}
+	if files := s.DumpFS(); files["log"] != "yo" {
+		t.Errorf("log = %q, want the second line last", files["log"])
+	}
+	for _, pid := range []int{first, second} {
+		if st := s.Stats()[pid-1]; st.State != StateExited {
+			t.Errorf("PID %d %v on %q", pid, st.State, st.WaitingOn)
+		}
+	}
+}
+
+func TestKillWhileWaitingOnDevice(t *testing.T) {
+	s := newTestScheduler()
+	sleeper := s.Spawn(&ProcessSpec{Name: "sleeper", Program: []Op{Sleep(3), FileWrite("woke", "x")}})
+	gone := s.Spawn(&ProcessSpec{Name: "gone", Program: []Op{TTYRead(), FileWrite("gone", "")}})
+	reader := s.Spawn(&ProcessSpec{Name: "reader", Program: []Op{TTYRead(), FileWrite("line", "")}})
+	s.Step(3)
+	s.Kill(sleeper)
+	s.Kill(gone)
+	s.Type("ok\n")
+	runToEnd(s)
+
+	files := s.DumpFS()
+	if _, ok := files["woke"]; ok {
+		t.Error("killed sleeper ran after its timer")
+	}
+	if _, ok := files["gone"]; ok || files["line"] != "ok" {
+		t.Errorf("files = %v, want the line to go to the live reader", files)
+	}
+	if st := irqStat(s, DevTimer); st.Count != 1 {
+		t.Errorf("timer = %+v", st)
+	}
+	if st := s.Stats()[reader-1]; st.State != StateExited {
+		t.Errorf("reader %v", st.State)
+	}
+}
+
+func TestIRQStatsListEveryDevice(t *testing.T) {
+	var names []string
+	for _, st := range newTestScheduler().IRQStats() {
+		names = append(names, st.Device)
+	}
+	if want := []string{"timer", "disk", "net", "tty"}; !slices.Equal(names, want) {
+		t.Errorf("devices = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,280 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"gosimos/fs"
+	"gosimos/ipc"
+)
+
+var (
+	ErrBadFd      = errors.New("bad file descriptor")
+	ErrBrokenPipe = errors.New("broken pipe")
+	ErrNoFifo     = errors.New("no such fifo")
+	ErrSegv       = errors.New("segmentation fault")
+)
+
+type fdesc struct {
+	pipe  *ipc.Pipe
+	write bool
+}
+
+// fault terminates the running process p with err as its exit reason; the
+// scheduler loop reaps it once Run returns.
+func (s *Scheduler) fault(p *Process, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.exitReason = err.Error()
+}
+
+func (s *Scheduler) wakeAll(pids []int) {
+	for _, pid := range pids {
+		if p, ok := s.procs[pid]; ok && p.state == StateBlocked {
+			s.wake(p)
+		}
+	}
+}
+
+func (s *Scheduler) pipeCreate(p *Process, rfd, wfd, capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pipeSeq++
+	pp := ipc.NewPipe(fmt.Sprintf("pipe:%d", s.pipeSeq), capacity)
+	s.pipes = append(s.pipes, pp)
+	s.installFdLocked(p, rfd, &fdesc{pipe: pp})
+	s.installFdLocked(p, wfd, &fdesc{pipe: pp, write: true})
+}
+
+func (s *Scheduler) installFdLocked(p *Process, fd int, d *fdesc) {
+	if old, ok := p.fds[fd]; ok {
+		s.closeFdLocked(p, fd, old)
+	}
+	d.pipe.Open(d.write)
+	p.fds[fd] = d
+	if d.write {
+		s.wakeAll(d.pipe.TakeReaders())
+	} else {
+		s.wakeAll(d.pipe.TakeWriters())
+	}
+}
+
+func (s *Scheduler) closeFdLocked(p *Process, fd int, d *fdesc) {
+	delete(p.fds, fd)
+	d.pipe.Close(d.write)
+	if d.write && d.pipe.Writers() == 0 {
+		s.wakeAll(d.pipe.TakeReaders())
+	}
+	if !d.write && d.pipe.Readers() == 0 {
+		s.wakeAll(d.pipe.TakeWriters())
+	}
+}
+
+// spawnChild forks spec as a child of parent; the child inherits a copy of
+// the parent's descriptor table, like fork(2) followed by exec.
+func (s *Scheduler) spawnChild(parent *Process, spec *ProcessSpec) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	child := NewProcess(s.nextPID+1, spec)
+	err := s.setUserLocked(child, spec, parent.user)
+	var g *cgroup
+	if err == nil {
+		g, err = s.joinCgroupLocked(spec, parent.cgroup)
+	}
+	if err != nil {
+		s.klogLocked(LogNotice, "proc", parent.ID, "fork refused: %v", err)
+		return 0, err
+	}
+	s.nextPID++
+	child.cgroup = g
+	child.parentID = parent.ID
+	child.spawnedAt = s.now
+	if spec.RT != nil {
+		s.newRTLocked(child, *spec.RT)
+	}
+	for fd, d := range parent.fds {
+		dup := *d
+		dup.pipe.Open(dup.write)
+		child.fds[fd] = &dup
+	}
+	s.placeLocked(child, false)
+	s.enqueueLocked(child)
+	s.procs[child.ID] = child
+	s.mailboxes[child.ID] = []ipc.Message{}
+	s.procEventLocked(child, Observer.OnSpawn)
+	s.klogLocked(LogDebug, "proc", child.ID, "forked %q from PID %d", child.Name, parent.ID)
+	s.memChargeLocked(child, spec.Memory)
+	return child.ID, nil
+}
+
+func (s *Scheduler) fdClose(p *Process, fd int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := p.fds[fd]
+	if !ok {
+		return ErrBadFd
+	}
+	s.closeFdLocked(p, fd, d)
+	return nil
+}
+
+// fdWrite writes data to a pipe, blocking while the buffer is full. Partial
+// progress is kept in p.opLeft so the op resumes where it stopped.
+func (s *Scheduler) fdWrite(p *Process, fd int, data string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := p.fds[fd]
+	if !ok || !d.write {
+		return false, ErrBadFd
+	}
+	pp := d.pipe
+	if pp.Broken() {
+		return false, ErrBrokenPipe
+	}
+	if pp.HadReader() {
+		if n := pp.Write(data[p.opLeft:]); n > 0 {
+			p.opLeft += n
+			s.wakeAll(pp.TakeReaders())
+		}
+		if p.opLeft == len(data) {
+			p.opLeft = 0
+			return true, nil
+		}
+	}
+	pp.WaitWrite(p.ID)
+	s.block(p, "pipe-write:"+pp.Name)
+	return false, nil
+}
+
+// fdRead returns up to max bytes, blocking while the pipe is empty and a
+// writer may still show up. At end of file it returns "" and done.
+func (s *Scheduler) fdRead(p *Process, fd, max int) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := p.fds[fd]
+	if !ok || d.write {
+		return "", false, ErrBadFd
+	}
+	pp := d.pipe
+	if pp.Buffered() > 0 {
+		data := pp.Read(max)
+		s.wakeAll(pp.TakeWriters())
+		return data, true, nil
+	}
+	if pp.EOF() {
+		return "", true, nil
+	}
+	pp.WaitRead(p.ID)
+	s.block(p, "pipe-read:"+pp.Name)
+	return "", false, nil
+}
+
+func (s *Scheduler) mkfifo(p *Process, path string, capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fs.Fifo(path) != nil {
+		return
+	}
+	pp := ipc.NewPipe(path, capacity)
+	s.fs.MkfifoAs(p.cred, path, pp)
+	s.pipes = append(s.pipes, pp)
+	s.notifyLocked(func(o Observer) { o.OnFSOp(s.now, p.ID, "mkfifo", path) })
+}
+
+func (s *Scheduler) openFifo(p *Process, path string, fd int, write bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pp := s.fs.Fifo(path)
+	if pp == nil {
+		return ErrNoFifo
+	}
+	want := fs.PermRead
+	if write {
+		want = fs.PermWrite
+	}
+	if err := s.fs.Access(p.cred, path, want); err != nil {
+		return err
+	}
+	s.installFdLocked(p, fd, &fdesc{pipe: pp, write: write})
+	s.notifyLocked(func(o Observer) { o.OnFSOp(s.now, p.ID, "open", path) })
+	return nil
+}
+
+func (s *Scheduler) shmAttach(p *Process, name string, size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sg, ok := s.segments[name]
+	if !ok {
+		sg = ipc.NewSegment(name, size)
+		s.segments[name] = sg
+	}
+	sg.Attach(p.proc().ID)
+}
+
+func (s *Scheduler) shmDetach(p *Process, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sg, ok := s.segments[name]; ok {
+		sg.Detach(p.proc().ID)
+	}
+}
+
+func (s *Scheduler) shmWrite(p *Process, name string, off int, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sg, ok := s.segments[name]
+	if !ok || !sg.IsAttached(p.proc().ID) || !sg.Write(off, data) {
+		return ErrSegv
+	}
+	return nil
+}
+
+func (s *Scheduler) shmRead(p *Process, name string, off, n int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sg, ok := s.segments[name]
+	if !ok || !sg.IsAttached(p.proc().ID) {
+		return "", ErrSegv
+	}
+	data, ok := sg.Read(off, n)
+	if !ok {
+		return "", ErrSegv
+	}
+	return data, nil
+}
+
+// closeAllLocked closes p's descriptors and detaches its segments on exit.
+// A thread's are its process's and stay open.
+func (s *Scheduler) closeAllLocked(p *Process) {
+	if p.leader != nil {
+		return
+	}
+	for _, fd := range slices.Sorted(maps.Keys(p.fds)) {
+		s.closeFdLocked(p, fd, p.fds[fd])
+	}
+	for _, sg := range s.segments {
+		sg.Detach(p.ID)
+	}
+}
+
+func (s *Scheduler) PipeStats() []ipc.PipeStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ipc.PipeStat, 0, len(s.pipes))
+	for _, pp := range s.pipes {
+		out = append(out, pp.Stat())
+	}
+	return out
+}
+
+func (s *Scheduler) SegmentStats() []ipc.SegmentStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ipc.SegmentStat, 0, len(s.segments))
+	for _, name := range slices.Sorted(maps.Keys(s.segments)) {
+		out = append(out, s.segments[name].Stat())
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package sched
+
+import (
+	"testing"
+	"time"
+
+	"gosimos/ipc"
+)
+
+func pipeStat(stats []ipc.PipeStat, name string) ipc.PipeStat {
+	for _, st := range stats {
+		if st.Name == name {
+			return st
+		}
+	}
+	return ipc.PipeStat{}
+}
+
+func TestPipelineDeliversEveryItem(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	Pipeline(s, 10)
+	s.Start()
+	stats := waitExited(t, s, 2*time.Second)
+
+	for _, st := range stats {
+		if st.ExitReason != "" {
+			t.Errorf("%s exited with %q", st.Name, st.ExitReason)
+		}
+	}
+	for _, p := range s.PipeStats() {
+		if p.BytesIn != 50 || p.BytesOut != 50 || p.Buffered != 0 {
+			t.Errorf("%s: in=%d out=%d buffered=%d, want 50/50/0", p.Name, p.BytesIn, p.BytesOut, p.Buffered)
+		}
+	}
+	if got, _ := s.fs.ReadFile("pipeline.out"); got != "item;" {
+		t.Errorf("pipeline.out = %q", got)
+	}
+}
+
+func TestPipeWriterBlocksOnFullBuffer(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	reader := &ProcessSpec{Name: "reader", Program: []Op{FdClose(4), Compute(3), FdRead(3, 0), FdRead(3, 0), FileWrite("tail", "")}}
+	s.Spawn(&ProcessSpec{Name: "writer", Program: []Op{
+		PipeCreate(3, 4, 4),
+		SpawnChild(reader),
+		FdClose(3),
+		FdWrite(4, "abcdefgh"),
+	}})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	p := pipeStat(s.PipeStats(), "pipe:1")
+	if p.WriteStall == 0 || p.BytesOut != 8 {
+		t.Errorf("write stalls=%d bytes out=%d, want >0 and 8", p.WriteStall, p.BytesOut)
+	}
+	if got, _ := s.fs.ReadFile("tail"); got != "efgh" {
+		t.Errorf("second read = %q, want %q", got, "efgh")
+	}
+}
+
+func TestWriteWithoutReaderIsBrokenPipe(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	pid := s.Spawn(&ProcessSpec{Name: "lonely", Program: []Op{PipeCreate(3, 4, 0), FdClose(3), FdWrite(4, "x")}})
+	s.Start()
+	for _, st := range waitExited(t, s, 2*time.Second) {
+		if st.ID == pid && st.ExitReason != ErrBrokenPipe.Error() {
+			t.Errorf("exit reason = %q, want %q", st.ExitReason, ErrBrokenPipe)
+		}
+	}
+}
+
+func TestFifoConnectsUnrelatedProcesses(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	s.Spawn(&ProcessSpec{Name: "reader", Program: []Op{Mkfifo("/tmp/q", 0), OpenFifo("/tmp/q", 0, false), FdRead(0, 0), FileWrite("got", ""), FdRead(0, 0)}})
+	s.Spawn(&ProcessSpec{Name: "writer", Program: []Op{Compute(2), OpenFifo("/tmp/q", 1, true), FdWrite(1, "hello")}})
+	s.Start()
+	for _, st := range waitExited(t, s, 2*time.Second) {
+		if st.ExitReason != "" {
+			t.Errorf("%s exited with %q", st.Name, st.ExitReason)
+		}
+	}
+	if got, _ := s.fs.ReadFile("got"); got != "hello" {
+		t.Errorf("fifo read = %q", got)
+	}
+}
+
+func TestSharedMemoryRequiresAttach(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	SharedMemory(s, 5)
+	rogue := s.Spawn(&ProcessSpec{Name: "rogue", Program: []Op{Compute(1), ShmRead("buf", 0, 1)}})
+	s.Start()
+	for _, st := range waitExited(t, s, 2*time.Second) {
+		if st.ID == rogue && st.ExitReason != ErrSegv.Error() {
+			t.Errorf("rogue exit reason = %q, want %q", st.ExitReason, ErrSegv)
+		}
+	}
+	segs := s.SegmentStats()
+	if len(segs) != 1 || segs[0].BytesIn != 25 || segs[0].BytesOut != 25 || len(segs[0].Attached) != 0 {
+		t.Errorf("segments = %+v", segs)
+	}
+}
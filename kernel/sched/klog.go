@@ -0,0 +1,159 @@
+package sched
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// LogLevel is how serious a kernel log entry is, from LogDebug up.
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogNotice
+	LogWarn
+	LogErr
+)
+
+var logLevels = [...]string{"debug", "info", "notice", "warn", "err"}
+
+func (l LogLevel) String() string {
+	if l < 0 || int(l) >= len(logLevels) {
+		return "unknown"
+	}
+	return logLevels[l]
+}
+
+func ParseLogLevel(name string) (LogLevel, error) {
+	for l, n := range logLevels {
+		if n == name {
+			return LogLevel(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// Slog maps l onto slog's levels. Notices fall between info and warn,
+// and print as INFO+2.
+func (l LogLevel) Slog() slog.Level {
+	switch l {
+	case LogDebug:
+		return slog.LevelDebug
+	case LogNotice:
+		return slog.LevelInfo + 2
+	case LogWarn:
+		return slog.LevelWarn
+	case LogErr:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// defaultLogSize is how many entries the kernel log keeps by default.
+const defaultLogSize = 1024
+
+// LogEntry is one line of the kernel log. PID is 0 for messages about
+// the system as a whole.
+type LogEntry struct {
+	Seq    uint64
+	Time   time.Duration // simulated
+	Level  LogLevel
+	Subsys string
+	PID    int `json:",omitempty"`
+	Msg    string
+}
+
+// String formats e like dmesg(1): seconds of simulated time, level,
+// subsystem, then the PID if there is one.
+func (e LogEntry) String() string {
+	pid := ""
+	if e.PID != 0 {
+		pid = fmt.Sprintf("[%d] ", e.PID)
+	}
+	return fmt.Sprintf("[%12.6f] %-6s %s: %s%s", e.Time.Seconds(), e.Level, e.Subsys, pid, e.Msg)
+}
+
+// LogFilter selects kernel log entries: at Level or above, and from
+// Subsys and PID when they are set.
+type LogFilter struct {
+	Level  LogLevel
+	Subsys string
+	PID    int
+}
+
+func (f LogFilter) match(e LogEntry) bool {
+	return e.Level >= f.Level && (f.Subsys == "" || e.Subsys == f.Subsys) && (f.PID == 0 || e.PID == f.PID)
+}
+
+// klogLocked appends an entry to the kernel log, dropping the oldest
+// once it is full, and mirrors it to the slog logger if there is one.
+func (s *Scheduler) klogLocked(level LogLevel, subsys string, pid int, format string, args ...any) {
+	s.klogSeq++
+	e := LogEntry{Seq: s.klogSeq, Time: s.now, Level: level, Subsys: subsys, PID: pid, Msg: fmt.Sprintf(format, args...)}
+	if len(s.klog) == s.klogSize {
+		s.klog = append(s.klog[:0], s.klog[1:]...)
+	}
+	s.klog = append(s.klog, e)
+	if s.logger != nil {
+		attrs := []slog.Attr{slog.Duration("t", e.Time), slog.String("subsys", subsys)}
+		if pid != 0 {
+			attrs = append(attrs, slog.Int("pid", pid))
+		}
+		s.logger.LogAttrs(context.Background(), level.Slog(), e.Msg, attrs...)
+	}
+}
+
+// SetLogger mirrors kernel log entries to l from now on; nil stops it.
+func (s *Scheduler) SetLogger(l *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = l
+}
+
+// Logf adds a message from outside the kernel, such as the operator's
+// tools, to the kernel log.
+func (s *Scheduler) Logf(level LogLevel, subsys, format string, args ...any) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := LogEntry{Level: level, Subsys: subsys, Msg: fmt.Sprintf(format, args...)}
+	s.recordLocked(Event{Kind: EvLog, Log: &e})
+	s.klogLocked(level, subsys, 0, "%s", e.Msg)
+}
+
+// Dmesg returns the kernel log entries f selects, oldest first.
+func (s *Scheduler) Dmesg(f LogFilter) []LogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dmesgLocked(f)
+}
+
+func (s *Scheduler) dmesgLocked(f LogFilter) []LogEntry {
+	var out []LogEntry
+	for _, e := range s.klog {
+		if f.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func formatLog(entries []LogEntry) string {
+	b := &strings.Builder{}
+	for _, e := range entries {
+		b.WriteString(e.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// SaveLog writes the entries f selects to path, one per line.
+func (s *Scheduler) SaveLog(path string, f LogFilter) error {
+	return os.WriteFile(path, []byte(formatLog(s.Dmesg(f))), 0o644)
+}
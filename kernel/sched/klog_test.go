@@ -0,0 +1,89 @@
+package sched
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func logLines(entries []LogEntry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Subsys+" "+e.Msg)
+	}
+	return out
+}
+
+func TestKernelLogRecordsKillsAndFilters(t *testing.T) {
+	s := newTestScheduler()
+	if err := s.NewCgroup(CgroupSpec{Name: "web", MemLimit: 1024}); err != nil {
+		t.Fatal(err)
+	}
+	leak := s.Spawn(&ProcessSpec{Name: "leak", Cgroup: "web", Program: Repeat(4, Compute(1), Alloc(512))})
+	idle := s.Spawn(&ProcessSpec{Name: "idle", Program: []Op{Receive("never")}})
+	runToEnd(s)
+	s.Kill(idle)
+	s.Logf(LogInfo, "shell", "done")
+
+	if got, want := logLines(s.Dmesg(LogFilter{Level: LogNotice})), []string{
+		`mm out of memory in cgroup web (1536 of 1024 bytes), killing "leak"`,
+		`proc "leak" killed: oom-killed: cgroup web over 1024 bytes`,
+		`proc "idle" killed: killed`,
+	}; !slices.Equal(got, want) {
+		t.Errorf("notices:\n%q\nwant\n%q", got, want)
+	}
+	if got := s.Dmesg(LogFilter{Subsys: "mm"}); len(got) != 1 || got[0].PID != leak || got[0].Level != LogErr || got[0].Time != 3*time.Millisecond {
+		t.Errorf("mm entries = %+v", got)
+	}
+	if got := logLines(s.Dmesg(LogFilter{PID: idle})); !slices.Equal(got, []string{`proc spawned "idle"`, `proc "idle" killed: killed`}) {
+		t.Errorf("PID %d entries = %q", idle, got)
+	}
+	all := s.Dmesg(LogFilter{})
+	if got, want := all[0].String(), "[    0.000000] info   sched: policy priority, quantum 1ms, unit 1ms"; got != want {
+		t.Errorf("first entry = %q, want %q", got, want)
+	}
+	if last := all[len(all)-1]; last.String() != "[    0.003000] info   shell: done" {
+		t.Errorf("last entry = %q", last)
+	}
+	if kmsg, _ := s.ReadFile(ProcDir + "/kmsg"); kmsg != formatLog(all) {
+		t.Errorf("/proc/kmsg =\n%s\nwant\n%s", kmsg, formatLog(all))
+	}
+}
+
+func TestKernelLogKeepsTheNewestEntries(t *testing.T) {
+	s := NewScheduler(WithUnit(time.Millisecond), WithLogSize(3))
+	for range 5 {
+		s.Spawn(&ProcessSpec{Name: "p", WorkUnits: 1})
+	}
+	got := s.Dmesg(LogFilter{})
+	if len(got) != 3 || got[0].Seq != 4 || got[2].Seq != 6 || got[2].PID != 5 {
+		t.Errorf("log = %+v, want spawns of PIDs 3 to 5", got)
+	}
+}
+
+func TestKernelLogGoesToSlogAndFiles(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithLogger(logger))
+	s.Spawn(&ProcessSpec{Name: "p", Program: []Op{FdClose(3)}})
+	s.Logf(LogWarn, "shell", "disk %d%% full", 90)
+	runToEnd(s)
+
+	if out := buf.String(); !strings.Contains(out, `level=WARN msg="disk 90% full" t=0s subsys=shell`) || strings.Contains(out, "level=INFO") {
+		t.Errorf("slog output = %q, want the warning and nothing below it", out)
+	}
+	path := filepath.Join(t.TempDir(), "dmesg.txt")
+	f := LogFilter{Subsys: "syscall"}
+	if err := s.SaveLog(path, f); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "[    0.000000] debug  syscall: [1] close(3) = -1 EBADF (bad file descriptor)\n"; string(data) != want {
+		t.Errorf("saved %q, want %q", data, want)
+	}
+}
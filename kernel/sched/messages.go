@@ -0,0 +1,295 @@
+package sched
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"gosimos/ipc"
+)
+
+var ErrNoProcess = errors.New("no such process")
+
+// recvFilter selects messages for a blocking receive: by type, by
+// correlation ID, or anything when both are zero.
+type recvFilter struct {
+	typ  string
+	corr uint64
+}
+
+func (f recvFilter) match(m ipc.Message) bool {
+	if f.corr != 0 {
+		return m.CorrID == f.corr
+	}
+	return f.typ == "" || m.Type == f.typ
+}
+
+// Now reports the simulated clock.
+func (s *Scheduler) Now() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Quantum is the time slice a process runs before it is preempted.
+func (s *Scheduler) Quantum() time.Duration {
+	return s.quantum
+}
+
+// account charges units of completed work to p and advances the clock.
+func (s *Scheduler) account(p *Process, units int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.WorkUnits -= units
+	s.now += time.Duration(units) * s.unit
+	if p.uts != nil {
+		p.uts[p.ucur].CPU += time.Duration(units) * s.unit
+	}
+}
+
+func (s *Scheduler) SendMessage(from, to int, msg ipc.Message) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvSend, PID: from, N: to, Msg: &msg})
+	msg.From, msg.To = from, to
+	s.deliverLocked(msg)
+}
+
+// deliverLocked stamps msg and appends it to the receiver's mailbox,
+// waking the receiver if it is blocked on a matching receive. A message to
+// a thread goes to its process, and wakes the first of its threads
+// waiting for it.
+func (s *Scheduler) deliverLocked(msg ipc.Message) uint64 {
+	if q, ok := s.procs[msg.To]; ok {
+		msg.To = q.proc().ID
+	}
+	if _, ok := s.mailboxes[msg.To]; !ok {
+		return 0
+	}
+	if msg.Type == "" {
+		msg.Type = "text"
+	}
+	s.msgSeq++
+	msg.Seq = s.msgSeq
+	msg.SentAt = s.now
+	s.mailboxes[msg.To] = append(s.mailboxes[msg.To], msg)
+	s.typeStatLocked(msg.To, msg.Type).Delivered++
+	s.flows[[2]int{msg.From, msg.To}]++
+	s.notifyLocked(func(o Observer) { o.OnMessage(s.now, msg) })
+
+	if lead, ok := s.procs[msg.To]; ok {
+		for _, p := range append([]*Process{lead}, lead.threads...) {
+			if p.state == StateBlocked && p.recv != nil && p.recv.match(msg) {
+				p.recv = nil
+				s.wake(p)
+				break
+			}
+		}
+	}
+	return msg.Seq
+}
+
+func (s *Scheduler) typeStatLocked(pid int, typ string) *ipc.TypeStat {
+	byType, ok := s.mailStats[pid]
+	if !ok {
+		byType = make(map[string]*ipc.TypeStat)
+		s.mailStats[pid] = byType
+	}
+	ts, ok := byType[typ]
+	if !ok {
+		ts = &ipc.TypeStat{}
+		byType[typ] = ts
+	}
+	return ts
+}
+
+// Broadcast delivers msg to every live process except the sender that
+// the sender may signal, and returns how many received it.
+func (s *Scheduler) Broadcast(from int, msg ipc.Message) int {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvBroadcast, PID: from, Msg: &msg})
+	return s.broadcastLocked(from, msg)
+}
+
+func (s *Scheduler) broadcastLocked(from int, msg ipc.Message) int {
+	n := 0
+	for _, pid := range slices.Sorted(maps.Keys(s.procs)) {
+		if q := s.procs[pid]; pid == from || q.leader != nil || q.state == StateExited || !s.maySignalLocked(from, q) {
+			continue
+		}
+		msg.From, msg.To = from, pid
+		s.deliverLocked(msg)
+		n++
+	}
+	return n
+}
+
+func (s *Scheduler) JoinGroup(pid int, group string) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvJoin, PID: pid, Arg: group})
+	s.joinGroupLocked(pid, group)
+}
+
+func (s *Scheduler) joinGroupLocked(pid int, group string) {
+	members, ok := s.groups[group]
+	if !ok {
+		members = make(map[int]bool)
+		s.groups[group] = members
+	}
+	members[pid] = true
+}
+
+func (s *Scheduler) LeaveGroup(pid int, group string) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvLeave, PID: pid, Arg: group})
+	delete(s.groups[group], pid)
+}
+
+// Multicast delivers msg to every member of group other than the sender,
+// as far as the sender may signal them.
+func (s *Scheduler) Multicast(from int, group string, msg ipc.Message) int {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvMulticast, PID: from, Arg: group, Msg: &msg})
+	return s.multicastLocked(from, group, msg)
+}
+
+func (s *Scheduler) multicastLocked(from int, group string, msg ipc.Message) int {
+	n := 0
+	for _, pid := range slices.Sorted(maps.Keys(s.groups[group])) {
+		if q, ok := s.procs[pid]; pid == from || ok && !s.maySignalLocked(from, q) {
+			continue
+		}
+		msg.From, msg.To = from, pid
+		s.deliverLocked(msg)
+		n++
+	}
+	return n
+}
+
+func (s *Scheduler) Groups() map[string][]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string][]int, len(s.groups))
+	for name, members := range s.groups {
+		ids := make([]int, 0, len(members))
+		for pid := range members {
+			ids = append(ids, pid)
+		}
+		sort.Ints(ids)
+		out[name] = ids
+	}
+	return out
+}
+
+// receive removes the oldest message in p's mailbox that matches f. When
+// none does, p blocks until a matching message is delivered.
+func (s *Scheduler) receive(p *Process, f recvFilter) (ipc.Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pid := p.proc().ID
+	box := s.mailboxes[pid]
+	for i, m := range box {
+		if !f.match(m) {
+			continue
+		}
+		s.mailboxes[pid] = append(box[:i:i], box[i+1:]...)
+		m.RecvAt = s.now
+		ts := s.typeStatLocked(pid, m.Type)
+		ts.Received++
+		delay := m.RecvAt - m.SentAt
+		ts.TotalDelay += delay
+		ts.MaxDelay = max(ts.MaxDelay, delay)
+		return m, true
+	}
+	p.recv = &f
+	on := "recv:" + f.typ
+	if f.corr != 0 {
+		on = "reply"
+	}
+	s.block(p, on)
+	return ipc.Message{}, false
+}
+
+// send delivers a program's message: to a PID, a group ("*" for
+// everyone), or, when no target is given, as a reply to the last message
+// the process received.
+// Groups only reach the members p may signal; a unicast to anyone else
+// fails with ErrPermission.
+func (s *Scheduler) send(p *Process, op Op, data string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from := p.proc().ID
+	msg := ipc.Message{From: from, Type: op.Name, Payload: []byte(data)}
+	switch op.Group {
+	case "":
+	case "*":
+		s.broadcastLocked(from, msg)
+		return 0, nil
+	default:
+		s.multicastLocked(from, op.Group, msg)
+		return 0, nil
+	}
+	if op.Units == 0 && p.lastMsg != nil {
+		msg.To = p.lastMsg.From
+		msg.CorrID = p.lastMsg.Seq
+	} else {
+		msg.To = op.Units
+	}
+	if q, ok := s.procs[msg.To]; ok && !s.maySignalLocked(from, q) {
+		return 0, ErrPermission
+	}
+	return s.deliverLocked(msg), nil
+}
+
+// Flows counts delivered messages per sender and receiver, ordered by
+// sender then receiver.
+func (s *Scheduler) Flows() []ipc.Flow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flowsLocked()
+}
+
+func (s *Scheduler) flowsLocked() []ipc.Flow {
+	out := make([]ipc.Flow, 0, len(s.flows))
+	for k, n := range s.flows {
+		out = append(out, ipc.Flow{From: k[0], To: k[1], Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].From != out[j].From {
+			return out[i].From < out[j].From
+		}
+		return out[i].To < out[j].To
+	})
+	return out
+}
+
+func (s *Scheduler) MailboxStats() []ipc.MailboxStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ipc.MailboxStat, 0, len(s.mailboxes))
+	for pid, box := range s.mailboxes {
+		st := ipc.MailboxStat{PID: pid, Pending: len(box), ByType: map[string]ipc.TypeStat{}}
+		for typ, ts := range s.mailStats[pid] {
+			st.ByType[typ] = *ts
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PID < out[j].PID })
+	return out
+}
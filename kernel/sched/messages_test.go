@@ -0,0 +1,94 @@
+package sched
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gosimos/ipc"
+)
+
+func mailStat(stats []ipc.MailboxStat, pid int) ipc.MailboxStat {
+	for _, st := range stats {
+		if st.PID == pid {
+			return st
+		}
+	}
+	return ipc.MailboxStat{}
+}
+
+func TestSelectiveReceiveByType(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	rx := s.Spawn(&ProcessSpec{Name: "rx", Program: []Op{Receive("b"), FileWrite("first", "")}})
+	s.Spawn(&ProcessSpec{Name: "tx", Priority: 1, Program: []Op{Send(rx, "a", "skip me"), Compute(2), Send(rx, "b", "take me")}})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	if got, _ := s.fs.ReadFile("first"); got != "take me" {
+		t.Errorf("received %q, want the type b message", got)
+	}
+	box := s.DumpMailboxes()[rx]
+	if len(box) != 1 || box[0].Type != "a" {
+		t.Errorf("mailbox = %+v, want the unmatched type a message left", box)
+	}
+	ts := mailStat(s.MailboxStats(), rx).ByType["b"]
+	if ts.Received != 1 || ts.MaxDelay != 0 {
+		t.Errorf("type b stats = %+v, want one receive with no delay", ts)
+	}
+}
+
+func TestCallMatchesReplyByCorrelationID(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	RPC(s, 3, 2)
+	s.Start()
+	stats := waitExited(t, s, 2*time.Second)
+
+	for _, st := range stats {
+		if st.ExitReason != "" {
+			t.Errorf("%s exited with %q", st.Name, st.ExitReason)
+		}
+	}
+	for _, st := range s.MailboxStats() {
+		if st.Pending != 0 {
+			t.Errorf("PID %d has %d unread messages", st.PID, st.Pending)
+		}
+	}
+	server := mailStat(s.MailboxStats(), stats[0].ID).ByType["req"]
+	if server.Received != 6 {
+		t.Errorf("server received %d requests, want 6", server.Received)
+	}
+	if server.MaxDelay == 0 {
+		t.Errorf("queued requests should show send-to-receive latency")
+	}
+}
+
+func TestBroadcastAndMulticast(t *testing.T) {
+	s := newTestScheduler()
+	a := s.Spawn(&ProcessSpec{Name: "a", WorkUnits: 1})
+	b := s.Spawn(&ProcessSpec{Name: "b", WorkUnits: 1})
+	c := s.Spawn(&ProcessSpec{Name: "c", WorkUnits: 1})
+	s.JoinGroup(b, "g")
+	s.JoinGroup(c, "g")
+
+	if n := s.Broadcast(a, ipc.Message{Type: "hello"}); n != 2 {
+		t.Errorf("broadcast reached %d processes, want 2", n)
+	}
+	if n := s.Multicast(b, "g", ipc.Message{Type: "gossip"}); n != 1 {
+		t.Errorf("multicast reached %d processes, want 1", n)
+	}
+	boxes := s.DumpMailboxes()
+	if len(boxes[a]) != 0 || len(boxes[b]) != 1 || len(boxes[c]) != 2 {
+		t.Fatalf("mailbox sizes a=%d b=%d c=%d, want 0 1 2", len(boxes[a]), len(boxes[b]), len(boxes[c]))
+	}
+	if m := boxes[c]; m[0].Seq >= m[1].Seq || m[1].From != b || m[1].Type != "gossip" {
+		t.Errorf("c mailbox = %+v", m)
+	}
+	want := []ipc.Flow{{From: a, To: b, Count: 1}, {From: a, To: c, Count: 1}, {From: b, To: c, Count: 1}}
+	if got := s.Flows(); !slices.Equal(got, want) {
+		t.Errorf("flows = %+v, want %+v", got, want)
+	}
+}
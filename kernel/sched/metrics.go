@@ -0,0 +1,66 @@
+package sched
+
+import (
+	"slices"
+	"time"
+)
+
+// WaitBuckets are the upper bounds, in simulated time, of the wait-time
+// histograms.
+var WaitBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// Histogram counts durations into buckets. Counts[i] is the number of
+// observations no larger than Bounds[i]; the last count is everything
+// above the largest bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []int
+	Sum    time.Duration
+	Count  int
+}
+
+func newHistogram(bounds []time.Duration) *Histogram {
+	return &Histogram{Bounds: slices.Clone(bounds), Counts: make([]int, len(bounds)+1)}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(h.Bounds, d)
+	h.Counts[i]++
+	h.Sum += d
+	h.Count++
+}
+
+func (h *Histogram) clone() Histogram {
+	return Histogram{Bounds: slices.Clone(h.Bounds), Counts: slices.Clone(h.Counts), Sum: h.Sum, Count: h.Count}
+}
+
+// ContextSwitches counts dispatches that ran a different process from the
+// one before.
+func (s *Scheduler) ContextSwitches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.switches
+}
+
+// ReadyWait is how long processes sat in the ready queue before each
+// dispatch; BlockWait is how long each block lasted until the wake-up.
+func (s *Scheduler) ReadyWait() Histogram {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readyWait.clone()
+}
+
+func (s *Scheduler) BlockWait() Histogram {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blockWait.clone()
+}
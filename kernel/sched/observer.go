@@ -0,0 +1,90 @@
+package sched
+
+import (
+	"time"
+
+	"gosimos/ipc"
+)
+
+// Observer is told about kernel events as they happen. Callbacks run with
+// the kernel lock held, on whichever goroutine caused the event: they must
+// return quickly and must not call back into the Scheduler. Embed
+// BaseObserver to implement only some of them.
+type Observer interface {
+	OnSpawn(now time.Duration, p ProcessStat)
+	OnDispatch(now time.Duration, p ProcessStat)
+	OnPreempt(now time.Duration, p ProcessStat) // quantum used up, back to the ready queue
+	OnBlock(now time.Duration, p ProcessStat)   // p.WaitingOn says on what
+	OnWake(now time.Duration, p ProcessStat)
+	OnExit(now time.Duration, p ProcessStat)
+	OnMessage(now time.Duration, msg ipc.Message)
+	OnFSOp(now time.Duration, pid int, op, path string)
+}
+
+// BaseObserver implements Observer with callbacks that do nothing.
+type BaseObserver struct{}
+
+func (BaseObserver) OnSpawn(time.Duration, ProcessStat)        {}
+func (BaseObserver) OnDispatch(time.Duration, ProcessStat)     {}
+func (BaseObserver) OnPreempt(time.Duration, ProcessStat)      {}
+func (BaseObserver) OnBlock(time.Duration, ProcessStat)        {}
+func (BaseObserver) OnWake(time.Duration, ProcessStat)         {}
+func (BaseObserver) OnExit(time.Duration, ProcessStat)         {}
+func (BaseObserver) OnMessage(time.Duration, ipc.Message)      {}
+func (BaseObserver) OnFSOp(time.Duration, int, string, string) {}
+
+// AddObserver registers o for every event from now on. Observers are not
+// part of a checkpoint.
+func (s *Scheduler) AddObserver(o Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, o)
+}
+
+// RemoveObserver unregisters o and reports whether it was registered. It
+// compares observers with ==, so register pointers.
+func (s *Scheduler) RemoveObserver(o Observer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, x := range s.observers {
+		if x == o {
+			s.observers = append(s.observers[:i], s.observers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scheduler) notifyLocked(f func(Observer)) {
+	for _, o := range s.observers {
+		f(o)
+	}
+}
+
+// procEventLocked hands p's current stats to one callback per observer.
+func (s *Scheduler) procEventLocked(p *Process, cb func(Observer, time.Duration, ProcessStat)) {
+	if len(s.observers) == 0 {
+		return
+	}
+	st := p.stat()
+	for _, o := range s.observers {
+		cb(o, s.now, st)
+	}
+}
+
+// fsWrite is a process writing a file through the kernel, with its
+// permissions.
+func (s *Scheduler) fsWrite(p *Process, name, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fsWriteLocked(p, name, data)
+}
+
+func (s *Scheduler) fsWriteLocked(p *Process, name, data string) error {
+	if err := s.fs.WriteFileAs(p.cred, name, data); err != nil {
+		return err
+	}
+	p.fsWrites = append(p.fsWrites, name)
+	s.notifyLocked(func(o Observer) { o.OnFSOp(s.now, p.ID, "write", name) })
+	return nil
+}
@@ -0,0 +1,73 @@
+package sched
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"gosimos/ipc"
+)
+
+// traceObserver is the test assertion plugin: it logs every event as a line.
+type traceObserver struct {
+	BaseObserver
+	lines []string
+}
+
+func (o *traceObserver) add(format string, args ...any) {
+	o.lines = append(o.lines, fmt.Sprintf(format, args...))
+}
+
+func (o *traceObserver) OnSpawn(_ time.Duration, p ProcessStat) { o.add("spawn %d", p.ID) }
+func (o *traceObserver) OnPreempt(_ time.Duration, p ProcessStat) {
+	o.add("preempt %d", p.ID)
+}
+func (o *traceObserver) OnBlock(_ time.Duration, p ProcessStat) {
+	o.add("block %d %s", p.ID, p.WaitingOn)
+}
+func (o *traceObserver) OnWake(_ time.Duration, p ProcessStat) { o.add("wake %d", p.ID) }
+func (o *traceObserver) OnExit(_ time.Duration, p ProcessStat) { o.add("exit %d", p.ID) }
+func (o *traceObserver) OnMessage(_ time.Duration, m ipc.Message) {
+	o.add("msg %d->%d %s", m.From, m.To, m.Type)
+}
+func (o *traceObserver) OnFSOp(_ time.Duration, pid int, op, path string) {
+	o.add("fs %d %s %s", pid, op, path)
+}
+
+func TestObserverSeesKernelEvents(t *testing.T) {
+	o := &traceObserver{}
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithObserver(o))
+	rx := s.Spawn(&ProcessSpec{Name: "rx", Program: []Op{Receive("ping"), FileWrite("got", "")}})
+	s.Spawn(&ProcessSpec{Name: "tx", Priority: 1, Program: []Op{
+		SpawnChild(&ProcessSpec{Name: "child", WorkUnits: 1}),
+		Compute(2),
+		Send(rx, "ping", "hi"),
+	}})
+	for s.Step(100) > 0 {
+	}
+
+	want := []string{
+		"spawn 1", "spawn 2",
+		"block 1 recv:ping",
+		"spawn 3",
+		"preempt 2",
+		"exit 3",
+		"preempt 2", // Compute(2) takes two 1-unit quanta
+		"msg 2->1 ping", "wake 1",
+		"exit 2",
+		"fs 1 write got",
+		"exit 1",
+	}
+	if !slices.Equal(o.lines, want) {
+		t.Errorf("events:\n%q\nwant\n%q", o.lines, want)
+	}
+
+	if !s.RemoveObserver(o) || s.RemoveObserver(o) {
+		t.Errorf("RemoveObserver should succeed exactly once")
+	}
+	s.Spawn(&ProcessSpec{Name: "late", WorkUnits: 1})
+	if n := len(o.lines); n != len(want) {
+		t.Errorf("removed observer still got %q", o.lines[len(want):])
+	}
+}
@@ -0,0 +1,91 @@
+package sched
+
+import (
+	"log/slog"
+	"maps"
+	"time"
+
+	"gosimos/fs"
+)
+
+// Option configures a Scheduler in NewScheduler.
+type Option func(*Scheduler)
+
+// WithQuantum sets how long a process may run before it is preempted.
+func WithQuantum(d time.Duration) Option {
+	return func(s *Scheduler) { s.quantum = d }
+}
+
+// WithPolicy selects the scheduling policy; the default is PolicyPriority.
+func WithPolicy(pol Policy) Option {
+	return func(s *Scheduler) { s.policy = pol }
+}
+
+// WithCFS tunes PolicyCFS: the target latency within which every ready
+// process should run once, and the shortest slice it is cut into. They
+// default to six quanta and one quantum.
+func WithCFS(latency, granularity time.Duration) Option {
+	return func(s *Scheduler) { s.cfsLatency, s.cfsGranularity = latency, granularity }
+}
+
+// WithDevices sets the timings of the simulated hardware.
+func WithDevices(cfg DeviceConfig) Option {
+	return func(s *Scheduler) { s.devices = cfg }
+}
+
+// WithSwap sets the physical memory and the swap area; see SwapConfig.
+func WithSwap(cfg SwapConfig) Option {
+	return func(s *Scheduler) { s.swap = cfg }
+}
+
+// WithSyscallCosts sets what each system call costs in simulated time;
+// calls not in costs are free, as all are by default. See
+// DefaultSyscallCosts.
+func WithSyscallCosts(costs map[Sysno]time.Duration) Option {
+	return func(s *Scheduler) { s.sysCosts = maps.Clone(costs) }
+}
+
+// WithUnit sets how much simulated time one unit of work takes.
+func WithUnit(d time.Duration) Option {
+	return func(s *Scheduler) { s.unit = d }
+}
+
+// WithSeed seeds the simulation's random number generator.
+func WithSeed(seed uint64) Option {
+	return func(s *Scheduler) { s.rng.Seed(seed, 0) }
+}
+
+// WithDeadlockDetection is SetDeadlockDetection at construction.
+func WithDeadlockDetection(every int, recover bool) Option {
+	return func(s *Scheduler) { s.deadlockEvery, s.deadlockRecover = every, recover }
+}
+
+// WithBankers turns on Banker's-algorithm avoidance, see EnableBankers.
+func WithBankers() Option {
+	return func(s *Scheduler) { s.bankers = true }
+}
+
+// WithFS runs the scheduler on an existing file system, e.g. one that is
+// shared with the caller or pre-populated. Only the first scheduler on a
+// file system mounts its /proc there; later ones leave it to the owner.
+func WithFS(f *fs.SimFS) Option {
+	return func(s *Scheduler) { s.fs = f }
+}
+
+// WithLogSize sets how many entries the kernel log keeps, 1024 by
+// default.
+func WithLogSize(n int) Option {
+	return func(s *Scheduler) { s.klogSize = max(n, 1) }
+}
+
+// WithLogger mirrors every kernel log entry to l as it is written, e.g.
+// to a slog.TextHandler on stderr; see SetLogger. Like observers it is
+// not part of a checkpoint.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Scheduler) { s.logger = l }
+}
+
+// WithObserver registers o from the first event on, see AddObserver.
+func WithObserver(o Observer) Option {
+	return func(s *Scheduler) { s.observers = append(s.observers, o) }
+}
@@ -0,0 +1,314 @@
+package sched
+
+import (
+	"fmt"
+	"time"
+
+	"gosimos/fs"
+	"gosimos/ipc"
+)
+
+type Behavior int
+
+const (
+	BehaviorCompute Behavior = iota
+	BehaviorIPCSender
+	BehaviorFSWriter
+)
+
+type ProcState int
+
+const (
+	StateReady ProcState = iota
+	StateRunning
+	StateBlocked
+	StateExited
+)
+
+func (st ProcState) String() string {
+	switch st {
+	case StateReady:
+		return "ready"
+	case StateRunning:
+		return "running"
+	case StateBlocked:
+		return "blocked"
+	case StateExited:
+		return "exited"
+	}
+	return "unknown"
+}
+
+type ProcessSpec struct {
+	Name      string
+	Priority  int //0 -> High
+	WorkUnits int
+	Behavior  Behavior
+	Program   []Op           // when set, replaces Behavior and WorkUnits
+	MaxNeed   map[string]int // Banker's claims, keyed by mutex/semaphore name
+	RT        *RTSpec        // when set, a real-time task; WorkUnits, Behavior and Program are ignored
+	Cgroup    string         // control group, default the parent's
+	Memory    int            // resident bytes at spawn
+	User      string         // account to run as, default the parent's
+	Threads   ThreadModel    // how ThreadCreate's threads are scheduled
+}
+
+// Process fields fall into two groups. Everything observers can see
+// (WorkUnits, RunCount, TotalCPU, state and the kernel bookkeeping below
+// it) is only written with the scheduler's mu held. The execution context
+// at the bottom belongs to whichever goroutine is running the scheduler
+// loop and is only touched from inside Run.
+type Process struct {
+	ID       int
+	Name     string
+	Priority int
+	Behavior Behavior
+	Program  []Op
+
+	WorkUnits  int
+	RunCount   int
+	TotalCPU   time.Duration
+	totalUnits int
+	state      ProcState
+	waitingOn  string
+	exitReason string
+	maxNeed    map[string]int
+	parentID   int
+	fds        map[int]*fdesc
+	recv       *recvFilter
+	rt         *rtState
+	vruntime   time.Duration // CFS: CPU time weighted by nice
+	rqPrev     *Process      // run queue links, see runqueue.go
+	rqNext     *Process
+	rqIndex    int
+	rqSeq      uint64
+	rqWeight   int64 // weight when queued in the CFS tree
+	cgroup     *cgroup
+	user       string
+	cred       fs.Cred
+	io         *ioReq          // device request in flight
+	mem        int             // resident bytes
+	swap       procSwap        // whether it is swapped out, and how often it was
+	sysCalls   map[Sysno]int   // calls made, by number
+	sysTime    time.Duration   // charged as system call cost
+	cpuTime    time.Duration   // simulated time on the CPU, sysTime included
+	strace     []SyscallRecord // the last straceLimit calls
+	spawnedAt  time.Duration   // simulated clock at spawn
+	readyAt    time.Duration   // ... at the last enqueue
+	blockedAt  time.Duration   // ... at the last block
+	model      ThreadModel
+	leader     *Process   // main thread of a kernel thread's process
+	threads    []*Process // kernel threads started, on the main thread
+	uts        []*uthread // user-level threads, the running one at ucur
+	ucur       int
+
+	pc       int
+	opLeft   int
+	acc      string // last data read from a pipe, segment or message
+	lastMsg  *ipc.Message
+	callSeq  uint64
+	sysOp    *Op // retried call that blocked, entered again when woken
+	fsWrites []string
+}
+
+func NewProcess(id int, spec *ProcessSpec) *Process {
+	p := &Process{
+		ID:        id,
+		Name:      spec.Name,
+		Priority:  spec.Priority,
+		WorkUnits: spec.WorkUnits,
+		Behavior:  spec.Behavior,
+		Program:   spec.Program,
+		maxNeed:   spec.MaxNeed,
+		model:     spec.Threads,
+		fds:       make(map[int]*fdesc),
+	}
+	if p.Program != nil {
+		p.WorkUnits = programUnits(p.Program)
+	}
+	p.totalUnits = p.WorkUnits
+	return p
+}
+
+func (p *Process) cgroupName() string {
+	if p.cgroup == nil {
+		return ""
+	}
+	return p.cgroup.Name
+}
+
+// stat is p as observers see it; callers hold the scheduler's mu.
+func (p *Process) stat() ProcessStat {
+	return ProcessStat{
+		ID:         p.ID,
+		Name:       p.Name,
+		Priority:   p.Priority,
+		RunCount:   p.RunCount,
+		TotalCPU:   p.TotalCPU,
+		CPUTime:    p.cpuTime,
+		Remaining:  p.WorkUnits,
+		State:      p.state,
+		WaitingOn:  p.waitingOn,
+		ExitReason: p.exitReason,
+		Parent:     p.parentID,
+		VRuntime:   p.vruntime,
+		Cgroup:     p.cgroupName(),
+		Memory:     p.mem,
+		User:       p.user,
+		UID:        p.cred.UID,
+		Syscalls:   p.syscallCounts(),
+		SysTime:    p.sysTime,
+		Swapped:    p.swap.Out,
+		SwapIns:    p.swap.Ins,
+		SwapOuts:   p.swap.Outs,
+		SwapTime:   p.swap.Time,
+		TGID:       p.proc().ID,
+		Threads:    p.liveThreads(),
+	}
+}
+
+// syscallCounts is p.sysCalls keyed by name, nil if p made no calls.
+func (p *Process) syscallCounts() map[string]int {
+	if len(p.sysCalls) == 0 {
+		return nil
+	}
+	out := make(map[string]int, len(p.sysCalls))
+	for nr, n := range p.sysCalls {
+		out[nr.String()] = n
+	}
+	return out
+}
+
+func (p *Process) syscallTotal() int {
+	n := 0
+	for _, c := range p.sysCalls {
+		n += c
+	}
+	return n
+}
+
+func (p *Process) unitsDone() int {
+	return p.totalUnits - p.WorkUnits
+}
+
+func (p *Process) Run(quantum time.Duration, sched *Scheduler) ProcState {
+	unit := sched.unit
+
+	maxUnits := int(quantum / unit)
+	if maxUnits < 1 {
+		maxUnits = 1
+	}
+
+	if p.rt != nil {
+		return p.runRT(maxUnits, unit, sched)
+	}
+	if p.Program != nil {
+		return p.runProgram(maxUnits, unit, sched)
+	}
+
+	if p.sysOp != nil {
+		sched.syscall(p, *p.sysOp) // woken by the disk: the last write landed
+		if p.WorkUnits <= 0 {
+			return StateExited
+		}
+	}
+
+	remaining := p.WorkUnits
+	if remaining < 0 {
+		return StateExited
+	}
+
+	toRun := remaining
+	if toRun > maxUnits {
+		toRun = maxUnits
+	}
+
+	time.Sleep(time.Duration(toRun) * unit)
+	sched.account(p, toRun)
+
+	switch p.Behavior {
+	case BehaviorIPCSender:
+		if target := 1; target != p.ID {
+			sched.syscall(p, Send(target, "text", fmt.Sprintf("MSG from %s at %v", p.Name, sched.Now()-p.spawnedAt)))
+		}
+	case BehaviorFSWriter:
+		name := fmt.Sprintf("file_%d.txt", p.ID)
+		content := fmt.Sprintf("Data written by %s at %v", p.Name, sched.Now())
+		if r := sched.syscall(p, DiskWrite(name, content)); r.blocked {
+			return StateBlocked
+		}
+	}
+
+	if p.WorkUnits <= 0 {
+		return StateExited
+	}
+	return StateReady
+}
+
+// runProgram interprets p.Program from its program counter until the
+// quantum is used up, the process blocks on a kernel object, or it exits.
+// Every op but Compute is a system call. Lock-style calls advance the pc
+// first: ownership is handed over on wake-up. Retried calls (pipes,
+// messages, devices) leave the pc alone and are entered again when woken.
+// The thread ops of a process with UserThreads never reach the kernel:
+// its thread library switches contexts in place.
+func (p *Process) runProgram(maxUnits int, unit time.Duration, sched *Scheduler) ProcState {
+	used := 0
+
+	for {
+		if p.pc >= len(p.Program) {
+			if p.uts != nil && sched.userThreadOp(p, ThreadExit()) {
+				continue
+			}
+			return StateExited
+		}
+		op := p.Program[p.pc]
+		switch op.Kind {
+		case OpCompute:
+			if p.opLeft == 0 {
+				p.opLeft = op.Units
+			}
+			n := p.opLeft
+			if n > maxUnits-used {
+				n = maxUnits - used
+			}
+			time.Sleep(time.Duration(n) * unit)
+			sched.account(p, n)
+			used += n
+			p.opLeft -= n
+			if p.opLeft > 0 {
+				return StateReady
+			}
+			p.pc++
+		case OpThread, OpThreadJoin, OpThreadExit, OpYield:
+			if p.model == UserThreads {
+				if !sched.userThreadOp(p, op) {
+					return StateExited
+				}
+				break
+			}
+			fallthrough
+		default:
+			r := sched.syscall(p, op)
+			if r.fatal || r.killed && !r.blocked || r.exit {
+				return StateExited
+			}
+			if r.blocked && syscalls[op.Kind].retry {
+				return StateBlocked
+			}
+			p.pc++
+			if r.read {
+				p.acc = r.data
+			}
+			if r.blocked {
+				return StateBlocked
+			}
+			if r.yield {
+				return StateReady
+			}
+		}
+		if used >= maxUnits && p.pc < len(p.Program) {
+			return StateReady
+		}
+	}
+}
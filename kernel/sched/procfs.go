@@ -0,0 +1,253 @@
+package sched
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosimos/fs"
+)
+
+// ProcDir is where the scheduler mounts its /proc file system.
+const ProcDir = "/proc"
+
+// loadWindows are the load average periods: 1, 5 and 15 seconds at the
+// default 100ms unit. Linux's minutes would hardly move in a simulation.
+var loadWindows = [3]int{10, 50, 150}
+
+// procFS generates /proc from the scheduler's state. The file system may
+// be shared with the caller (WithFS), who can read /proc through it at any
+// time, so its methods take s.mu themselves: nothing may call into the
+// file system for a /proc path with s.mu held.
+type procFS struct{ s *Scheduler }
+
+var procFiles = []string{"status", "stat", "fd"}
+
+func (pf procFS) List() []string {
+	pf.s.mu.Lock()
+	defer pf.s.mu.Unlock()
+	out := []string{"kmsg", "loadavg", "meminfo", "sched_debug"}
+	for _, pid := range slices.Sorted(maps.Keys(pf.s.procs)) {
+		for _, f := range procFiles {
+			out = append(out, fmt.Sprintf("%d/%s", pid, f))
+		}
+	}
+	return out
+}
+
+func (pf procFS) Read(name string) (string, bool) {
+	s := pf.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch name {
+	case "kmsg":
+		return formatLog(s.klog), true
+	case "loadavg":
+		return s.loadavgLocked(), true
+	case "meminfo":
+		return s.meminfoLocked(), true
+	case "sched_debug":
+		return s.schedDebugLocked(), true
+	}
+	dir, file, _ := strings.Cut(name, "/")
+	pid, err := strconv.Atoi(dir)
+	p, ok := s.procs[pid]
+	if err != nil || !ok {
+		return "", false
+	}
+	switch file {
+	case "status":
+		return s.procStatusLocked(p), true
+	case "stat":
+		return s.procStatLocked(p), true
+	case "fd":
+		return procFds(p), true
+	}
+	return "", false
+}
+
+// procRead is p reading a /proc file, which comes from memory rather
+// than the disk. /proc/self is p's own directory.
+func (s *Scheduler) procRead(p *Process, path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, ProcDir+"/self/"); ok {
+		path = fmt.Sprintf("%s/%d/%s", ProcDir, p.ID, rest)
+	}
+	data, ok := s.fs.ReadFile(path)
+	if !ok {
+		return "", fs.ErrNotExist
+	}
+	return data, nil
+}
+
+// ReadFile returns a file's content, /proc included, without counting
+// it as a file system call.
+func (s *Scheduler) ReadFile(path string) (string, error) {
+	data, ok := s.fs.Lookup(path)
+	if !ok {
+		return "", fs.ErrNotExist
+	}
+	return data, nil
+}
+
+// ReadDir lists the files under dir, "" for every stored one and
+// ProcDir for /proc.
+func (s *Scheduler) ReadDir(dir string) []string {
+	return s.fs.List(strings.TrimSuffix(dir, "/"))
+}
+
+func procState(p *Process) string {
+	switch p.state {
+	case StateReady:
+		return "R (ready)"
+	case StateRunning:
+		return "R (running)"
+	case StateBlocked:
+		return "S (sleeping)"
+	}
+	return "X (dead)"
+}
+
+func (s *Scheduler) procStatusLocked(p *Process) string {
+	b := &strings.Builder{}
+	field := func(name string, v any) { fmt.Fprintf(b, "%s:\t%v\n", name, v) }
+	field("Name", p.Name)
+	field("State", procState(p))
+	field("Tgid", p.proc().ID)
+	field("Pid", p.ID)
+	field("PPid", p.parentID)
+	field("Uid", p.cred.UID)
+	field("Gid", p.cred.GID)
+	field("Cgroup", cmp.Or(p.cgroupName(), "/"))
+	field("Priority", p.Priority)
+	field("Nice", p.Nice())
+	field("Threads", p.liveThreads())
+	lead := p.proc()
+	rss, swapped := lead.mem, 0
+	if lead.swap.Out {
+		rss, swapped = 0, lead.mem
+	}
+	field("VmRSS", fmt.Sprintf("%d B", rss))
+	field("VmSwap", fmt.Sprintf("%d B", swapped))
+	field("Dispatches", p.RunCount)
+	field("CpuTime", p.cpuTime)
+	field("SysTime", p.sysTime)
+	field("Syscalls", p.syscallTotal())
+	if p.waitingOn != "" {
+		field("Wchan", p.waitingOn)
+	}
+	if p.exitReason != "" {
+		field("Exit", p.exitReason)
+	}
+	return b.String()
+}
+
+// procStatLocked is one line like Linux's /proc/<pid>/stat, with fewer
+// fields: pid (comm) state ppid utime stime priority nice dispatches
+// starttime vruntime rss. Times are nanoseconds of simulated time.
+func (s *Scheduler) procStatLocked(p *Process) string {
+	return fmt.Sprintf("%d (%s) %c %d %d %d %d %d %d %d %d %d\n",
+		p.ID, p.Name, procState(p)[0], p.parentID,
+		int64(p.cpuTime-p.sysTime), int64(p.sysTime), p.Priority, p.Nice(),
+		p.RunCount, int64(p.spawnedAt), int64(p.vruntime), p.mem)
+}
+
+func procFds(p *Process) string {
+	b := &strings.Builder{}
+	for _, fd := range slices.Sorted(maps.Keys(p.fds)) {
+		d := p.fds[fd]
+		end := "read"
+		if d.write {
+			end = "write"
+		}
+		fmt.Fprintf(b, "%d -> %s (%s)\n", fd, d.pipe.Name, end)
+	}
+	return b.String()
+}
+
+// loadTickLocked folds the time since the last tick into the load
+// averages: exponentially damped means of the ready queue length, those
+// waiting to be swapped in included, as Linux counts disk waits.
+func (s *Scheduler) loadTickLocked() {
+	dt := s.now - s.loadAt
+	if dt <= 0 {
+		return
+	}
+	n := float64(s.readyLenLocked() + len(s.swapQ))
+	for i, w := range loadWindows {
+		e := math.Exp(-float64(dt) / float64(time.Duration(w)*s.unit))
+		s.loadAvg[i] = s.loadAvg[i]*e + n*(1-e)
+	}
+	s.loadAt = s.now
+	s.swapTickLocked()
+}
+
+// LoadAvg is the 1, 5 and 15 second load average (at the default unit).
+func (s *Scheduler) LoadAvg() [3]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadAvg
+}
+
+func (s *Scheduler) loadavgLocked() string {
+	running, live := 0, 0
+	for _, p := range s.procs {
+		if p.state != StateExited {
+			live++
+		}
+		if p.state == StateReady || p.state == StateRunning {
+			running++
+		}
+	}
+	l := s.loadAvg
+	return fmt.Sprintf("%.2f %.2f %.2f %d/%d %d\n", l[0], l[1], l[2], running, live, s.nextPID)
+}
+
+func (s *Scheduler) meminfoLocked() string {
+	var shm, piped, files int
+	used := s.residentLocked()
+	for _, sg := range s.segments {
+		shm += sg.Stat().Size
+	}
+	for _, pp := range s.pipes {
+		piped += pp.Buffered()
+	}
+	for _, name := range s.fs.List("") {
+		files += s.fs.Size(name)
+	}
+	type line struct {
+		name string
+		n    int
+	}
+	lines := []line{{"MemUsed", used}, {"Shmem", shm}, {"PipeBuffers", piped}, {"Files", files}}
+	if sw := s.swap; sw.Memory > 0 {
+		lines = append([]line{{"MemTotal", sw.Memory}}, lines...)
+		lines = append(lines, line{"SwapTotal", sw.Size}, line{"SwapUsed", s.swapStats.Used})
+	}
+	b := &strings.Builder{}
+	for _, f := range lines {
+		fmt.Fprintf(b, "%-14s%10d B\n", f.name+":", f.n)
+	}
+	return b.String()
+}
+
+func (s *Scheduler) schedDebugLocked() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "now: %v\npolicy: %s\nquantum: %v\ndispatches: %d\nswitches: %d\nnr_running: %d\nmin_vruntime: %v\n",
+		s.now, s.policy, s.quantum, s.dispatches, s.switches, s.readyLenLocked(), s.minVruntime)
+	fmt.Fprintf(b, "ready: %v\n\n", pids(s.readyListLocked()))
+	fmt.Fprintf(b, "S %5s %-16s %4s %12s %10s %s\n", "PID", "NAME", "PRIO", "VRUNTIME", "DISPATCHES", "CGROUP")
+	for _, pid := range slices.Sorted(maps.Keys(s.procs)) {
+		p := s.procs[pid]
+		if p.state == StateExited {
+			continue
+		}
+		fmt.Fprintf(b, "%c %5d %-16s %4d %12v %10d %s\n",
+			procState(p)[0], p.ID, p.Name, p.Priority, p.vruntime, p.RunCount, cmp.Or(p.cgroupName(), "/"))
+	}
+	return b.String()
+}
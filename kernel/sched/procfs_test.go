@@ -0,0 +1,127 @@
+package sched
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gosimos/fs"
+)
+
+func TestProcessesReadProc(t *testing.T) {
+	s := newTestScheduler()
+	if err := s.AddUser(User{Name: "alice", UID: 1000, GID: 100}); err != nil {
+		t.Fatal(err)
+	}
+	pid := s.Spawn(&ProcessSpec{Name: "probe", User: "alice", Memory: 2048, Program: []Op{
+		PipeCreate(3, 4, 0),
+		DiskRead("/proc/self/status"), FileWrite("status", ""),
+		DiskRead("/proc/1/fd"), FileWrite("fd", ""),
+		DiskRead("/proc/1/stat"), FileWrite("stat", ""),
+		DiskRead("/proc/9/status"),
+		FileWrite("/proc/loadavg", "0"),
+		Chmod("/proc/meminfo", 0o666),
+	}})
+	runToEnd(s)
+
+	files := s.DumpFS()
+	for _, want := range []string{"Name:\tprobe\n", "State:\tR (running)\n", "Pid:\t1\n", "Uid:\t1000\n", "VmRSS:\t2048 B\n", "Syscalls:\t2\n"} {
+		if !strings.Contains(files["status"], want) {
+			t.Errorf("status lacks %q:\n%s", want, files["status"])
+		}
+	}
+	if want := "3 -> pipe:1 (read)\n4 -> pipe:1 (write)\n"; files["fd"] != want {
+		t.Errorf("fd = %q, want %q", files["fd"], want)
+	}
+	if !strings.HasPrefix(files["stat"], "1 (probe) R 0 ") || !strings.HasSuffix(files["stat"], " 2048\n") {
+		t.Errorf("stat = %q", files["stat"])
+	}
+	got := straceLines(t, s, pid)
+	if want := []string{
+		`disk_read("/proc/9/status") = -1 ENOENT (no such file or directory)`,
+		`writefile("/proc/loadavg", "0") = -1 EACCES (permission denied)`,
+		`chmod("/proc/meminfo", 0666) = -1 EPERM (operation not permitted)`,
+	}; !slices.Equal(got[len(got)-3:], want) {
+		t.Errorf("strace ends\n%q\nwant\n%q", got[len(got)-3:], want)
+	}
+	if st := irqStat(s, DevDisk); st.Count != 0 {
+		t.Errorf("/proc reads raised %d disk interrupts", st.Count)
+	}
+}
+
+func TestSharedFSKeepsTheFirstProc(t *testing.T) {
+	f := fs.NewSimFS()
+	first := NewScheduler(WithFS(f))
+	first.Spawn(&ProcessSpec{Name: "owner", WorkUnits: 1})
+	second := NewScheduler(WithFS(f))
+	second.Spawn(&ProcessSpec{Name: "guest", WorkUnits: 1})
+
+	if data, _ := f.Lookup(ProcDir + "/1/status"); !strings.Contains(data, "Name:\towner\n") {
+		t.Errorf("/proc/1/status = %q, want the first scheduler's process", data)
+	}
+	if log := second.Dmesg(LogFilter{Level: LogWarn}); len(log) != 1 || !strings.Contains(log[0].Msg, "already mounted") {
+		t.Errorf("second scheduler's log = %v, want a warning about /proc", log)
+	}
+}
+
+func TestLoadAverageFollowsTheReadyQueue(t *testing.T) {
+	s := newTestScheduler()
+	for range 4 {
+		s.Spawn(&ProcessSpec{Name: "busy", WorkUnits: 100})
+	}
+	s.Step(200)
+	l := s.LoadAvg()
+	if l[0] < 3.9 || l[0] > 4 || !(l[0] > l[1] && l[1] > l[2]) {
+		t.Errorf("load average %v with four busy processes, want the 1s one near 4 and the others lagging", l)
+	}
+	data, err := s.ReadFile(ProcDir + "/loadavg")
+	if err != nil || !strings.HasSuffix(data, " 4/4 4\n") {
+		t.Errorf("loadavg = %q, %v", data, err)
+	}
+}
+
+func TestOperatorBrowsesProc(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&ProcessSpec{Name: "w", WorkUnits: 1})
+	names := s.ReadDir(ProcDir)
+	if !slices.Equal(names, []string{"/proc/1/fd", "/proc/1/stat", "/proc/1/status", "/proc/kmsg", "/proc/loadavg", "/proc/meminfo", "/proc/sched_debug"}) {
+		t.Errorf("ReadDir(/proc) = %q", names)
+	}
+	data, err := s.ReadFile(ProcDir + "/sched_debug")
+	if err != nil || !strings.Contains(data, "nr_running: 1\n") || !strings.Contains(data, "ready: [1]\n") {
+		t.Errorf("sched_debug = %q, %v", data, err)
+	}
+	if _, err := s.ReadFile(ProcDir + "/2/status"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile of a missing PID = %v", err)
+	}
+	if len(s.FSOps()) != 0 {
+		t.Errorf("browsing counted as file system calls: %v", s.FSOps())
+	}
+}
+
+func TestSharedFSReadsProcWhileRunning(t *testing.T) {
+	f := fs.NewSimFS()
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(100*time.Microsecond), WithFS(f))
+	defer s.Stop()
+	for i := 0; i < 4; i++ {
+		s.Spawn(&ProcessSpec{Name: "w", Priority: i % 2, Program: Repeat(20, Compute(1), Sleep(1))})
+	}
+	s.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for reads := 0; ; reads++ {
+		if _, ok := f.Lookup(ProcDir + "/sched_debug"); !ok {
+			t.Fatal("sched_debug missing from the shared fs")
+		}
+		for _, name := range f.List(ProcDir) {
+			f.Lookup(name)
+		}
+		if reads > 0 && !slices.ContainsFunc(s.Stats(), func(st ProcessStat) bool { return st.State != StateExited }) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("workload did not finish")
+		}
+	}
+}
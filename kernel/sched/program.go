@@ -0,0 +1,193 @@
+package sched
+
+import "gosimos/fs"
+
+type OpKind int
+
+const (
+	OpCompute OpKind = iota
+	OpLock
+	OpUnlock
+	OpSemWait
+	OpSemPost
+	OpCondWait
+	OpCondSignal
+	OpCondBroadcast
+	OpFlock
+	OpFunlock
+	OpWrite
+	OpPipe
+	OpSpawn
+	OpFdRead
+	OpFdWrite
+	OpFdClose
+	OpMkfifo
+	OpOpen
+	OpShmAttach
+	OpShmDetach
+	OpShmWrite
+	OpShmRead
+	OpSend
+	OpRecv
+	OpCall
+	OpJoin
+	OpLeave
+	OpAlloc
+	OpSleep
+	OpDiskRead
+	OpDiskWrite
+	OpNetSend
+	OpTTYRead
+	OpKill
+	OpChmod
+	OpThread
+	OpThreadJoin
+	OpThreadExit
+	OpYield
+)
+
+// Op is a single instruction of a process program. Name is the kernel
+// object (mutex, semaphore, condition variable, file path or shared memory
+// segment) it refers to. Ops that write data with an empty Data field use
+// whatever the process last read from a pipe or segment instead.
+type Op struct {
+	Kind   OpKind
+	Name   string
+	Units  int
+	Mode   LockMode
+	Mutex  string
+	Data   string
+	Fd     int
+	Fd2    int
+	Offset int
+	Write  bool
+	Group  string
+	Child  *ProcessSpec
+}
+
+func Compute(units int) Op { return Op{Kind: OpCompute, Units: units} }
+
+func MutexLock(name string) Op   { return Op{Kind: OpLock, Name: name} }
+func MutexUnlock(name string) Op { return Op{Kind: OpUnlock, Name: name} }
+
+func SemWait(name string) Op { return Op{Kind: OpSemWait, Name: name} }
+func SemPost(name string) Op { return Op{Kind: OpSemPost, Name: name} }
+
+func CondWait(cond, mutex string) Op { return Op{Kind: OpCondWait, Name: cond, Mutex: mutex} }
+func CondSignal(cond string) Op      { return Op{Kind: OpCondSignal, Name: cond} }
+func CondBroadcast(cond string) Op   { return Op{Kind: OpCondBroadcast, Name: cond} }
+
+func FileLock(path string, mode LockMode) Op { return Op{Kind: OpFlock, Name: path, Mode: mode} }
+func FileUnlock(path string) Op              { return Op{Kind: OpFunlock, Name: path} }
+func FileWrite(path, data string) Op         { return Op{Kind: OpWrite, Name: path, Data: data} }
+
+// PipeCreate opens an anonymous pipe with read end rfd and write end wfd;
+// capacity 0 selects the default buffer size.
+func PipeCreate(rfd, wfd, capacity int) Op {
+	return Op{Kind: OpPipe, Fd: rfd, Fd2: wfd, Units: capacity}
+}
+
+// SpawnChild starts spec as a child process that inherits open descriptors.
+func SpawnChild(spec *ProcessSpec) Op { return Op{Kind: OpSpawn, Child: spec} }
+
+func FdRead(fd, max int) Op          { return Op{Kind: OpFdRead, Fd: fd, Units: max} }
+func FdWrite(fd int, data string) Op { return Op{Kind: OpFdWrite, Fd: fd, Data: data} }
+func FdClose(fd int) Op              { return Op{Kind: OpFdClose, Fd: fd} }
+
+func Mkfifo(path string, capacity int) Op { return Op{Kind: OpMkfifo, Name: path, Units: capacity} }
+func OpenFifo(path string, fd int, write bool) Op {
+	return Op{Kind: OpOpen, Name: path, Fd: fd, Write: write}
+}
+
+func ShmAttach(name string, size int) Op { return Op{Kind: OpShmAttach, Name: name, Units: size} }
+func ShmDetach(name string) Op           { return Op{Kind: OpShmDetach, Name: name} }
+func ShmWrite(name string, off int, data string) Op {
+	return Op{Kind: OpShmWrite, Name: name, Offset: off, Data: data}
+}
+func ShmRead(name string, off, n int) Op {
+	return Op{Kind: OpShmRead, Name: name, Offset: off, Units: n}
+}
+
+func Send(to int, typ, data string) Op { return Op{Kind: OpSend, Units: to, Name: typ, Data: data} }
+
+// Reply answers the last message received, correlating with its Seq.
+func Reply(typ, data string) Op { return Op{Kind: OpSend, Name: typ, Data: data} }
+
+func BroadcastMsg(typ, data string) Op { return Op{Kind: OpSend, Group: "*", Name: typ, Data: data} }
+func MulticastMsg(group, typ, data string) Op {
+	return Op{Kind: OpSend, Group: group, Name: typ, Data: data}
+}
+
+// Receive blocks until a message of type typ arrives ("" matches any).
+func Receive(typ string) Op { return Op{Kind: OpRecv, Name: typ} }
+
+// Call sends a request and blocks until the matching reply arrives.
+func Call(to int, typ, data string) Op { return Op{Kind: OpCall, Units: to, Name: typ, Data: data} }
+
+func Join(group string) Op  { return Op{Kind: OpJoin, Group: group} }
+func Leave(group string) Op { return Op{Kind: OpLeave, Group: group} }
+
+// Alloc grows the process's resident memory by n bytes; Free shrinks it.
+func Alloc(n int) Op { return Op{Kind: OpAlloc, Units: n} }
+func Free(n int) Op  { return Op{Kind: OpAlloc, Units: -n} }
+
+// Sleep blocks for units of simulated time, until a timer interrupt.
+func Sleep(units int) Op { return Op{Kind: OpSleep, Units: units} }
+
+// DiskRead and DiskWrite go through the simulated disk and block until
+// its completion interrupt; DiskRead leaves the file's content to be
+// written by later ops.
+func DiskRead(path string) Op        { return Op{Kind: OpDiskRead, Name: path} }
+func DiskWrite(path, data string) Op { return Op{Kind: OpDiskWrite, Name: path, Data: data} }
+
+// NetSend is Send over the simulated network: the message arrives with
+// the receive interrupt, NetLatency later. With to 0 it replies.
+func NetSend(to int, typ, data string) Op {
+	return Op{Kind: OpNetSend, Units: to, Name: typ, Data: data}
+}
+
+// TTYRead blocks until a line has been typed, see Scheduler.Type.
+func TTYRead() Op { return Op{Kind: OpTTYRead} }
+
+// KillProc terminates another process. Like kill(2) it needs root or
+// the same user as the target.
+func KillProc(pid int) Op { return Op{Kind: OpKill, Units: pid} }
+
+// Chmod sets a file's permission bits; only its owner or root may.
+func Chmod(path string, mode fs.Mode) Op { return Op{Kind: OpChmod, Name: path, Units: int(mode)} }
+
+// ThreadCreate starts a thread called name running prog in the process,
+// sharing its descriptors, messages and memory. The process's
+// ProcessSpec.Threads decides whether the kernel schedules it.
+func ThreadCreate(name string, prog ...Op) Op {
+	return Op{Kind: OpThread, Name: name, Child: &ProcessSpec{Name: name, Program: prog}}
+}
+
+// ThreadJoin waits for the process's thread called name to exit.
+func ThreadJoin(name string) Op { return Op{Kind: OpThreadJoin, Name: name} }
+
+// ThreadExit ends the calling thread; from the main thread it ends the
+// process. A thread's program running out does the same.
+func ThreadExit() Op { return Op{Kind: OpThreadExit} }
+
+// Yield gives up the rest of the quantum, or the CPU to the next user
+// thread.
+func Yield() Op { return Op{Kind: OpYield} }
+
+func Repeat(n int, ops ...Op) []Op {
+	out := make([]Op, 0, n*len(ops))
+	for i := 0; i < n; i++ {
+		out = append(out, ops...)
+	}
+	return out
+}
+
+func programUnits(prog []Op) int {
+	total := 0
+	for _, op := range prog {
+		if op.Kind == OpCompute {
+			total += op.Units
+		}
+	}
+	return total
+}
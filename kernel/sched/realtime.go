@@ -0,0 +1,315 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrNotSchedulable = errors.New("task set not schedulable")
+
+// Policy chooses which ready process runs next. Under EDF and RM,
+// processes without an RTSpec only run when no real-time job is ready, in
+// Priority order.
+type Policy int
+
+const (
+	// PolicyPriority runs the lowest Priority value first, round-robin
+	// within a level.
+	PolicyPriority Policy = iota
+	// PolicyEDF runs the real-time job with the earliest absolute deadline.
+	PolicyEDF
+	// PolicyRM runs the real-time task with the shortest period.
+	PolicyRM
+	// PolicyCFS runs the process with the least virtual runtime, for a
+	// slice of the target latency weighted by its nice value.
+	PolicyCFS
+)
+
+func (pol Policy) String() string {
+	switch pol {
+	case PolicyPriority:
+		return "priority"
+	case PolicyEDF:
+		return "edf"
+	case PolicyRM:
+		return "rm"
+	case PolicyCFS:
+		return "cfs"
+	}
+	return "unknown"
+}
+
+func ParsePolicy(name string) (Policy, error) {
+	for _, pol := range []Policy{PolicyPriority, PolicyEDF, PolicyRM, PolicyCFS} {
+		if pol.String() == name {
+			return pol, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scheduling policy %q", name)
+}
+
+// RTSpec makes a process a real-time task. A job of WCET execution time is
+// released every Period and should finish within Deadline of its release
+// (Deadline 0 means the period). Sporadic tasks are released at least
+// Period apart, with a random extra delay of up to one period.
+type RTSpec struct {
+	Period   time.Duration
+	WCET     time.Duration
+	Deadline time.Duration
+	Sporadic bool
+	Jobs     int // stop after this many jobs, 0 = run until killed
+}
+
+// RTStat reports on one real-time task. Response times run from release
+// to completion; Jitter is the spread between the fastest and slowest.
+type RTStat struct {
+	PID         int
+	Name        string
+	Period      time.Duration
+	WCET        time.Duration
+	Deadline    time.Duration
+	Jobs        int
+	Misses      int
+	MinResponse time.Duration
+	MaxResponse time.Duration
+	AvgResponse time.Duration
+	Jitter      time.Duration
+}
+
+// rtState is a task's spec plus its current job; exported fields because
+// it is checkpointed as is.
+type rtState struct {
+	Spec     RTSpec
+	Units    int           // WCET in work units
+	Release  time.Duration // current job
+	Deadline time.Duration // ... absolute
+	Next     time.Duration // next release while waiting for it
+	Missed   bool          // current job is past its deadline
+
+	Jobs      int
+	Misses    int
+	MinResp   time.Duration
+	MaxResp   time.Duration
+	TotalResp time.Duration
+}
+
+func (s *Scheduler) Policy() Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policy
+}
+
+// lessLocked orders the ready queue for the current policy.
+func (s *Scheduler) lessLocked(a, b *Process) bool {
+	if s.policy == PolicyCFS {
+		if a.vruntime != b.vruntime {
+			return a.vruntime < b.vruntime
+		}
+		return a.ID < b.ID
+	}
+	if (s.policy == PolicyEDF || s.policy == PolicyRM) && (a.rt != nil) != (b.rt != nil) {
+		return a.rt != nil
+	}
+	if a.rt != nil && b.rt != nil {
+		switch s.policy {
+		case PolicyEDF:
+			return a.rt.Deadline < b.rt.Deadline
+		case PolicyRM:
+			return a.rt.Spec.Period < b.rt.Spec.Period
+		}
+	}
+	return a.Priority < b.Priority
+}
+
+func (spec RTSpec) deadline() time.Duration {
+	if spec.Deadline > 0 {
+		return spec.Deadline
+	}
+	return spec.Period
+}
+
+// newRTLocked sets p up as a real-time task whose first job is released now.
+func (s *Scheduler) newRTLocked(p *Process, spec RTSpec) {
+	units := int((spec.WCET + s.unit - 1) / s.unit)
+	p.rt = &rtState{Spec: spec, Units: max(units, 1)}
+	p.WorkUnits, p.totalUnits = 0, 0
+	s.releaseLocked(p, s.now)
+	s.rtProcs = append(s.rtProcs, p)
+}
+
+func (s *Scheduler) releaseLocked(p *Process, at time.Duration) {
+	rt := p.rt
+	rt.Release, rt.Deadline, rt.Missed = at, at+rt.Spec.deadline(), false
+	p.WorkUnits = rt.Units
+	p.totalUnits += rt.Units
+}
+
+// nextReleaseLocked is the earliest release a real-time task waits for.
+func (s *Scheduler) nextReleaseLocked() (time.Duration, bool) {
+	next, waiting := time.Duration(math.MaxInt64), false
+	for _, p := range s.rtProcs {
+		if p.state == StateBlocked && p.waitingOn == "period" {
+			next, waiting = min(next, p.rt.Next), true
+		}
+	}
+	return next, waiting
+}
+
+// rtTickLocked releases the jobs that are due and flags jobs that have run
+// past their deadline.
+func (s *Scheduler) rtTickLocked() {
+	for _, p := range s.rtProcs {
+		rt := p.rt
+		if p.state == StateExited {
+			continue
+		}
+		if p.state == StateBlocked && p.waitingOn == "period" && rt.Next <= s.now {
+			s.releaseLocked(p, rt.Next)
+			s.wake(p)
+		}
+		if p.WorkUnits > 0 && !rt.Missed && s.now > rt.Deadline {
+			s.missLocked(p)
+		}
+	}
+}
+
+// missLocked flags p's current job as past its deadline.
+func (s *Scheduler) missLocked(p *Process) {
+	p.rt.Missed = true
+	p.rt.Misses++
+	s.klogLocked(LogWarn, "rt", p.ID, "%q missed the deadline at %v", p.Name, p.rt.Deadline)
+}
+
+// jobDone closes p's current job and either parks p until the next
+// release or, if that is already due, keeps it ready.
+func (s *Scheduler) jobDone(p *Process) ProcState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rt := p.rt
+	if !rt.Missed && s.now > rt.Deadline {
+		s.missLocked(p)
+	}
+	resp := s.now - rt.Release
+	if rt.Jobs == 0 || resp < rt.MinResp {
+		rt.MinResp = resp
+	}
+	rt.MaxResp = max(rt.MaxResp, resp)
+	rt.TotalResp += resp
+	rt.Jobs++
+	if rt.Spec.Jobs > 0 && rt.Jobs >= rt.Spec.Jobs {
+		return StateExited
+	}
+
+	rt.Next = rt.Release + rt.Spec.Period
+	if rt.Spec.Sporadic {
+		rt.Next += time.Duration(s.rand.IntN(int(rt.Spec.Period/s.unit)+1)) * s.unit
+	}
+	if rt.Next <= s.now {
+		s.releaseLocked(p, rt.Next)
+		return StateReady
+	}
+	s.block(p, "period")
+	return StateBlocked
+}
+
+// runRT executes the current job for up to one quantum.
+func (p *Process) runRT(maxUnits int, unit time.Duration, sched *Scheduler) ProcState {
+	n := min(p.WorkUnits, maxUnits)
+	time.Sleep(time.Duration(n) * unit)
+	sched.account(p, n)
+	if p.WorkUnits > 0 {
+		return StateReady
+	}
+	return sched.jobDone(p)
+}
+
+// admitRTLocked runs the schedulability test of the current policy on the
+// live real-time tasks plus spec: total density at most 1 for EDF; for RM
+// the Liu & Layland utilization bound, falling back to exact response-time
+// analysis when the bound fails or deadlines are shorter than periods.
+// Other policies admit everything.
+func (s *Scheduler) admitRTLocked(name string, spec RTSpec) error {
+	if spec.Period <= 0 || spec.WCET <= 0 {
+		return fmt.Errorf("%s: real-time task needs a positive period and WCET", name)
+	}
+	type task struct{ c, d, t time.Duration }
+	wcet := func(d time.Duration) time.Duration { return max((d+s.unit-1)/s.unit, 1) * s.unit }
+	var tasks []task
+	for _, p := range s.rtProcs {
+		if p.state != StateExited {
+			tasks = append(tasks, task{time.Duration(p.rt.Units) * s.unit, p.rt.Spec.deadline(), p.rt.Spec.Period})
+		}
+	}
+	tasks = append(tasks, task{wcet(spec.WCET), spec.deadline(), spec.Period})
+
+	switch s.policy {
+	case PolicyEDF:
+		density := 0.0
+		for _, tk := range tasks {
+			density += float64(tk.c) / float64(min(tk.d, tk.t))
+		}
+		if density > 1 {
+			return fmt.Errorf("%s: %w (EDF density %.2f > 1)", name, ErrNotSchedulable, density)
+		}
+	case PolicyRM:
+		util, implicit := 0.0, true
+		for _, tk := range tasks {
+			util += float64(tk.c) / float64(tk.t)
+			implicit = implicit && tk.d == tk.t
+		}
+		n := float64(len(tasks))
+		if implicit && util <= n*(math.Pow(2, 1/n)-1) {
+			return nil
+		}
+		for i, tk := range tasks {
+			// Higher priority: shorter period, or equal period and older.
+			r := tk.c
+			for {
+				next := tk.c
+				for j, hp := range tasks {
+					if hp.t < tk.t || (hp.t == tk.t && j < i) {
+						next += time.Duration(math.Ceil(float64(r)/float64(hp.t))) * hp.c
+					}
+				}
+				if next > tk.d {
+					return fmt.Errorf("%s: %w (RM response time %v > deadline %v for a task of period %v)",
+						name, ErrNotSchedulable, next, tk.d, tk.t)
+				}
+				if next == r {
+					break
+				}
+				r = next
+			}
+		}
+	}
+	return nil
+}
+
+// RTStats reports every real-time task, live or exited, by PID.
+func (s *Scheduler) RTStats() []RTStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []RTStat
+	for _, p := range s.rtProcs {
+		rt := p.rt
+		st := RTStat{
+			PID:         p.ID,
+			Name:        p.Name,
+			Period:      rt.Spec.Period,
+			WCET:        rt.Spec.WCET,
+			Deadline:    rt.Spec.deadline(),
+			Jobs:        rt.Jobs,
+			Misses:      rt.Misses,
+			MinResponse: rt.MinResp,
+			MaxResponse: rt.MaxResp,
+			Jitter:      rt.MaxResp - rt.MinResp,
+		}
+		if rt.Jobs > 0 {
+			st.AvgResponse = rt.TotalResp / time.Duration(rt.Jobs)
+		}
+		out = append(out, st)
+	}
+	return out
+}
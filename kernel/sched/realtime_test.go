@@ -0,0 +1,98 @@
+package sched
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// rtPair is the textbook set that EDF schedules and RM does not:
+// utilization 2/5 + 4/7 = 0.97.
+func rtPair(jobs int) []*ProcessSpec {
+	ms := time.Millisecond
+	return []*ProcessSpec{
+		{Name: "t1", RT: &RTSpec{Period: 5 * ms, WCET: 2 * ms, Jobs: jobs * 7}},
+		{Name: "t2", RT: &RTSpec{Period: 7 * ms, WCET: 4 * ms, Jobs: jobs * 5}},
+	}
+}
+
+// runRT runs rtPair under pol. RM's admission test refuses the pair, so
+// it is admitted under EDF and the checkpoint restored under RM.
+func runRT(t *testing.T, pol Policy) []RTStat {
+	t.Helper()
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithPolicy(PolicyEDF))
+	for _, spec := range rtPair(1) {
+		s.Spawn(spec)
+	}
+	snap := s.Checkpoint()
+	snap.Policy = pol
+	s, err := Restore(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s.Step(1000) > 0 {
+	}
+	return s.RTStats()
+}
+
+func TestEDFMeetsDeadlinesRMMisses(t *testing.T) {
+	edf := runRT(t, PolicyEDF)
+	for _, st := range edf {
+		if st.Misses != 0 || st.Jobs != map[string]int{"t1": 7, "t2": 5}[st.Name] {
+			t.Errorf("EDF %s: %d jobs, %d misses", st.Name, st.Jobs, st.Misses)
+		}
+		if st.MaxResponse > st.Deadline {
+			t.Errorf("EDF %s: max response %v past deadline %v", st.Name, st.MaxResponse, st.Deadline)
+		}
+	}
+	rm := runRT(t, PolicyRM)
+	if rm[0].Misses != 0 || rm[1].Misses == 0 {
+		t.Errorf("RM misses t1=%d t2=%d, want only the long-period task to miss", rm[0].Misses, rm[1].Misses)
+	}
+	if rm[0].Jitter != 0 || rm[1].Jitter == 0 {
+		t.Errorf("RM jitter t1=%v t2=%v", rm[0].Jitter, rm[1].Jitter)
+	}
+}
+
+func TestRTAdmission(t *testing.T) {
+	for _, tc := range []struct {
+		pol  Policy
+		want error
+	}{{PolicyRM, ErrNotSchedulable}, {PolicyEDF, nil}, {PolicyPriority, nil}} {
+		s := NewScheduler(WithUnit(time.Millisecond), WithPolicy(tc.pol))
+		pair := rtPair(1)
+		if _, err := s.TrySpawn(pair[0]); err != nil {
+			t.Fatalf("%v: first task rejected: %v", tc.pol, err)
+		}
+		if _, err := s.TrySpawn(pair[1]); !errors.Is(err, tc.want) {
+			t.Errorf("%v: second task: %v, want %v", tc.pol, err, tc.want)
+		}
+	}
+
+	s := NewScheduler(WithUnit(time.Millisecond), WithPolicy(PolicyEDF))
+	over := &ProcessSpec{Name: "over", RT: &RTSpec{Period: 4 * time.Millisecond, WCET: 3 * time.Millisecond, Deadline: 2 * time.Millisecond}}
+	if _, err := s.TrySpawn(over); !errors.Is(err, ErrNotSchedulable) {
+		t.Errorf("density 1.5 admitted under EDF: %v", err)
+	}
+	if pid := s.Spawn(over); pid != 0 || len(s.Stats()) != 0 {
+		t.Errorf("Spawn admitted the over-utilized task as PID %d", pid)
+	}
+}
+
+func TestIdleClockJumpsToNextRelease(t *testing.T) {
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithPolicy(PolicyEDF))
+	s.Spawn(&ProcessSpec{Name: "tick", RT: &RTSpec{Period: 10 * time.Millisecond, WCET: 2 * time.Millisecond, Jobs: 3}})
+	s.Spawn(&ProcessSpec{Name: "background", WorkUnits: 1})
+	for s.Step(100) > 0 {
+	}
+	if got := s.Now(); got != 22*time.Millisecond {
+		t.Errorf("clock at %v, want 22ms (third release at 20ms + 2ms of work)", got)
+	}
+	st := s.RTStats()[0]
+	if st.Jobs != 3 || st.Misses != 0 || st.MinResponse != 2*time.Millisecond || st.Jitter != 0 {
+		t.Errorf("tick stats = %+v", st)
+	}
+	if bg := s.Stats()[1]; bg.State != StateExited {
+		t.Errorf("background job is %v", bg.State)
+	}
+}
@@ -0,0 +1,314 @@
+package sched
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gosimos/ipc"
+)
+
+// EventKind names an entry in a recording. Spawn, kill, sem, cgroup, tty,
+// rand, messages and group changes from outside, and settings changed
+// mid-run are inputs from outside the kernel and are fed back on replay;
+// run and idle are scheduling decisions and are checked against the
+// replay. Shell command lines are kept for context, their effects are
+// recorded as the events they cause.
+type EventKind string
+
+const (
+	EvSpawn     EventKind = "spawn"
+	EvKill      EventKind = "kill"
+	EvSem       EventKind = "sem"
+	EvCgrp      EventKind = "cgroup"
+	EvUser      EventKind = "user"
+	EvLog       EventKind = "log"
+	EvTTY       EventKind = "tty"
+	EvRand      EventKind = "rand"
+	EvSend      EventKind = "send"
+	EvBroadcast EventKind = "broadcast"
+	EvMulticast EventKind = "multicast"
+	EvJoin      EventKind = "join"
+	EvLeave     EventKind = "leave"
+	EvSeed      EventKind = "seed"
+	EvBankers   EventKind = "bankers"
+	EvDetect    EventKind = "deadlock-detection"
+	EvCmd       EventKind = "cmd"
+	EvRun       EventKind = "run"
+	EvIdle      EventKind = "idle"
+	EvEnd       EventKind = "end"
+)
+
+// Event is one line of a recording. Dispatch and Now place it on the
+// simulated timeline; the other fields depend on Kind.
+type Event struct {
+	Kind     EventKind
+	Dispatch int
+	Now      time.Duration
+	PID      int          `json:",omitempty"`
+	N        int          `json:",omitempty"`
+	Value    int          `json:",omitempty"`
+	Arg      string       `json:",omitempty"`
+	Spec     *ProcessSpec `json:",omitempty"`
+	Cgroup   *CgroupSpec  `json:",omitempty"`
+	User     *User        `json:",omitempty"`
+	Log      *LogEntry    `json:",omitempty"`
+	Msg      *ipc.Message `json:",omitempty"`
+	Seed     uint64       `json:",omitempty"`
+}
+
+func (ev Event) String() string {
+	s := fmt.Sprintf("#%d t=%v %s", ev.Dispatch, ev.Now, ev.Kind)
+	switch ev.Kind {
+	case EvSpawn, EvKill, EvRun, EvBroadcast:
+		s += fmt.Sprintf(" pid=%d", ev.PID)
+	case EvSend:
+		s += fmt.Sprintf(" %d -> %d", ev.PID, ev.N)
+	case EvJoin, EvLeave, EvMulticast:
+		s += fmt.Sprintf(" pid=%d %s", ev.PID, ev.Arg)
+	case EvSeed:
+		s += fmt.Sprintf(" %d", ev.Seed)
+	case EvDetect:
+		s += fmt.Sprintf(" every=%d recover=%t", ev.N, ev.Value != 0)
+	case EvSem:
+		s += fmt.Sprintf(" %s=%d", ev.Arg, ev.N)
+	case EvCgrp:
+		s += " " + ev.Cgroup.Name
+	case EvUser:
+		s += fmt.Sprintf(" %s uid=%d", ev.User.Name, ev.User.UID)
+	case EvLog:
+		s += fmt.Sprintf(" %s %s: %q", ev.Log.Level, ev.Log.Subsys, ev.Log.Msg)
+	case EvRand:
+		s += fmt.Sprintf(" n=%d -> %d", ev.N, ev.Value)
+	case EvCmd, EvTTY:
+		s += fmt.Sprintf(" %q", ev.Arg)
+	case EvIdle:
+		s += fmt.Sprintf(" deadlocks=%d", ev.Value)
+	}
+	return s
+}
+
+// Recording is the state a recorded run started from followed by every
+// nondeterministic input and scheduling decision it made.
+type Recording struct {
+	Start  *Snapshot
+	Events []Event
+}
+
+// Divergence is returned by Replay when the run stops matching its
+// recording. Got is nil when the replay made no decision at all where
+// the recording has one.
+type Divergence struct {
+	Index int
+	Want  Event
+	Got   *Event
+}
+
+func (d *Divergence) Error() string {
+	if d.Got == nil {
+		return fmt.Sprintf("replay diverged at event %d: want %v, got nothing", d.Index, d.Want)
+	}
+	return fmt.Sprintf("replay diverged at event %d: want %v, got %v", d.Index, d.Want, *d.Got)
+}
+
+type journal struct {
+	// record mode
+	f   *os.File
+	w   *bufio.Writer
+	err error
+
+	// replay mode
+	replay   bool
+	events   []Event
+	pos      int
+	diverged *Divergence
+}
+
+// StartRecording writes a snapshot of s to path and then appends every
+// input and scheduling decision until StopRecording.
+func (s *Scheduler) StartRecording(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := &journal{f: f, w: bufio.NewWriter(f)}
+	if err := json.NewEncoder(j.w).Encode(s.checkpointLocked()); err != nil {
+		f.Close()
+		return err
+	}
+	s.journal = j
+	return nil
+}
+
+// StopRecording ends the recording with a final event and closes it.
+func (s *Scheduler) StopRecording() error {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.journal
+	if j == nil || j.replay {
+		return nil
+	}
+	s.recordLocked(Event{Kind: EvEnd})
+	s.journal = nil
+	if err := j.w.Flush(); err != nil && j.err == nil {
+		j.err = err
+	}
+	if err := j.f.Close(); err != nil && j.err == nil {
+		j.err = err
+	}
+	return j.err
+}
+
+// RecordCommand notes a shell command line in the recording.
+func (s *Scheduler) RecordCommand(line string) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvCmd, Arg: line})
+}
+
+// recordLocked appends ev to the recording. During replay it checks ev
+// against the next recorded event instead and returns that one, so that
+// inputs such as random numbers come from the log.
+func (s *Scheduler) recordLocked(ev Event) Event {
+	j := s.journal
+	if j == nil {
+		return ev
+	}
+	ev.Dispatch, ev.Now = s.dispatches, s.now
+	if !j.replay {
+		if j.err == nil {
+			j.err = json.NewEncoder(j.w).Encode(ev)
+		}
+		return ev
+	}
+	if j.diverged != nil {
+		return ev
+	}
+	if j.pos >= len(j.events) {
+		j.diverged = &Divergence{Index: j.pos, Want: Event{Kind: EvEnd}, Got: &ev}
+		return ev
+	}
+	want := j.events[j.pos]
+	if !sameEvent(want, ev) {
+		j.diverged = &Divergence{Index: j.pos, Want: want, Got: &ev}
+		return ev
+	}
+	j.pos++
+	return want
+}
+
+func sameEvent(want, got Event) bool {
+	if want.Kind == EvRand {
+		got.Value = want.Value
+	}
+	return want.Kind == got.Kind && want.Dispatch == got.Dispatch && want.Now == got.Now &&
+		want.PID == got.PID && want.N == got.N && want.Value == got.Value && want.Arg == got.Arg &&
+		want.Seed == got.Seed
+}
+
+// LoadRecording reads a file written by StartRecording.
+func LoadRecording(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	rec := &Recording{Start: &Snapshot{}}
+	if err := dec.Decode(rec.Start); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for {
+		var ev Event
+		if err := dec.Decode(&ev); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: event %d: %w", path, len(rec.Events), err)
+		}
+		rec.Events = append(rec.Events, ev)
+	}
+	return rec, nil
+}
+
+// Replay restores the recording's starting state and feeds its inputs back
+// at the recorded points, dispatching wherever the recording dispatched. It
+// returns the scheduler in its final state, along with a *Divergence if the
+// run made a different decision than the one recorded.
+func Replay(rec *Recording) (*Scheduler, error) {
+	s, err := Restore(rec.Start)
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{replay: true, events: rec.Events}
+	s.journal = j
+	defer func() { s.journal = nil }()
+
+	for j.diverged == nil && j.pos < len(j.events) {
+		ev, pos := j.events[j.pos], j.pos
+		switch ev.Kind {
+		case EvSpawn:
+			s.Spawn(ev.Spec)
+		case EvKill:
+			s.Kill(ev.PID)
+		case EvSem:
+			s.NewSemaphore(ev.Arg, ev.N)
+		case EvCgrp:
+			_ = s.NewCgroup(*ev.Cgroup)
+		case EvUser:
+			_ = s.AddUser(*ev.User)
+		case EvLog:
+			s.Logf(ev.Log.Level, ev.Log.Subsys, "%s", ev.Log.Msg)
+		case EvTTY:
+			s.Type(ev.Arg)
+		case EvRand:
+			s.Intn(ev.N)
+		case EvSend:
+			s.SendMessage(ev.PID, ev.N, *ev.Msg)
+		case EvBroadcast:
+			s.Broadcast(ev.PID, *ev.Msg)
+		case EvMulticast:
+			s.Multicast(ev.PID, ev.Arg, *ev.Msg)
+		case EvJoin:
+			s.JoinGroup(ev.PID, ev.Arg)
+		case EvLeave:
+			s.LeaveGroup(ev.PID, ev.Arg)
+		case EvSeed:
+			s.SetSeed(ev.Seed)
+		case EvBankers:
+			s.EnableBankers()
+		case EvDetect:
+			s.SetDeadlockDetection(ev.N, ev.Value != 0)
+		case EvCmd:
+			s.RecordCommand(ev.Arg)
+		case EvRun, EvIdle:
+			s.exec.Lock()
+			s.dispatch()
+			s.exec.Unlock()
+		case EvEnd:
+			s.mu.Lock()
+			s.recordLocked(Event{Kind: EvEnd})
+			s.mu.Unlock()
+		default:
+			return s, fmt.Errorf("%w: unknown event %q", ErrSnapshot, ev.Kind)
+		}
+		if j.diverged == nil && j.pos == pos {
+			j.diverged = &Divergence{Index: pos, Want: ev}
+		}
+	}
+	if j.diverged != nil {
+		return s, j.diverged
+	}
+	return s, nil
+}
@@ -0,0 +1,131 @@
+package sched
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"gosimos/ipc"
+)
+
+// recordLive records a run of the checkpoint workload on the real
+// scheduler loop, with a kill and a random draw arriving from outside
+// while it runs.
+func recordLive(t *testing.T) (*Scheduler, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	s := newTestScheduler()
+	s.SetSeed(7)
+	s.SetDeadlockDetection(3, true)
+	if err := s.StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	spawnWorkload(s)
+	s.Start()
+	time.Sleep(3 * time.Millisecond)
+	s.RecordCommand("kill 12")
+	s.Kill(12)
+	s.Logf(LogNotice, "shell", "killed PID 12")
+	s.Intn(1000)
+	waitExited(t, s, 5*time.Second)
+	s.Stop()
+	if err := s.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func TestReplayReproducesLiveRun(t *testing.T) {
+	live, path := recordLive(t)
+	rec, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := Replay(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outcomeOf(replayed), outcomeOf(live); !reflect.DeepEqual(got, want) {
+		t.Errorf("replay differs from the recorded run:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestReplayReportsFirstDivergence(t *testing.T) {
+	_, path := recordLive(t)
+	rec, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A scheduler change that picks a different process shows up at the
+	// first dispatch it affects.
+	first := -1
+	for i, ev := range rec.Events {
+		if ev.Kind == EvRun && ev.Dispatch > 5 {
+			first = i
+			rec.Events[i].PID = 999
+			break
+		}
+	}
+	_, err = Replay(rec)
+	var d *Divergence
+	if !errors.As(err, &d) {
+		t.Fatalf("want a divergence, got %v", err)
+	}
+	if d.Index != first || d.Got == nil || d.Got.Kind != EvRun {
+		t.Errorf("divergence at %d (%v), want event %d", d.Index, d, first)
+	}
+
+	// So does a change to what a quantum costs.
+	rec, _ = LoadRecording(path)
+	rec.Start.Unit *= 2
+	if _, err := Replay(rec); !errors.As(err, &d) {
+		t.Fatalf("want a divergence after changing the time unit, got %v", err)
+	}
+}
+
+func TestReplayFeedsBackMessagesAndSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	live := newTestScheduler()
+	if err := live.StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	rx := live.Spawn(&ProcessSpec{Name: "rx", Program: []Op{
+		Receive("job"), FileWrite("job", ""), Receive("note"), FileWrite("note", ""),
+	}})
+	live.Spawn(&ProcessSpec{Name: "busy", Program: Repeat(50, Compute(1))})
+	live.Start()
+	time.Sleep(2 * time.Millisecond)
+	live.SetSeed(3)
+	live.SetDeadlockDetection(2, false)
+	live.SendMessage(0, rx, ipc.Message{Type: "job", Payload: []byte("a")})
+	live.JoinGroup(rx, "g")
+	live.Multicast(0, "g", ipc.Message{Type: "note", Payload: []byte("b")})
+	live.LeaveGroup(rx, "g")
+	waitExited(t, live, 5*time.Second)
+	live.Stop()
+	if err := live.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(rec.Events, func(ev Event) bool { return ev.Kind == EvSend && ev.N == rx }) {
+		t.Fatalf("the message from outside was not recorded: %v", rec.Events)
+	}
+	replayed, err := Replay(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outcomeOf(replayed), outcomeOf(live); !reflect.DeepEqual(got, want) {
+		t.Errorf("replay differs from the recorded run:\n got %+v\nwant %+v", got, want)
+	}
+	if files := replayed.DumpFS(); files["job"] != "a" || files["note"] != "b" {
+		t.Errorf("replayed files %v, want both messages received", files)
+	}
+}
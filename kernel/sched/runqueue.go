@@ -0,0 +1,214 @@
+package sched
+
+import (
+	"cmp"
+	"container/heap"
+	"slices"
+)
+
+// runQueue holds the ready processes in dispatch order for one policy.
+// A process's sort key must not change while it is queued.
+type runQueue interface {
+	push(p *Process)
+	pop() *Process  // nil when empty
+	peek() *Process // ... without removing it
+	remove(p *Process) bool
+	len() int
+	each(f func(*Process)) // in dispatch order
+}
+
+// newRunQueue picks the queue for pol: per-level FIFOs for priorities, a
+// heap for the real-time policies and a red-black tree for CFS.
+func newRunQueue(pol Policy, less func(a, b *Process) bool) runQueue {
+	switch pol {
+	case PolicyEDF, PolicyRM:
+		return &rtHeap{less: less}
+	case PolicyCFS:
+		return &runTree{less: less}
+	}
+	return newLevelQueue()
+}
+
+// levelQueue is a FIFO per priority level plus a min-heap of the levels
+// that have anyone in them. Everything is O(1) but for a level becoming
+// empty or non-empty, which is O(log levels).
+type levelQueue struct {
+	levels map[int]*level
+	active intHeap
+	n      int
+}
+
+// level is an intrusive doubly-linked list through Process.rqPrev/rqNext.
+type level struct {
+	head, tail *Process
+}
+
+func newLevelQueue() *levelQueue {
+	return &levelQueue{levels: make(map[int]*level)}
+}
+
+func (q *levelQueue) push(p *Process) {
+	l := q.levels[p.Priority]
+	if l == nil {
+		l = &level{}
+		q.levels[p.Priority] = l
+	}
+	if l.head == nil {
+		heap.Push(&q.active, p.Priority)
+		l.head = p
+	} else {
+		l.tail.rqNext, p.rqPrev = p, l.tail
+	}
+	l.tail = p
+	q.n++
+}
+
+func (q *levelQueue) peek() *Process {
+	if q.n == 0 {
+		return nil
+	}
+	return q.levels[q.active[0]].head
+}
+
+func (q *levelQueue) pop() *Process {
+	p := q.peek()
+	if p != nil {
+		q.unlink(p)
+	}
+	return p
+}
+
+func (q *levelQueue) remove(p *Process) bool {
+	l := q.levels[p.Priority]
+	if l == nil || (l.head != p && p.rqPrev == nil) {
+		return false
+	}
+	q.unlink(p)
+	return true
+}
+
+func (q *levelQueue) unlink(p *Process) {
+	l := q.levels[p.Priority]
+	if p.rqPrev != nil {
+		p.rqPrev.rqNext = p.rqNext
+	} else {
+		l.head = p.rqNext
+	}
+	if p.rqNext != nil {
+		p.rqNext.rqPrev = p.rqPrev
+	} else {
+		l.tail = p.rqPrev
+	}
+	p.rqPrev, p.rqNext = nil, nil
+	if l.head == nil {
+		heap.Remove(&q.active, slices.Index(q.active, p.Priority))
+	}
+	q.n--
+}
+
+func (q *levelQueue) len() int { return q.n }
+
+func (q *levelQueue) each(f func(*Process)) {
+	for _, prio := range slices.Sorted(slices.Values(q.active)) {
+		for p := q.levels[prio].head; p != nil; p = p.rqNext {
+			f(p)
+		}
+	}
+}
+
+type intHeap []int
+
+func (h intHeap) Len() int           { return len(h) }
+func (h intHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// rtHeap is a binary min-heap on less, first come first served among
+// equals. Process.rqIndex is each process's position in procs.
+type rtHeap struct {
+	procs []*Process
+	less  func(a, b *Process) bool
+	seq   uint64
+}
+
+func (h *rtHeap) Len() int { return len(h.procs) }
+
+func (h *rtHeap) Less(i, j int) bool {
+	a, b := h.procs[i], h.procs[j]
+	if h.less(a, b) {
+		return true
+	}
+	return !h.less(b, a) && a.rqSeq < b.rqSeq
+}
+
+func (h *rtHeap) Swap(i, j int) {
+	h.procs[i], h.procs[j] = h.procs[j], h.procs[i]
+	h.procs[i].rqIndex, h.procs[j].rqIndex = i, j
+}
+
+func (h *rtHeap) Push(x any) {
+	p := x.(*Process)
+	p.rqIndex = len(h.procs)
+	h.procs = append(h.procs, p)
+}
+
+func (h *rtHeap) Pop() any {
+	n := len(h.procs) - 1
+	p := h.procs[n]
+	h.procs[n] = nil
+	h.procs = h.procs[:n]
+	return p
+}
+
+func (h *rtHeap) push(p *Process) {
+	h.seq++
+	p.rqSeq = h.seq
+	heap.Push(h, p)
+}
+
+func (h *rtHeap) pop() *Process {
+	if len(h.procs) == 0 {
+		return nil
+	}
+	return heap.Pop(h).(*Process)
+}
+
+func (h *rtHeap) peek() *Process {
+	if len(h.procs) == 0 {
+		return nil
+	}
+	return h.procs[0]
+}
+
+func (h *rtHeap) remove(p *Process) bool {
+	i := p.rqIndex
+	if i < 0 || i >= len(h.procs) || h.procs[i] != p {
+		return false
+	}
+	heap.Remove(h, i)
+	return true
+}
+
+func (h *rtHeap) len() int { return len(h.procs) }
+
+func (h *rtHeap) each(f func(*Process)) {
+	sorted := slices.Clone(h.procs)
+	slices.SortFunc(sorted, func(a, b *Process) int {
+		switch {
+		case h.less(a, b):
+			return -1
+		case h.less(b, a):
+			return 1
+		}
+		return cmp.Compare(a.rqSeq, b.rqSeq)
+	})
+	for _, p := range sorted {
+		f(p)
+	}
+}
@@ -0,0 +1,134 @@
+package sched
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+// sliceQueue is the ready queue before runQueue: a slice sorted on every
+// dispatch. It is the reference the real queues must agree with and the
+// baseline for BenchmarkDispatch.
+type sliceQueue struct {
+	procs []*Process
+	less  func(a, b *Process) bool
+}
+
+func (q *sliceQueue) push(p *Process) { q.procs = append(q.procs, p) }
+
+func (q *sliceQueue) pop() *Process {
+	p := q.peek()
+	if p != nil {
+		q.procs = append(q.procs[:0], q.procs[1:]...)
+	}
+	return p
+}
+
+func (q *sliceQueue) peek() *Process {
+	if len(q.procs) == 0 {
+		return nil
+	}
+	sort.SliceStable(q.procs, func(i, j int) bool { return q.less(q.procs[i], q.procs[j]) })
+	return q.procs[0]
+}
+
+func (q *sliceQueue) remove(p *Process) bool {
+	i := slices.Index(q.procs, p)
+	if i >= 0 {
+		q.procs = slices.Delete(q.procs, i, i+1)
+	}
+	return i >= 0
+}
+
+func (q *sliceQueue) len() int { return len(q.procs) }
+
+func (q *sliceQueue) each(f func(*Process)) {
+	q.peek()
+	for _, p := range q.procs {
+		f(p)
+	}
+}
+
+func TestRunQueuesMatchSortedSlice(t *testing.T) {
+	for _, pol := range []Policy{PolicyPriority, PolicyEDF, PolicyRM, PolicyCFS} {
+		t.Run(pol.String(), func(t *testing.T) {
+			s := NewScheduler(WithPolicy(pol))
+			got, want := newRunQueue(pol, s.lessLocked), &sliceQueue{less: s.lessLocked}
+			r := rand.New(rand.NewPCG(3, 4))
+			var queued []*Process
+			for i := 1; i <= 3000; i++ {
+				switch op := r.IntN(5); {
+				case op < 2 || len(queued) == 0:
+					p := &Process{ID: i, Priority: r.IntN(6) - 1, vruntime: time.Duration(r.IntN(20))}
+					if r.IntN(2) == 0 {
+						p.rt = &rtState{Spec: RTSpec{Period: time.Duration(1 + r.IntN(5))}, Deadline: time.Duration(r.IntN(10))}
+					}
+					got.push(p)
+					want.push(p)
+					queued = append(queued, p)
+				case op == 2:
+					p := queued[r.IntN(len(queued))]
+					if !got.remove(p) || !want.remove(p) {
+						t.Fatalf("PID %d not queued", p.ID)
+					}
+					queued = slices.DeleteFunc(queued, func(q *Process) bool { return q == p })
+				default:
+					g, w := got.pop(), want.pop()
+					if g != w {
+						t.Fatalf("pop = PID %d, want %d", g.ID, w.ID)
+					}
+					queued = slices.DeleteFunc(queued, func(q *Process) bool { return q == g })
+				}
+				if got.len() != want.len() {
+					t.Fatalf("len = %d, want %d", got.len(), want.len())
+				}
+			}
+			if g, w := pids(collect(got)), pids(collect(want)); !slices.Equal(g, w) {
+				t.Errorf("dispatch order %v, want %v", g, w)
+			}
+			if got.remove(&Process{ID: -1}) {
+				t.Error("removed a process that was never queued")
+			}
+		})
+	}
+}
+
+func collect(q runQueue) []*Process {
+	var out []*Process
+	q.each(func(p *Process) { out = append(out, p) })
+	return out
+}
+
+// BenchmarkDispatch measures one dispatch with n CPU-bound processes over
+// eight priority levels, with each policy's queue and, for the policies
+// that used it, with the sorted slice it replaced.
+func BenchmarkDispatch(b *testing.B) {
+	for _, pol := range []Policy{PolicyPriority, PolicyEDF, PolicyCFS} {
+		for _, n := range []int{1_000, 10_000, 100_000} {
+			for _, baseline := range []bool{false, true} {
+				if baseline && pol == PolicyCFS {
+					continue
+				}
+				name := fmt.Sprintf("%v/n=%d/queue", pol, n)
+				if baseline {
+					name = fmt.Sprintf("%v/n=%d/sorted-slice", pol, n)
+				}
+				b.Run(name, func(b *testing.B) {
+					s := NewScheduler(WithQuantum(time.Nanosecond), WithUnit(time.Nanosecond),
+						WithPolicy(pol), WithDeadlockDetection(0, false))
+					if baseline {
+						s.runq = &sliceQueue{less: s.lessLocked}
+					}
+					for i := 0; i < n; i++ {
+						s.Spawn(&ProcessSpec{Name: "hog", Priority: i % 8, WorkUnits: 1 << 30})
+					}
+					b.ResetTimer()
+					s.Step(b.N)
+				})
+			}
+		}
+	}
+}
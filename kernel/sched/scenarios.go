@@ -0,0 +1,290 @@
+package sched
+
+import "fmt"
+
+// Philosophers builds the classic dining-philosophers table: each
+// philosopher takes the left fork, then the right one. With a one-unit
+// quantum every philosopher can end up holding one fork.
+func Philosophers(s *Scheduler, n, meals int) {
+	for i := 0; i < n; i++ {
+		left := fmt.Sprintf("fork-%d", i)
+		right := fmt.Sprintf("fork-%d", (i+1)%n)
+		s.Spawn(&ProcessSpec{
+			Name: fmt.Sprintf("philosopher-%d", i),
+			Program: Repeat(meals,
+				MutexLock(left),
+				Compute(1),
+				MutexLock(right),
+				Compute(1),
+				MutexUnlock(right),
+				MutexUnlock(left),
+				Compute(1),
+			),
+			MaxNeed: map[string]int{left: 1, right: 1},
+		})
+	}
+}
+
+// BankersExercise is the textbook five-process, three-resource example
+// with A=10, B=5, C=7 instances. Each process acquires its maximum one
+// unit at a time, works, then releases everything.
+func BankersExercise(s *Scheduler) {
+	total := map[string]int{"A": 10, "B": 5, "C": 7}
+	for _, name := range []string{"A", "B", "C"} {
+		s.NewSemaphore(name, total[name])
+	}
+
+	max := []map[string]int{
+		{"A": 7, "B": 5, "C": 3},
+		{"A": 3, "B": 2, "C": 2},
+		{"A": 9, "B": 0, "C": 2},
+		{"A": 2, "B": 2, "C": 2},
+		{"A": 4, "B": 3, "C": 3},
+	}
+	for i, need := range max {
+		prog := []Op{}
+		for _, r := range []string{"A", "B", "C"} {
+			prog = append(prog, Repeat(need[r], SemWait(r), Compute(1))...)
+		}
+		prog = append(prog, Compute(2))
+		for _, r := range []string{"A", "B", "C"} {
+			prog = append(prog, Repeat(need[r], SemPost(r))...)
+		}
+		if _, err := s.TrySpawn(&ProcessSpec{Name: fmt.Sprintf("P%d", i), Program: prog, MaxNeed: need}); err != nil {
+			s.Logf(LogNotice, "bankers", "admission: %v", err)
+		}
+	}
+}
+
+// Pipeline wires producer | filter | consumer through two anonymous pipes,
+// the way a shell forks each stage with the right descriptors open.
+func Pipeline(s *Scheduler, items int) {
+	const item = "item;"
+	producer := &ProcessSpec{Name: "producer", Program: append(
+		[]Op{FdClose(3), FdClose(5), FdClose(6)},
+		append(Repeat(items, Compute(1), FdWrite(4, item)), FdClose(4))...,
+	)}
+	filter := &ProcessSpec{Name: "filter", Program: append(
+		[]Op{FdClose(4), FdClose(5)},
+		append(Repeat(items, FdRead(3, len(item)), FdWrite(6, "")), FdClose(6))...,
+	)}
+	consumer := &ProcessSpec{Name: "consumer", Program: append(
+		[]Op{FdClose(3), FdClose(4), FdClose(6)},
+		Repeat(items, FdRead(5, len(item)), FileWrite("pipeline.out", ""))...,
+	)}
+	s.Spawn(&ProcessSpec{Name: "sh", Program: []Op{
+		PipeCreate(3, 4, 0),
+		PipeCreate(5, 6, 0),
+		SpawnChild(producer),
+		SpawnChild(filter),
+		SpawnChild(consumer),
+	}})
+}
+
+// SharedMemory moves the same items as pipeline through a one-slot shared
+// segment guarded by empty/full semaphores.
+func SharedMemory(s *Scheduler, items int) {
+	const item = "item;"
+	s.NewSemaphore("empty", 1)
+	s.NewSemaphore("full", 0)
+	s.Spawn(&ProcessSpec{Name: "shm-producer", Program: append(
+		[]Op{ShmAttach("buf", len(item))},
+		Repeat(items, Compute(1), SemWait("empty"), ShmWrite("buf", 0, item), SemPost("full"))...,
+	)})
+	s.Spawn(&ProcessSpec{Name: "shm-consumer", Program: append(
+		[]Op{ShmAttach("buf", len(item))},
+		Repeat(items, SemWait("full"), ShmRead("buf", 0, len(item)), SemPost("empty"), FileWrite("shm.out", ""))...,
+	)})
+}
+
+// RPC runs a request/reply server with several clients. Clients join
+// the "clients" group, and the server multicasts a shutdown notice to it
+// once every call has been answered.
+func RPC(s *Scheduler, clients, calls int) {
+	server := s.Spawn(&ProcessSpec{Name: "rpc-server", Program: append(
+		Repeat(clients*calls, Receive("req"), Compute(1), Reply("resp", "pong")),
+		MulticastMsg("clients", "shutdown", "bye"),
+	)})
+	for i := 0; i < clients; i++ {
+		s.Spawn(&ProcessSpec{Name: fmt.Sprintf("rpc-client-%d", i), Program: append(
+			append([]Op{Join("clients")}, Repeat(calls, Compute(1), Call(server, "req", "ping"))...),
+			Receive("shutdown"),
+		)})
+	}
+}
+
+// RealTime runs three periodic tasks with utilization 0.8, a sporadic
+// alarm with a deadline much shorter than its period, and a background
+// batch job. EDF meets every deadline; RM would rank the alarm by its
+// long period, so its admission test leaves the alarm out.
+func RealTime(s *Scheduler) {
+	u := s.unit
+	s.Spawn(&ProcessSpec{Name: "sensor", RT: &RTSpec{Period: 4 * u, WCET: u, Jobs: 30}})
+	s.Spawn(&ProcessSpec{Name: "control", RT: &RTSpec{Period: 5 * u, WCET: 2 * u, Jobs: 24}})
+	s.Spawn(&ProcessSpec{Name: "logger", RT: &RTSpec{Period: 20 * u, WCET: 3 * u, Jobs: 6}})
+	s.Spawn(&ProcessSpec{Name: "alarm", RT: &RTSpec{Period: 40 * u, WCET: u, Deadline: 10 * u, Sporadic: true, Jobs: 2}})
+	s.Spawn(&ProcessSpec{Name: "batch", Priority: 1, WorkUnits: 20})
+}
+
+// Cgroups runs two workers in a group capped at a fifth of the CPU next
+// to an uncapped one, and a leaky pair whose group is OOM-killed once
+// their allocations pass 4 KiB.
+func Cgroups(s *Scheduler) {
+	u := s.quantum
+	for _, spec := range []CgroupSpec{
+		{Name: "batch", Quota: 2 * u, Period: 10 * u, PidsMax: 2},
+		{Name: "web", MemLimit: 4096},
+	} {
+		if err := s.NewCgroup(spec); err != nil {
+			s.Logf(LogWarn, "cgroup", "%v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		s.Spawn(&ProcessSpec{Name: fmt.Sprintf("batch-%d", i), Cgroup: "batch", WorkUnits: 10}) // the third is refused
+	}
+	s.Spawn(&ProcessSpec{Name: "interactive", WorkUnits: 10})
+	for i := 0; i < 2; i++ {
+		s.Spawn(&ProcessSpec{Name: fmt.Sprintf("web-%d", i), Cgroup: "web", Memory: 1024,
+			Program: Repeat(8, Compute(1), Alloc(256))})
+	}
+}
+
+// IOBound runs processes that spend most of their time waiting on the
+// simulated devices: a sleeper, a database that writes and reads back its
+// file, and a TTY echo that forwards typed lines over the network to a
+// logger, while a CPU hog soaks up what is left.
+func IOBound(s *Scheduler) {
+	s.Spawn(&ProcessSpec{Name: "sleeper", Program: Repeat(3, Compute(1), Sleep(4))})
+	s.Spawn(&ProcessSpec{Name: "db", Program: []Op{
+		DiskWrite("db.dat", "id,name;1,ada;2,grace"),
+		DiskRead("db.dat"),
+		Compute(1),
+		DiskWrite("db.bak", ""),
+	}})
+	logger := s.Spawn(&ProcessSpec{Name: "logger", Program: Repeat(2, Receive("line"), DiskWrite("tty.log", ""))})
+	s.Spawn(&ProcessSpec{Name: "echo", Program: Repeat(2, TTYRead(), NetSend(logger, "line", ""))})
+	s.Spawn(&ProcessSpec{Name: "hog", Priority: 2, WorkUnits: 20})
+	s.Type("ls\nexit\n")
+}
+
+// Users runs alice and bob, who share group 100. Alice keeps private
+// notes and a group-writable plan; bob may update the plan but not read
+// the notes or kill alice's job. Root may do both.
+func Users(s *Scheduler) {
+	for _, u := range []User{
+		{Name: "alice", UID: 1000, GID: 100},
+		{Name: "bob", UID: 1001, GID: 100},
+	} {
+		if err := s.AddUser(u); err != nil {
+			s.Logf(LogWarn, "user", "%v", err)
+		}
+	}
+	s.Spawn(&ProcessSpec{Name: "alice", User: "alice", Program: []Op{
+		FileWrite("notes", "call grace"), Chmod("notes", 0o600),
+		FileWrite("plan", "ship v1"), Chmod("plan", 0o660),
+	}})
+	job := s.Spawn(&ProcessSpec{Name: "alice-job", User: "alice", WorkUnits: 60})
+	s.Spawn(&ProcessSpec{Name: "bob", User: "bob", Program: []Op{
+		Compute(1), DiskRead("notes"), DiskWrite("plan", "ship v2"), KillProc(job),
+	}})
+	s.Spawn(&ProcessSpec{Name: "admin", Program: []Op{Compute(3), DiskRead("notes"), KillProc(job)}})
+}
+
+// Top runs a monitor that samples /proc/loadavg while three workers
+// compete for the CPU, then saves its own status and the scheduler's
+// debug view, like a small top(1).
+func Top(s *Scheduler) {
+	for i := 0; i < 3; i++ {
+		s.Spawn(&ProcessSpec{Name: fmt.Sprintf("worker-%d", i), Priority: i, WorkUnits: 15})
+	}
+	prog := Repeat(3, Sleep(10), DiskRead(ProcDir+"/loadavg"), FileWrite("loadavg.log", ""))
+	prog = append(prog,
+		DiskRead(ProcDir+"/self/status"), FileWrite("top.status", ""),
+		DiskRead(ProcDir+"/sched_debug"), FileWrite("sched_debug.txt", ""))
+	s.Spawn(&ProcessSpec{Name: "top", Program: prog})
+}
+
+// Swapping has a launcher start a 256-byte worker every few units; each
+// computes a little and sleeps in between. Once more of them are alive
+// than physical memory holds (see WithSwap) they push each other out to
+// the disk, until swapping takes most of the disk's time and the system
+// thrashes.
+func Swapping(s *Scheduler) {
+	if s.SwapStats().Memory == 0 {
+		s.Logf(LogNotice, "mm", "swapping is off: physical memory has no limit")
+	}
+	var prog []Op
+	for i := 0; i < 8; i++ {
+		prog = append(prog, SpawnChild(&ProcessSpec{Name: fmt.Sprintf("worker-%d", i), Memory: 256,
+			Program: Repeat(8, Compute(1), Sleep(4))}), Sleep(3))
+	}
+	s.Spawn(&ProcessSpec{Name: "launcher", Program: prog})
+}
+
+// Threading runs the same program under both thread models: a main
+// thread starts three workers that compute, sleep and yield, then joins
+// them. The kernel threads sleep side by side; the user-level ones stop
+// their whole process each time one of them sleeps.
+func Threading(s *Scheduler) {
+	for _, model := range []ThreadModel{KernelThreads, UserThreads} {
+		name := "kthreads"
+		if model == UserThreads {
+			name = "uthreads"
+		}
+		var prog []Op
+		for i := 0; i < 3; i++ {
+			prog = append(prog, ThreadCreate(fmt.Sprintf("t%d", i),
+				Compute(2), Sleep(6), Compute(1), Yield(), Compute(2)))
+		}
+		prog = append(prog, Compute(1), ThreadJoin("t0"), ThreadJoin("t1"), ThreadJoin("t2"))
+		s.Spawn(&ProcessSpec{Name: name, Threads: model, Program: prog})
+	}
+}
+
+// Scenarios lists the names SpawnScenario accepts.
+var Scenarios = []string{"philosophers", "bankers", "pipeline", "shm", "rpc", "rt", "cgroups", "io", "users", "proc", "swap", "threads"}
+
+// SpawnScenario spawns a canned workload by name and reports whether the
+// name was known.
+func SpawnScenario(s *Scheduler, name string) bool {
+	switch name {
+	case "philosophers":
+		Philosophers(s, 5, 3)
+	case "bankers":
+		BankersExercise(s)
+	case "pipeline":
+		Pipeline(s, 20)
+	case "shm":
+		SharedMemory(s, 20)
+	case "rpc":
+		RPC(s, 3, 4)
+	case "rt":
+		RealTime(s)
+	case "cgroups":
+		Cgroups(s)
+	case "io":
+		IOBound(s)
+	case "users":
+		Users(s)
+	case "proc":
+		Top(s)
+	case "swap":
+		Swapping(s)
+	case "threads":
+		Threading(s)
+	default:
+		return false
+	}
+	return true
+}
+
+// JournalProgram appends to a shared journal under an exclusive flock,
+// one work unit per critical section.
+func JournalProgram(name string, units int) []Op {
+	return Repeat(units,
+		FileLock("journal.log", LockExclusive),
+		Compute(1),
+		FileWrite("journal.log", fmt.Sprintf("last entry by %s", name)),
+		FileUnlock("journal.log"),
+	)
+}
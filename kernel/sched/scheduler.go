@@ -0,0 +1,483 @@
+// Package sched is the GoSimOS kernel: a priority round-robin scheduler on
+// a simulated clock, with processes that run programs of kernel calls
+// (locks, semaphores, pipes, shared memory, messages, device I/O),
+// deadlock handling, checkpoints and record/replay.
+package sched
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"gosimos/fs"
+	"gosimos/ipc"
+)
+
+type ProcessStat struct {
+	ID         int
+	Name       string
+	Priority   int
+	RunCount   int
+	TotalCPU   time.Duration // wall clock spent running the program
+	CPUTime    time.Duration // simulated time on the CPU, SysTime included
+	Remaining  int
+	State      ProcState
+	WaitingOn  string
+	ExitReason string
+	Parent     int
+	VRuntime   time.Duration // CFS virtual runtime
+	Cgroup     string
+	Memory     int // bytes, resident unless Swapped
+	User       string
+	UID        int
+	Syscalls   map[string]int
+	SysTime    time.Duration // charged as system call cost
+	Swapped    bool          // Memory is in the swap area
+	SwapIns    int
+	SwapOuts   int
+	SwapTime   time.Duration // spent swapped out, up to the last swap-in
+	TGID       int           // the process a thread belongs to, its own PID if not a thread
+	Threads    int           // live threads of that process
+}
+
+type Scheduler struct {
+	quantum   time.Duration
+	unit      time.Duration
+	mu        sync.Mutex
+	runq      runQueue // ready processes
+	procs     map[int]*Process
+	mailboxes map[int][]ipc.Message
+	mailStats map[int]map[string]*ipc.TypeStat
+	flows     map[[2]int]int
+	groups    map[string]map[int]bool
+	msgSeq    uint64
+	now       time.Duration
+	fs        *fs.SimFS
+	mutexes   map[string]*kmutex
+	sems      map[string]*ksem
+	conds     map[string]*kcond
+	flocks    map[string]*kflock
+	pipes     []*ipc.Pipe
+	pipeSeq   int
+	segments  map[string]*ipc.Segment
+	pending   []pendingReq
+	managed   map[string]bool
+	bankers   bool
+
+	policy          Policy
+	users           []User    // in creation order, root first
+	cgroups         []*cgroup // in creation order
+	minVruntime     time.Duration
+	cfsLatency      time.Duration
+	cfsGranularity  time.Duration
+	rtProcs         []*Process // real-time tasks in spawn order
+	devices         DeviceConfig
+	irqs            []irq // pending interrupts by time
+	irqSeq          uint64
+	irqStats        [numDevices]irqCount
+	diskBusy        time.Duration // until the last queued request completes
+	swap            SwapConfig    // swapping is off while its Memory is 0
+	swapQ           []*Process    // ready but swapped out, waiting for swap-in
+	swapStats       swapCount     // for SwapStats
+	ttyBuf          string        // typed but not yet read
+	ttyWaiters      []*Process
+	sysCosts        map[Sysno]time.Duration
+	sysStats        [numSyscalls]sysCount
+	dispatches      int
+	switches        int
+	lastPID         int
+	loadAvg         [3]float64
+	loadAt          time.Duration // when loadAvg was last brought up to date
+	readyWait       *Histogram
+	blockWait       *Histogram
+	deadlockEvery   int
+	deadlockRecover bool
+	deadlocks       []DeadlockReport
+	reportedCycles  map[string]bool
+	klog            []LogEntry // the last klogSize entries
+	klogSize        int
+	klogSeq         uint64
+	logger          *slog.Logger
+
+	nextPID int
+	rng     *rand.PCG // simulation randomness; part of a checkpoint
+	rand    *rand.Rand
+	journal *journal // record or replay log, nil when neither
+
+	observers []Observer
+
+	exec        sync.Mutex // held while a process runs
+	paused      bool
+	kick        chan struct{}
+	breakpoints []*Breakpoint
+	breakSeq    int
+	hits        chan BreakHit
+
+	ctl     sync.Mutex // serializes Start and Stop
+	running bool
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewScheduler returns a stopped scheduler with an empty process table.
+// Without options the quantum and the work unit are both 100ms.
+func NewScheduler(opts ...Option) *Scheduler {
+	rng := rand.NewPCG(0, 0)
+	s := &Scheduler{
+		quantum:   100 * time.Millisecond,
+		unit:      100 * time.Millisecond,
+		procs:     make(map[int]*Process),
+		mailboxes: make(map[int][]ipc.Message),
+		mailStats: make(map[int]map[string]*ipc.TypeStat),
+		flows:     make(map[[2]int]int),
+		groups:    make(map[string]map[int]bool),
+		fs:        fs.NewSimFS(),
+		mutexes:   make(map[string]*kmutex),
+		sems:      make(map[string]*ksem),
+		conds:     make(map[string]*kcond),
+		flocks:    make(map[string]*kflock),
+		segments:  make(map[string]*ipc.Segment),
+		managed:   make(map[string]bool),
+		users:     []User{rootUser},
+
+		readyWait: newHistogram(WaitBuckets),
+		blockWait: newHistogram(WaitBuckets),
+
+		deadlockEvery:  10,
+		reportedCycles: make(map[string]bool),
+		klogSize:       defaultLogSize,
+
+		rng:  rng,
+		rand: rand.New(rng),
+
+		kick: make(chan struct{}, 1),
+		hits: make(chan BreakHit, 64),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.runq = newRunQueue(s.policy, s.lessLocked)
+	s.devices = s.devices.withDefaults(s.unit)
+	s.swap = s.swap.withDefaults(s.devices)
+	if !s.fs.Mount(ProcDir, procFS{s}) {
+		s.klogLocked(LogWarn, "sched", 0, "%s is already mounted by another scheduler", ProcDir)
+	}
+	s.klogLocked(LogInfo, "sched", 0, "policy %s, quantum %v, unit %v", s.policy, s.quantum, s.unit)
+	return s
+}
+
+// Spawn adds a process to the ready queue. Like every change from outside
+// the kernel it lands between two dispatches. It returns 0 if the process's
+// user or cgroup does not exist, the cgroup is full or a real-time task
+// fails the policy's schedulability test; TrySpawn says why.
+func (s *Scheduler) Spawn(spec *ProcessSpec) int {
+	pid, _ := s.spawn(spec)
+	return pid
+}
+
+func (s *Scheduler) spawn(spec *ProcessSpec) (int, error) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := NewProcess(s.nextPID+1, spec)
+	if spec.RT != nil {
+		if err := s.admitRTLocked(spec.Name, *spec.RT); err != nil {
+			s.recordLocked(Event{Kind: EvSpawn, Spec: spec})
+			s.klogLocked(LogNotice, "proc", 0, "spawn refused: %v", err)
+			return 0, err
+		}
+	}
+	if err := s.setUserLocked(p, spec, rootUser.Name); err != nil {
+		s.recordLocked(Event{Kind: EvSpawn, Spec: spec})
+		s.klogLocked(LogNotice, "proc", 0, "spawn refused: %v", err)
+		return 0, err
+	}
+	g, err := s.joinCgroupLocked(spec, nil)
+	if err != nil {
+		s.recordLocked(Event{Kind: EvSpawn, Spec: spec}) // a refusal still counts against the group
+		s.klogLocked(LogNotice, "proc", 0, "spawn refused: %v", err)
+		return 0, err
+	}
+	s.nextPID++
+	p.cgroup = g
+	p.spawnedAt = s.now
+	if spec.RT != nil {
+		s.newRTLocked(p, *spec.RT)
+	}
+	s.placeLocked(p, false)
+	s.enqueueLocked(p)
+	s.procs[p.ID] = p
+	s.mailboxes[p.ID] = []ipc.Message{}
+	s.procEventLocked(p, Observer.OnSpawn)
+	for name := range spec.MaxNeed {
+		s.managed[name] = s.bankers
+	}
+	s.recordLocked(Event{Kind: EvSpawn, PID: p.ID, Spec: spec})
+	s.klogLocked(LogDebug, "proc", p.ID, "spawned %q", p.Name)
+	s.memChargeLocked(p, spec.Memory)
+	return p.ID, nil
+}
+
+// SetSeed reseeds the simulation's random number generator.
+func (s *Scheduler) SetSeed(seed uint64) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvSeed, Seed: seed})
+	s.rng.Seed(seed, 0)
+}
+
+// Intn returns a random number in [0, n) from the simulation's generator,
+// so that workloads built from it survive a checkpoint.
+func (s *Scheduler) Intn(n int) int {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev := s.recordLocked(Event{Kind: EvRand, N: n, Value: s.rand.IntN(n)})
+	return ev.Value
+}
+
+// Start launches the scheduler loop. A stopped scheduler can be started
+// again; it resumes with whatever is left in the ready queue.
+func (s *Scheduler) Start() {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+	if s.running {
+		return
+	}
+
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	go s.loop(s.stopCh, s.doneCh)
+}
+
+// Stop halts the loop after the current quantum and waits for it to exit.
+func (s *Scheduler) Stop() {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+	if !s.running {
+		return
+	}
+
+	close(s.stopCh)
+	<-s.doneCh
+	s.running = false
+}
+
+func (s *Scheduler) loop(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		if s.Paused() {
+			select {
+			case <-stopCh:
+				return
+			case <-s.kick:
+			}
+			continue
+		}
+
+		s.exec.Lock()
+		ran, _ := s.dispatch()
+		s.exec.Unlock()
+		if !ran {
+			select {
+			case <-stopCh:
+				return
+			case <-s.kick:
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+}
+
+// enqueueLocked adds p to the ready queue and starts its wait clock.
+func (s *Scheduler) enqueueLocked(p *Process) {
+	p.readyAt = s.now
+	if p.swap.Out {
+		s.swapInLocked(p)
+		return
+	}
+	if g := p.cgroup; g != nil && g.throttled {
+		g.park(p, s.now)
+		return
+	}
+	s.runq.push(p)
+}
+
+func (s *Scheduler) readyLenLocked() int {
+	return s.runq.len()
+}
+
+func (s *Scheduler) popReadyLocked() *Process {
+	return s.runq.pop()
+}
+
+func (s *Scheduler) removeReadyLocked(p *Process) {
+	if !s.runq.remove(p) {
+		s.unparkLocked(p)
+	}
+}
+
+// readyListLocked is the ready queue in dispatch order.
+func (s *Scheduler) readyListLocked() []*Process {
+	out := make([]*Process, 0, s.runq.len())
+	s.runq.each(func(p *Process) { out = append(out, p) })
+	return out
+}
+
+// timersLocked fires what is due on the simulated clock: device
+// interrupts, new quota periods for throttled cgroups and real-time job
+// releases. While nothing is ready it moves the clock on to the earliest
+// of them, the only way simulated time passes while the CPU idles.
+func (s *Scheduler) timersLocked() {
+	for {
+		s.interruptsLocked()
+		s.cgroupTickLocked()
+		s.rtTickLocked()
+		if s.readyLenLocked() > 0 {
+			return
+		}
+		next, ok := time.Duration(0), false
+		for _, f := range []func() (time.Duration, bool){s.nextIRQLocked, s.nextReleaseLocked, s.nextUnthrottleLocked} {
+			if t, due := f(); due && (!ok || t < next) {
+				next, ok = t, true
+			}
+		}
+		if !ok || next <= s.now {
+			return
+		}
+		s.loadTickLocked()
+		s.now = next
+	}
+}
+
+// dispatch runs the highest-priority ready process for one quantum. It
+// reports whether anything ran and whether a breakpoint fired. Callers
+// hold s.exec so that only one goroutine executes processes at a time.
+func (s *Scheduler) dispatch() (ran, hit bool) {
+	s.mu.Lock()
+	s.loadTickLocked()
+	s.timersLocked()
+	if s.readyLenLocked() == 0 {
+		if s.deadlockEvery > 0 {
+			found := len(s.deadlocks)
+			s.checkDeadlockLocked()
+			if len(s.deadlocks) > found {
+				s.recordLocked(Event{Kind: EvIdle, Value: len(s.deadlocks)})
+			}
+		}
+		s.mu.Unlock()
+		return false, false
+	}
+
+	p := s.popReadyLocked()
+	slice := s.cgroupSliceLocked(p, s.sliceLocked(p))
+	p.state = StateRunning
+	p.swap.Fresh = false
+	s.dispatches++
+	s.readyWait.observe(s.now - p.readyAt)
+	if p.ID != s.lastPID {
+		s.switches++
+		s.lastPID = p.ID
+	}
+	s.recordLocked(Event{Kind: EvRun, PID: p.ID})
+	s.procEventLocked(p, Observer.OnDispatch)
+	before := s.now
+	s.mu.Unlock()
+
+	start := time.Now()
+	state := p.Run(slice, s)
+	cpu := time.Since(start)
+
+	s.mu.Lock()
+	p.TotalCPU += cpu
+	p.RunCount++
+	p.cpuTime += s.now - before
+	s.chargeLocked(p, s.now-before)
+	s.cgroupChargeLocked(p, s.now-before)
+	if p.exitReason != "" {
+		if state == StateBlocked {
+			s.dequeueLocked(p)
+		}
+		state = StateExited
+	}
+	switch state {
+	case StateReady:
+		p.state = StateReady
+		s.enqueueLocked(p)
+		s.procEventLocked(p, Observer.OnPreempt)
+	case StateExited:
+		// keep in procs for stats but not in ready queue
+		s.exitLocked(p)
+	case StateBlocked:
+		// parked on a wait queue; whoever releases the object wakes it
+	}
+	if s.deadlockEvery > 0 && s.dispatches%s.deadlockEvery == 0 {
+		s.checkDeadlockLocked()
+	}
+	hit = s.checkBreakpointsLocked(p)
+	s.mu.Unlock()
+	return true, hit
+}
+
+func (s *Scheduler) Stats() []ProcessStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ProcessStat, 0, len(s.procs))
+
+	for _, p := range s.procs {
+		out = append(out, p.stat())
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out
+}
+
+// ReadyQueue lists the ready PIDs in the order they would be dispatched.
+func (s *Scheduler) ReadyQueue() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pids(s.readyListLocked())
+}
+
+func (s *Scheduler) DumpMailboxes() map[int][]ipc.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dup := make(map[int][]ipc.Message, len(s.mailboxes))
+
+	for k, v := range s.mailboxes {
+		dup[k] = append([]ipc.Message(nil), v...)
+	}
+
+	return dup
+}
+
+func (s *Scheduler) DumpFS() map[string]string {
+	return s.fs.Dump()
+}
+
+// FSOps counts virtual file system calls by kind.
+func (s *Scheduler) FSOps() map[string]int {
+	return s.fs.Ops()
+}
+
+// FileInfo reports the owner and mode of every file and fifo.
+func (s *Scheduler) FileInfo() map[string]fs.Info {
+	return s.fs.Infos()
+}
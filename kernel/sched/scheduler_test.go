@@ -0,0 +1,151 @@
+package sched
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gosimos/ipc"
+)
+
+func TestPriorityHighFirst(t *testing.T) {
+	s := NewScheduler(WithQuantum(100 * time.Millisecond))
+	defer s.Stop()
+
+	p1 := &ProcessSpec{
+		Name:      "high",
+		Priority:  0,
+		WorkUnits: 3,
+		Behavior:  BehaviorCompute,
+	}
+	p2 := &ProcessSpec{
+		Name:      "low",
+		Priority:  2,
+		WorkUnits: 6,
+		Behavior:  BehaviorCompute,
+	}
+
+	s.Spawn(p2)
+	s.Spawn(p1)
+
+	s.Start()
+	time.Sleep(1200 * time.Millisecond)
+	s.Stop()
+
+	stats := s.Stats()
+	gotHighRemaining := -1
+	gotLowRemaining := -1
+
+	for _, st := range stats {
+		if st.Name == "high" {
+			gotHighRemaining = st.Remaining
+		} else if st.Name == "low" {
+			gotLowRemaining = st.Remaining
+		}
+	}
+
+	if gotHighRemaining > gotLowRemaining {
+		t.Errorf("High priority process has more remaining work units than low priority process: high=%d low=%d", gotHighRemaining, gotLowRemaining)
+	}
+}
+
+func spawnMixed(s *Scheduler, n int) {
+	for i := 0; i < n; i++ {
+		spec := &ProcessSpec{
+			Name:      fmt.Sprintf("stress-%03d", i),
+			Priority:  i % 3,
+			WorkUnits: 1 + i%7,
+		}
+		switch i % 5 {
+		case 1:
+			spec.Behavior = BehaviorIPCSender
+		case 2:
+			spec.Behavior = BehaviorFSWriter
+		case 3:
+			spec.Program = JournalProgram(spec.Name, 1+i%4)
+		case 4:
+			spec.Program = []Op{MutexLock("m"), Compute(2), Send(1, "tick", "x"), MutexUnlock("m")}
+		}
+		s.Spawn(spec)
+	}
+}
+
+func TestStressConcurrentObservers(t *testing.T) {
+	s := NewScheduler(WithQuantum(3 * time.Microsecond))
+	s.unit = time.Microsecond
+	defer s.Stop()
+
+	spawnMixed(s, 500)
+	s.Start()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_ = s.Stats()
+				_ = s.SyncStats()
+				_ = s.MailboxStats()
+				_ = s.DumpMailboxes()
+				_ = s.DumpFS()
+				_ = s.PipeStats()
+				_ = s.Now()
+				s.SendMessage(0, 1+i%500, ipc.Message{Type: "poke"})
+				if g == 0 && i%50 == 0 {
+					s.Kill(1 + (i/50)%500)
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+		}(g)
+	}
+
+	stats := waitExited(t, s, 20*time.Second)
+	close(stop)
+	wg.Wait()
+
+	if len(stats) != 500 {
+		t.Fatalf("got %d processes, want 500", len(stats))
+	}
+	for _, st := range stats {
+		if st.ExitReason == "" && st.Remaining != 0 {
+			t.Errorf("PID %d exited with %d units left", st.ID, st.Remaining)
+		}
+	}
+}
+
+func TestStartStopRestart(t *testing.T) {
+	s := NewScheduler(WithQuantum(2 * time.Microsecond))
+	s.unit = time.Microsecond
+
+	s.Stop() // never started: no-op
+	spawnMixed(s, 200)
+
+	for i := 0; i < 20; i++ {
+		s.Start()
+		s.Start()
+		time.Sleep(2 * time.Millisecond)
+		s.Stop()
+		s.Stop()
+	}
+	s.Start()
+	defer s.Stop()
+
+	total := 0
+	for _, st := range waitExited(t, s, 20*time.Second) {
+		if st.Remaining != 0 {
+			t.Errorf("PID %d finished with %d units left", st.ID, st.Remaining)
+		}
+		total += st.RunCount
+	}
+	if total < 200 {
+		t.Errorf("only %d dispatches recorded for 200 processes", total)
+	}
+}
@@ -0,0 +1,268 @@
+package sched
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// SwapConfig turns on the medium-term scheduler. Once the resident memory
+// of all processes passes Memory, whole processes are swapped out to a
+// swap area on the simulated disk, blocked ones first, and swapped back
+// in when they are ready to run. Zero fields other than Memory get
+// defaults: a swap area without limit, the disk's DiskByte per byte and
+// thrashing once swap I/O wants half of the disk's time.
+type SwapConfig struct {
+	Memory int           // physical memory in bytes; 0 turns swapping off
+	Size   int           // swap area in bytes
+	Cost   time.Duration // disk time per byte moved, after a seek
+	Thrash float64       // share of the disk's time spent swapping that is thrashing
+}
+
+func (cfg SwapConfig) withDefaults(dev DeviceConfig) SwapConfig {
+	if cfg.Cost == 0 {
+		cfg.Cost = dev.DiskByte
+	}
+	if cfg.Thrash == 0 {
+		cfg.Thrash = 0.5
+	}
+	return cfg
+}
+
+// SwapStat reports memory and swap use. Load is the disk time swapping
+// asked for, damped over the 5 second load average window, as a share of
+// the time; the system thrashes while it is over Thrash. ThrashAt,
+// ThrashLoad and ThrashProcs say when thrashing first set in, at what
+// load average and with how many live processes.
+type SwapStat struct {
+	SwapConfig
+	Resident    int
+	Used        int // bytes in the swap area
+	Ins         int
+	Outs        int
+	Time        time.Duration // disk time spent swapping
+	Load        float64
+	Thrashing   bool
+	Episodes    int
+	ThrashAt    time.Duration
+	ThrashLoad  float64
+	ThrashProcs int
+}
+
+// swapCount is the scheduler's side of SwapStat; exported fields because
+// it is checkpointed as is.
+type swapCount struct {
+	Used        int
+	Ins         int
+	Outs        int
+	Time        time.Duration
+	Load        float64
+	LoadAt      time.Duration
+	Thrashing   bool
+	Episodes    int
+	ThrashAt    time.Duration
+	ThrashLoad  float64
+	ThrashProcs int
+}
+
+// procSwap is a process's swapping state, checkpointed as is. Fresh means
+// swapped in but not dispatched since, which keeps it from going straight
+// back out.
+type procSwap struct {
+	Out   bool
+	Fresh bool
+	At    time.Duration // when it was last swapped out
+	Ins   int
+	Outs  int
+	Time  time.Duration // spent swapped out, up to the last swap-in
+}
+
+// residentLocked is the memory of every process that is not swapped out.
+func (s *Scheduler) residentLocked() int {
+	n := 0
+	for _, p := range s.procs {
+		if !p.swap.Out {
+			n += p.mem
+		}
+	}
+	return n
+}
+
+// memPressureLocked swaps processes out until the resident ones fit in
+// physical memory again or nothing more can go; keep stays in.
+func (s *Scheduler) memPressureLocked(keep *Process) {
+	if s.swap.Memory == 0 {
+		return
+	}
+	for res := s.residentLocked(); res > s.swap.Memory; {
+		q := s.swapVictimLocked(keep)
+		if q == nil {
+			s.klogLocked(LogDebug, "mm", 0, "%d of %d bytes resident, nothing left to swap out", res, s.swap.Memory)
+			return
+		}
+		res -= q.mem
+		s.swapOutLocked(q)
+	}
+}
+
+// swapVictimLocked picks what to swap out: a blocked process before a
+// ready one, then the lowest priority, then the one that has waited
+// longest. The running process, throttled ones and those swapped in but
+// not yet run stay, as does anything the swap area has no room for.
+func (s *Scheduler) swapVictimLocked(keep *Process) *Process {
+	free := math.MaxInt
+	if s.swap.Size > 0 {
+		free = s.swap.Size - s.swapStats.Used
+	}
+	var best *Process
+	for _, pid := range slices.Sorted(maps.Keys(s.procs)) {
+		p := s.procs[pid]
+		if p == keep || p.swap.Out || p.swap.Fresh || p.mem == 0 || p.mem > free || p.exitReason != "" {
+			continue
+		}
+		if slices.ContainsFunc(p.threads, func(t *Process) bool { return t.state != StateExited }) {
+			continue // its memory is in use by threads that may be running
+		}
+		if p.state != StateBlocked && (p.state != StateReady || p.cgroup != nil && p.cgroup.throttled) {
+			continue
+		}
+		if best == nil || swapsBefore(p, best) {
+			best = p
+		}
+	}
+	return best
+}
+
+func swapsBefore(a, b *Process) bool {
+	if (a.state == StateBlocked) != (b.state == StateBlocked) {
+		return a.state == StateBlocked
+	}
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return idleSince(a) < idleSince(b)
+}
+
+func idleSince(p *Process) time.Duration {
+	if p.state == StateBlocked {
+		return p.blockedAt
+	}
+	return p.readyAt
+}
+
+// swapOutLocked frees p's memory at once and queues the write on the
+// disk. A ready p is wanted back straight away.
+func (s *Scheduler) swapOutLocked(p *Process) {
+	p.swap.Out, p.swap.At = true, s.now
+	p.swap.Outs++
+	s.swapStats.Outs++
+	s.swapStats.Used += p.mem
+	s.klogLocked(LogDebug, "mm", p.ID, "swapped out %q (%d bytes)", p.Name, p.mem)
+	s.swapIOLocked(p, true)
+	if p.state == StateReady {
+		s.runq.remove(p)
+		s.swapInLocked(p)
+	}
+}
+
+// swapInLocked queues the read that brings p back; p waits in swapQ,
+// ready but out of the run queue, until it completes.
+func (s *Scheduler) swapInLocked(p *Process) {
+	s.swapQ = append(s.swapQ, p)
+	s.swapIOLocked(p, false)
+}
+
+// swapInDoneLocked makes p resident and ready, swapping others out to
+// make room for it. p is not in swapQ if it was killed meanwhile.
+func (s *Scheduler) swapInDoneLocked(p *Process) {
+	i := slices.Index(s.swapQ, p)
+	if i < 0 {
+		return
+	}
+	s.swapQ = slices.Delete(s.swapQ, i, i+1)
+	p.swap.Out, p.swap.Fresh = false, true
+	p.swap.Ins++
+	p.swap.Time += s.now - p.swap.At
+	s.swapStats.Ins++
+	s.swapStats.Used -= p.mem
+	s.klogLocked(LogDebug, "mm", p.ID, "swapped in %q after %v", p.Name, s.now-p.swap.At)
+	s.memPressureLocked(p)
+	readyAt := p.readyAt
+	s.enqueueLocked(p)
+	p.readyAt = readyAt // the wait for swap-in is ready time too
+}
+
+// swapFreeLocked gives back the swap space of a process that exits.
+func (s *Scheduler) swapFreeLocked(p *Process) {
+	if !p.swap.Out {
+		return
+	}
+	p.swap.Out = false
+	p.swap.Time += s.now - p.swap.At
+	s.swapStats.Used -= p.mem
+	if i := slices.Index(s.swapQ, p); i >= 0 {
+		s.swapQ = slices.Delete(s.swapQ, i, i+1)
+	}
+}
+
+// swapIOLocked queues a transfer of p's memory behind the requests
+// already on the disk and adds it to the swap load.
+func (s *Scheduler) swapIOLocked(p *Process, out bool) {
+	d := s.devices.DiskSeek + time.Duration(p.mem)*s.swap.Cost
+	s.diskBusy = max(s.now, s.diskBusy) + d
+	s.raiseLocked(irq{At: s.diskBusy, Dev: DevDisk, PID: p.ID, Write: out, Swap: true})
+	s.swapTickLocked()
+	st := &s.swapStats
+	st.Time += d
+	st.Load += float64(d) / float64(s.swapWindow())
+	if st.Thrashing || st.Load <= s.swap.Thrash {
+		return
+	}
+	live := 0
+	for _, q := range s.procs {
+		if q.state != StateExited {
+			live++
+		}
+	}
+	st.Thrashing = true
+	st.Episodes++
+	if st.Episodes == 1 {
+		st.ThrashAt, st.ThrashLoad, st.ThrashProcs = s.now, s.loadAvg[0], live
+	}
+	s.klogLocked(LogWarn, "mm", 0, "thrashing: swapping wants %.0f%% of the disk at load %.2f with %d processes",
+		100*st.Load, s.loadAvg[0], live)
+}
+
+// swapWindow is what the swap load is damped over, the middle load
+// average window: long enough that a few transfers are not thrashing.
+func (s *Scheduler) swapWindow() time.Duration {
+	return time.Duration(loadWindows[1]) * s.unit
+}
+
+// swapTickLocked decays the swap load to now and ends a thrashing
+// episode once it has fallen to half the threshold.
+func (s *Scheduler) swapTickLocked() {
+	st := &s.swapStats
+	if dt := s.now - st.LoadAt; dt > 0 {
+		st.Load *= math.Exp(-float64(dt) / float64(s.swapWindow()))
+		st.LoadAt = s.now
+	}
+	if st.Thrashing && st.Load < s.swap.Thrash/2 {
+		st.Thrashing = false
+		s.klogLocked(LogNotice, "mm", 0, "thrashing over")
+	}
+}
+
+// SwapStats reports physical memory, the swap area and thrashing.
+func (s *Scheduler) SwapStats() SwapStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.swapStats
+	load := st.Load * math.Exp(-float64(s.now-st.LoadAt)/float64(s.swapWindow()))
+	return SwapStat{
+		SwapConfig: s.swap, Resident: s.residentLocked(), Used: st.Used, Ins: st.Ins, Outs: st.Outs,
+		Time: st.Time, Load: load, Thrashing: st.Thrashing, Episodes: st.Episodes,
+		ThrashAt: st.ThrashAt, ThrashLoad: st.ThrashLoad, ThrashProcs: st.ThrashProcs,
+	}
+}
@@ -0,0 +1,77 @@
+package sched
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwapOutUnderMemoryPressure(t *testing.T) {
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithSwap(SwapConfig{Memory: 2048}))
+	for _, name := range []string{"a", "b", "c"} {
+		s.Spawn(&ProcessSpec{Name: name, Memory: 1024, Program: Repeat(3, Compute(1), Sleep(2))})
+	}
+	if st := s.SwapStats(); st.Resident != 2048 || st.Used != 1024 || st.Outs != 1 {
+		t.Errorf("after spawning: %+v, want one process swapped out", st)
+	}
+	if stats := s.Stats(); !stats[0].Swapped || stats[1].Swapped || stats[2].Swapped {
+		t.Errorf("swapped %v %v %v, want the first spawned, which has waited longest", stats[0].Swapped, stats[1].Swapped, stats[2].Swapped)
+	}
+	data, _ := s.ReadFile(ProcDir + "/1/status")
+	if !strings.Contains(data, "VmRSS:\t0 B\nVmSwap:\t1024 B\n") {
+		t.Errorf("/proc/1/status = %q", data)
+	}
+	runToEnd(s)
+
+	st := s.SwapStats()
+	if st.Ins == 0 || st.Ins != st.Outs || st.Used != 0 || st.Time == 0 {
+		t.Errorf("at the end: %+v, want everything swapped back in", st)
+	}
+	for _, p := range s.Stats() {
+		if p.ExitReason != "" || p.Swapped {
+			t.Errorf("%s: exit %q, swapped %v", p.Name, p.ExitReason, p.Swapped)
+		}
+		if p.SwapOuts > 0 && (p.SwapIns != p.SwapOuts || p.SwapTime == 0) {
+			t.Errorf("%s: %d ins, %d outs, %v swapped out", p.Name, p.SwapIns, p.SwapOuts, p.SwapTime)
+		}
+	}
+	if len(s.Dmesg(LogFilter{Subsys: "mm"})) == 0 {
+		t.Error("no swapping in the kernel log")
+	}
+}
+
+func TestSwapAreaLimit(t *testing.T) {
+	s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithSwap(SwapConfig{Memory: 1024, Size: 1024}))
+	for _, name := range []string{"a", "b", "c"} {
+		s.Spawn(&ProcessSpec{Name: name, Memory: 1024, Program: Repeat(2, Compute(1), Sleep(2))})
+	}
+	if st := s.SwapStats(); st.Used != 1024 || st.Resident != 2048 {
+		t.Errorf("%+v, want the swap area full and the rest left resident", st)
+	}
+	runToEnd(s)
+	for _, p := range s.Stats() {
+		if p.State != StateExited || p.ExitReason != "" {
+			t.Errorf("%s: %v %q", p.Name, p.State, p.ExitReason)
+		}
+	}
+}
+
+func TestThrashingSetsInWithLoad(t *testing.T) {
+	onset := func(mem int) SwapStat {
+		s := NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond), WithSwap(SwapConfig{Memory: mem}))
+		Swapping(s)
+		runToEnd(s)
+		return s.SwapStats()
+	}
+	if st := onset(2048); st.Outs != 0 || st.Episodes != 0 {
+		t.Errorf("with room for every worker: %+v", st)
+	}
+	big, small := onset(1536), onset(512)
+	if big.Episodes == 0 || small.Episodes == 0 {
+		t.Fatalf("no thrashing: %+v, %+v", big, small)
+	}
+	if big.ThrashProcs <= small.ThrashProcs || small.Outs <= big.Outs {
+		t.Errorf("thrashing set in with %d processes in 1536 bytes and %d in 512, after %d and %d swap-outs",
+			big.ThrashProcs, small.ThrashProcs, big.Outs, small.Outs)
+	}
+}
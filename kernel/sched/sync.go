@@ -0,0 +1,527 @@
+package sched
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+)
+
+type LockMode int
+
+const (
+	LockShared LockMode = iota
+	LockExclusive
+)
+
+var (
+	ErrNotOwner  = errors.New("mutex not held by caller")
+	ErrRelock    = errors.New("mutex already held by caller")
+	ErrNotLocked = errors.New("file not locked by caller")
+)
+
+type kmutex struct {
+	owner     *Process
+	waiters   []*Process
+	acquires  int
+	contended int
+	misuses   int
+}
+
+type ksem struct {
+	count     int
+	total     int
+	held      map[int]int
+	waiters   []*Process
+	acquires  int
+	contended int
+}
+
+type condWaiter struct {
+	p     *Process
+	mutex string
+}
+
+type kcond struct {
+	waiters []condWaiter
+	waits   int
+	signals int
+}
+
+type flockWaiter struct {
+	p    *Process
+	mode LockMode
+}
+
+type kflock struct {
+	readers   map[int]*Process
+	writer    *Process
+	waiters   []flockWaiter
+	acquires  int
+	contended int
+	misuses   int
+}
+
+type SyncStat struct {
+	Kind      string
+	Name      string
+	Value     int
+	Holders   []int
+	Waiters   []int
+	Acquires  int
+	Contended int
+	Misuses   int // unlocks by a non-holder and relocks by the holder
+}
+
+func (s *Scheduler) getMutex(name string) *kmutex {
+	m, ok := s.mutexes[name]
+	if !ok {
+		m = &kmutex{}
+		s.mutexes[name] = m
+	}
+	return m
+}
+
+func (s *Scheduler) getSem(name string) *ksem {
+	sem, ok := s.sems[name]
+	if !ok {
+		sem = &ksem{held: make(map[int]int)}
+		s.sems[name] = sem
+	}
+	return sem
+}
+
+func (s *Scheduler) getCond(name string) *kcond {
+	c, ok := s.conds[name]
+	if !ok {
+		c = &kcond{}
+		s.conds[name] = c
+	}
+	return c
+}
+
+func (s *Scheduler) getFlock(path string) *kflock {
+	l, ok := s.flocks[path]
+	if !ok {
+		l = &kflock{readers: make(map[int]*Process)}
+		s.flocks[path] = l
+	}
+	return l
+}
+
+// NewSemaphore creates (or resets) a named counting semaphore.
+func (s *Scheduler) NewSemaphore(name string, count int) {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordLocked(Event{Kind: EvSem, Arg: name, N: count})
+	sem := s.getSem(name)
+	sem.count = count
+	sem.total = count
+}
+
+// block parks p on a wait queue; it is only made ready again by wake.
+// Both must be called with s.mu held.
+func (s *Scheduler) block(p *Process, on string) {
+	p.state = StateBlocked
+	p.waitingOn = on
+	p.blockedAt = s.now
+	s.procEventLocked(p, Observer.OnBlock)
+}
+
+func (s *Scheduler) wake(p *Process) {
+	p.state = StateReady
+	p.waitingOn = ""
+	s.blockWait.observe(s.now - p.blockedAt)
+	s.placeLocked(p, true)
+	s.enqueueLocked(p)
+	s.procEventLocked(p, Observer.OnWake)
+}
+
+// mutexLock reports whether p got the mutex; it is an error for p to
+// lock one it already holds, which would otherwise never wake.
+func (s *Scheduler) mutexLock(p *Process, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m := s.getMutex(name); m.owner == p {
+		m.misuses++
+		return false, ErrRelock
+	}
+	return s.mutexLockLocked(p, name), nil
+}
+
+func (s *Scheduler) grantMutexLocked(p *Process, name string) bool {
+	m := s.getMutex(name)
+	if m.owner != nil || !s.bankersAllowLocked(p, name) {
+		return false
+	}
+	m.owner = p
+	m.acquires++
+	return true
+}
+
+func (s *Scheduler) mutexLockLocked(p *Process, name string) bool {
+	if !s.claimOKLocked(p, name) {
+		return false
+	}
+	if s.grantMutexLocked(p, name) {
+		return true
+	}
+	m := s.getMutex(name)
+	m.contended++
+	if s.managed[name] {
+		s.pending = append(s.pending, pendingReq{p: p, kind: "mutex", name: name})
+	} else {
+		m.waiters = append(m.waiters, p)
+	}
+	s.block(p, "mutex:"+name)
+	return false
+}
+
+func (s *Scheduler) mutexUnlock(p *Process, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mutexUnlockLocked(p, name)
+}
+
+func (s *Scheduler) mutexUnlockLocked(p *Process, name string) error {
+	m := s.getMutex(name)
+	if m.owner != p {
+		m.misuses++
+		return ErrNotOwner
+	}
+	m.owner = nil
+	if len(m.waiters) > 0 {
+		next := m.waiters[0]
+		m.waiters = m.waiters[1:]
+		m.owner = next
+		m.acquires++
+		s.wake(next)
+	}
+	s.retryPendingLocked()
+	return nil
+}
+
+func (s *Scheduler) grantSemLocked(p *Process, name string) bool {
+	sem := s.getSem(name)
+	if sem.count == 0 || !s.bankersAllowLocked(p, name) {
+		return false
+	}
+	sem.count--
+	sem.held[p.ID]++
+	sem.acquires++
+	return true
+}
+
+func (s *Scheduler) semWait(p *Process, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.claimOKLocked(p, name) {
+		return false
+	}
+	if s.grantSemLocked(p, name) {
+		return true
+	}
+	sem := s.getSem(name)
+	sem.contended++
+	if s.managed[name] {
+		s.pending = append(s.pending, pendingReq{p: p, kind: "sem", name: name})
+	} else {
+		sem.waiters = append(sem.waiters, p)
+	}
+	s.block(p, "sem:"+name)
+	return false
+}
+
+func (s *Scheduler) semPost(p *Process, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.semPostLocked(p, name)
+}
+
+func (s *Scheduler) semPostLocked(p *Process, name string) {
+	sem := s.getSem(name)
+	if p != nil && sem.held[p.ID] > 0 {
+		sem.held[p.ID]--
+		if sem.held[p.ID] == 0 {
+			delete(sem.held, p.ID)
+		}
+	}
+	if len(sem.waiters) > 0 {
+		next := sem.waiters[0]
+		sem.waiters = sem.waiters[1:]
+		sem.held[next.ID]++
+		sem.acquires++
+		s.wake(next)
+		return
+	}
+	sem.count++
+	s.retryPendingLocked()
+}
+
+// condWait atomically releases mutex and parks p on cond. The process
+// always blocks; once signalled it queues to reacquire the mutex.
+func (s *Scheduler) condWait(p *Process, cond, mutex string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.mutexUnlockLocked(p, mutex); err != nil {
+		return err
+	}
+	c := s.getCond(cond)
+	c.waits++
+	c.waiters = append(c.waiters, condWaiter{p: p, mutex: mutex})
+	s.block(p, "cond:"+cond)
+	return nil
+}
+
+func (s *Scheduler) condSignal(cond string, all bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.getCond(cond)
+	n := 1
+	if all {
+		n = len(c.waiters)
+	}
+	for i := 0; i < n && len(c.waiters) > 0; i++ {
+		w := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		c.signals++
+		if s.grantMutexLocked(w.p, w.mutex) {
+			s.wake(w.p)
+			continue
+		}
+		if s.managed[w.mutex] {
+			s.pending = append(s.pending, pendingReq{p: w.p, kind: "mutex", name: w.mutex})
+		} else {
+			m := s.getMutex(w.mutex)
+			m.waiters = append(m.waiters, w.p)
+		}
+		w.p.waitingOn = "mutex:" + w.mutex
+	}
+}
+
+func (l *kflock) compatible(p *Process, mode LockMode) bool {
+	if l.writer != nil && l.writer != p {
+		return false
+	}
+	if mode == LockShared {
+		return true
+	}
+	for pid := range l.readers {
+		if pid != p.ID {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *kflock) grant(p *Process, mode LockMode) {
+	delete(l.readers, p.ID)
+	if l.writer == p {
+		l.writer = nil
+	}
+	if mode == LockExclusive {
+		l.writer = p
+	} else {
+		l.readers[p.ID] = p
+	}
+	l.acquires++
+}
+
+func (s *Scheduler) flock(p *Process, path string, mode LockMode) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.getFlock(path)
+	if len(l.waiters) == 0 && l.compatible(p, mode) {
+		l.grant(p, mode)
+		return true
+	}
+	// As in flock(2), a conversion that has to wait gives up the lock p
+	// already holds first, or the waiters queued behind it would wait on
+	// p and p on them.
+	if _, ok := l.readers[p.ID]; ok || l.writer == p {
+		s.dropFlockLocked(l, p)
+		if len(l.waiters) == 0 && l.compatible(p, mode) {
+			l.grant(p, mode)
+			return true
+		}
+	}
+	l.contended++
+	l.waiters = append(l.waiters, flockWaiter{p: p, mode: mode})
+	s.block(p, "flock:"+path)
+	return false
+}
+
+func (s *Scheduler) funlock(p *Process, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.funlockLocked(p, path)
+}
+
+func (s *Scheduler) funlockLocked(p *Process, path string) error {
+	l := s.getFlock(path)
+	if _, ok := l.readers[p.ID]; !ok && l.writer != p {
+		l.misuses++
+		return ErrNotLocked
+	}
+	s.dropFlockLocked(l, p)
+	return nil
+}
+
+// dropFlockLocked releases p's hold on l and grants the waiters at the
+// head of the queue that are now compatible.
+func (s *Scheduler) dropFlockLocked(l *kflock, p *Process) {
+	delete(l.readers, p.ID)
+	if l.writer == p {
+		l.writer = nil
+	}
+	for len(l.waiters) > 0 && l.compatible(l.waiters[0].p, l.waiters[0].mode) {
+		w := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		l.grant(w.p, w.mode)
+		s.wake(w.p)
+	}
+}
+
+// releaseHeld drops every mutex and file lock still owned by p, the way
+// the kernel closes descriptors of an exiting process. A killed process
+// also gives back its semaphore units so that deadlock recovery frees
+// them. Called with s.mu held.
+func (s *Scheduler) releaseHeld(p *Process, sems bool) {
+	for _, name := range slices.Sorted(maps.Keys(s.mutexes)) {
+		if s.mutexes[name].owner == p {
+			_ = s.mutexUnlockLocked(p, name)
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(s.flocks)) {
+		l := s.flocks[path]
+		if _, ok := l.readers[p.ID]; ok || l.writer == p {
+			s.dropFlockLocked(l, p)
+		}
+	}
+	if !sems {
+		return
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.sems)) {
+		for s.sems[name].held[p.ID] > 0 {
+			s.semPostLocked(p, name)
+		}
+	}
+}
+
+// dequeueLocked removes a blocked process from whatever wait queue holds it.
+func (s *Scheduler) dequeueLocked(p *Process) {
+	drop := func(ps []*Process) []*Process {
+		out := ps[:0]
+		for _, q := range ps {
+			if q != p {
+				out = append(out, q)
+			}
+		}
+		return out
+	}
+	for _, m := range s.mutexes {
+		m.waiters = drop(m.waiters)
+	}
+	for _, sem := range s.sems {
+		sem.waiters = drop(sem.waiters)
+	}
+	for _, c := range s.conds {
+		ws := c.waiters[:0]
+		for _, w := range c.waiters {
+			if w.p != p {
+				ws = append(ws, w)
+			}
+		}
+		c.waiters = ws
+	}
+	for _, l := range s.flocks {
+		ws := l.waiters[:0]
+		for _, w := range l.waiters {
+			if w.p != p {
+				ws = append(ws, w)
+			}
+		}
+		l.waiters = ws
+	}
+	for _, pp := range s.pipes {
+		pp.Forget(p.ID)
+	}
+	s.ttyWaiters = drop(s.ttyWaiters)
+	p.recv = nil
+	pend := s.pending[:0]
+	for _, r := range s.pending {
+		if r.p != p {
+			pend = append(pend, r)
+		}
+	}
+	s.pending = pend
+}
+
+func pids(ps []*Process) []int {
+	out := make([]int, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, p.ID)
+	}
+	return out
+}
+
+func (s *Scheduler) SyncStats() []SyncStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []SyncStat{}
+
+	for name, m := range s.mutexes {
+		st := SyncStat{Kind: "mutex", Name: name, Waiters: pids(m.waiters), Acquires: m.acquires, Contended: m.contended, Misuses: m.misuses}
+		if m.owner != nil {
+			st.Holders = []int{m.owner.ID}
+		}
+		out = append(out, st)
+	}
+	for name, sem := range s.sems {
+		st := SyncStat{Kind: "sem", Name: name, Value: sem.count, Waiters: pids(sem.waiters), Acquires: sem.acquires, Contended: sem.contended}
+		for pid := range sem.held {
+			st.Holders = append(st.Holders, pid)
+		}
+		sort.Ints(st.Holders)
+		out = append(out, st)
+	}
+	for name, c := range s.conds {
+		ws := make([]int, 0, len(c.waiters))
+		for _, w := range c.waiters {
+			ws = append(ws, w.p.ID)
+		}
+		out = append(out, SyncStat{Kind: "cond", Name: name, Waiters: ws, Acquires: c.signals, Contended: c.waits})
+	}
+	for path, l := range s.flocks {
+		st := SyncStat{Kind: "flock", Name: path, Acquires: l.acquires, Contended: l.contended, Misuses: l.misuses}
+		if l.writer != nil {
+			st.Holders = append(st.Holders, l.writer.ID)
+		}
+		for pid := range l.readers {
+			st.Holders = append(st.Holders, pid)
+		}
+		sort.Ints(st.Holders)
+		for _, w := range l.waiters {
+			st.Waiters = append(st.Waiters, w.p.ID)
+		}
+		out = append(out, st)
+	}
+
+	for _, r := range s.pending {
+		for i := range out {
+			if out[i].Kind == r.kind && out[i].Name == r.name {
+				out[i].Waiters = append(out[i].Waiters, r.p.ID)
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -0,0 +1,164 @@
+package sched
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestScheduler() *Scheduler {
+	return NewScheduler(WithQuantum(time.Millisecond), WithUnit(time.Millisecond))
+}
+
+func waitExited(t *testing.T, s *Scheduler, timeout time.Duration) []ProcessStat {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		stats := s.Stats()
+		done := true
+		for _, st := range stats {
+			if st.State != StateExited {
+				done = false
+			}
+		}
+		if done {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("processes did not exit in %v: %+v", timeout, stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func syncStat(stats []SyncStat, kind, name string) SyncStat {
+	for _, st := range stats {
+		if st.Kind == kind && st.Name == name {
+			return st
+		}
+	}
+	return SyncStat{}
+}
+
+func TestMutexSerializesCriticalSection(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	crit := []Op{MutexLock("m"), Compute(3), FileWrite("shared", "x"), MutexUnlock("m")}
+	s.Spawn(&ProcessSpec{Name: "a", Program: crit})
+	s.Spawn(&ProcessSpec{Name: "b", Program: crit})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	st := syncStat(s.SyncStats(), "mutex", "m")
+	if st.Acquires != 2 || st.Contended != 1 {
+		t.Errorf("mutex m: acquires=%d contended=%d, want 2 and 1", st.Acquires, st.Contended)
+	}
+	if len(st.Holders) != 0 || len(st.Waiters) != 0 {
+		t.Errorf("mutex m still held: %+v", st)
+	}
+}
+
+func TestSemaphoreBlocksUntilPost(t *testing.T) {
+	s := newTestScheduler()
+
+	s.NewSemaphore("items", 0)
+	consumer := s.Spawn(&ProcessSpec{Name: "consumer", Priority: 0, Program: []Op{SemWait("items"), Compute(1)}})
+	s.Spawn(&ProcessSpec{Name: "producer", Priority: 1, Program: []Op{Compute(5), SemPost("items")}})
+
+	s.Step(3)
+	if st := s.Stats()[consumer-1]; st.State != StateBlocked || st.WaitingOn != "sem:items" {
+		t.Fatalf("consumer %v on %q before the producer posted, want blocked on sem:items", st.State, st.WaitingOn)
+	}
+
+	runToEnd(s)
+	if st := s.Stats()[consumer-1]; st.State != StateExited || st.ExitReason != "" {
+		t.Fatalf("consumer %v (%s) after the post, want exited", st.State, st.ExitReason)
+	}
+	st := syncStat(s.SyncStats(), "sem", "items")
+	if st.Value != 0 || st.Contended != 1 {
+		t.Errorf("sem items: value=%d contended=%d, want 0 and 1", st.Value, st.Contended)
+	}
+}
+
+func TestCondVarSignalReacquiresMutex(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	s.Spawn(&ProcessSpec{Name: "waiter", Program: []Op{
+		MutexLock("m"), CondWait("ready", "m"), FileWrite("woke", "yes"), MutexUnlock("m"),
+	}})
+	s.Spawn(&ProcessSpec{Name: "signaller", Priority: 1, Program: []Op{
+		Compute(2), MutexLock("m"), CondSignal("ready"), Compute(2), MutexUnlock("m"),
+	}})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	if _, ok := s.fs.ReadFile("woke"); !ok {
+		t.Errorf("waiter never resumed after signal")
+	}
+	if st := syncStat(s.SyncStats(), "mutex", "m"); len(st.Holders) != 0 {
+		t.Errorf("mutex m left held by %v", st.Holders)
+	}
+}
+
+func TestFlockSharedAndExclusive(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	reader := []Op{FileLock("db", LockShared), Compute(3), FileUnlock("db")}
+	s.Spawn(&ProcessSpec{Name: "r1", Program: reader})
+	s.Spawn(&ProcessSpec{Name: "r2", Program: reader})
+	s.Spawn(&ProcessSpec{Name: "w", Program: []Op{FileLock("db", LockExclusive), Compute(1), FileUnlock("db")}})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	st := syncStat(s.SyncStats(), "flock", "db")
+	if st.Acquires != 3 || st.Contended != 1 {
+		t.Errorf("flock db: acquires=%d contended=%d, want 3 and 1", st.Acquires, st.Contended)
+	}
+}
+
+func TestFileLockUpgradeUnderContention(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	s.Spawn(&ProcessSpec{Name: "upgrader", Program: []Op{
+		FileLock("db", LockShared), Compute(2), FileLock("db", LockExclusive), Compute(1), FileUnlock("db"),
+	}})
+	s.Spawn(&ProcessSpec{Name: "writer", Program: []Op{FileLock("db", LockExclusive), Compute(1), FileUnlock("db")}})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	if st := syncStat(s.SyncStats(), "flock", "db"); st.Acquires != 3 || st.Contended != 2 || len(st.Holders) != 0 {
+		t.Errorf("flock db: %+v, want the writer served between the shared and exclusive holds", st)
+	}
+}
+
+func TestLockMisuseIsCounted(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	s.Spawn(&ProcessSpec{Name: "p", Program: []Op{
+		MutexUnlock("m"), MutexLock("m"), MutexLock("m"), CondWait("c", "n"), FileUnlock("f"), MutexUnlock("m"),
+	}})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+
+	stats := s.SyncStats()
+	if m, n, f := syncStat(stats, "mutex", "m"), syncStat(stats, "mutex", "n"), syncStat(stats, "flock", "f"); m.Misuses != 2 || n.Misuses != 1 || f.Misuses != 1 {
+		t.Errorf("misuses m=%d n=%d f=%d, want 2, 1 and 1", m.Misuses, n.Misuses, f.Misuses)
+	}
+	if m := syncStat(stats, "mutex", "m"); len(m.Holders) != 0 || m.Acquires != 1 {
+		t.Errorf("mutex m: %+v", m)
+	}
+}
+
+func TestExitReleasesFileLocks(t *testing.T) {
+	s := newTestScheduler()
+	defer s.Stop()
+
+	s.Spawn(&ProcessSpec{Name: "leaky", Program: []Op{FileLock("db", LockExclusive), Compute(2)}})
+	s.Spawn(&ProcessSpec{Name: "next", Program: []Op{FileLock("db", LockExclusive), FileUnlock("db")}})
+	s.Start()
+	waitExited(t, s, 2*time.Second)
+}
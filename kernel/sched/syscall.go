@@ -0,0 +1,618 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosimos/fs"
+)
+
+// Sysno numbers a system call. A call's number is the kind of the op that
+// makes it, so 0 (OpCompute) is not a call.
+type Sysno int
+
+// syscalls describes every call by number. Retried calls leave the
+// program counter alone when they block and are entered again once woken;
+// the others are completed by whoever wakes the caller. Cost is the
+// default price in hundredths of a work unit, see DefaultSyscallCosts.
+var syscalls = [...]struct {
+	name  string
+	retry bool
+	cost  int
+}{
+	OpLock:          {name: "mutex_lock", cost: 2},
+	OpUnlock:        {name: "mutex_unlock", cost: 1},
+	OpSemWait:       {name: "sem_wait", cost: 2},
+	OpSemPost:       {name: "sem_post", cost: 1},
+	OpCondWait:      {name: "cond_wait", cost: 2},
+	OpCondSignal:    {name: "cond_signal", cost: 1},
+	OpCondBroadcast: {name: "cond_broadcast", cost: 1},
+	OpFlock:         {name: "flock", cost: 2},
+	OpFunlock:       {name: "funlock", cost: 1},
+	OpWrite:         {name: "writefile", cost: 5},
+	OpPipe:          {name: "pipe", cost: 5},
+	OpSpawn:         {name: "fork", cost: 10},
+	OpFdRead:        {name: "read", retry: true, cost: 2},
+	OpFdWrite:       {name: "write", retry: true, cost: 2},
+	OpFdClose:       {name: "close", cost: 1},
+	OpMkfifo:        {name: "mkfifo", cost: 5},
+	OpOpen:          {name: "open", cost: 5},
+	OpShmAttach:     {name: "shmat", cost: 5},
+	OpShmDetach:     {name: "shmdt", cost: 1},
+	OpShmWrite:      {name: "shm_write", cost: 2},
+	OpShmRead:       {name: "shm_read", cost: 2},
+	OpSend:          {name: "msgsnd", cost: 2},
+	OpRecv:          {name: "msgrcv", retry: true, cost: 2},
+	OpCall:          {name: "msgcall", retry: true, cost: 2},
+	OpJoin:          {name: "join", cost: 1},
+	OpLeave:         {name: "leave", cost: 1},
+	OpAlloc:         {name: "brk", cost: 1},
+	OpSleep:         {name: "nanosleep", retry: true, cost: 2},
+	OpDiskRead:      {name: "disk_read", retry: true, cost: 5},
+	OpDiskWrite:     {name: "disk_write", retry: true, cost: 5},
+	OpNetSend:       {name: "sendto", cost: 2},
+	OpTTYRead:       {name: "tty_read", retry: true, cost: 2},
+	OpKill:          {name: "kill", cost: 1},
+	OpChmod:         {name: "chmod", cost: 2},
+	OpThread:        {name: "clone", cost: 5},
+	OpThreadJoin:    {name: "thread_join", retry: true, cost: 2},
+	OpThreadExit:    {name: "thread_exit", cost: 1},
+	OpYield:         {name: "sched_yield", cost: 1},
+}
+
+const numSyscalls = Sysno(len(syscalls))
+
+func (n Sysno) valid() bool { return n > 0 && n < numSyscalls }
+
+func (n Sysno) String() string {
+	if !n.valid() {
+		return "syscall_" + strconv.Itoa(int(n))
+	}
+	return syscalls[n].name
+}
+
+// DefaultSyscallCosts prices every call at a hundredth to a tenth of
+// unit: cheapest for releasing an object, dearest for fork.
+func DefaultSyscallCosts(unit time.Duration) map[Sysno]time.Duration {
+	out := make(map[Sysno]time.Duration, numSyscalls-1)
+	for n := Sysno(1); n < numSyscalls; n++ {
+		out[n] = time.Duration(syscalls[n].cost) * unit / 100
+	}
+	return out
+}
+
+// Errno is the error code a failed system call returns.
+type Errno int
+
+const (
+	EPERM   Errno = 1
+	ENOENT  Errno = 2
+	ESRCH   Errno = 3
+	EBADF   Errno = 9
+	EAGAIN  Errno = 11
+	ENOMEM  Errno = 12
+	EACCES  Errno = 13
+	EFAULT  Errno = 14
+	EINVAL  Errno = 22
+	EPIPE   Errno = 32
+	EDEADLK Errno = 35
+)
+
+var errnos = map[Errno][2]string{
+	EPERM:   {"EPERM", "operation not permitted"},
+	ENOENT:  {"ENOENT", "no such file or directory"},
+	ESRCH:   {"ESRCH", "no such process"},
+	EBADF:   {"EBADF", "bad file descriptor"},
+	EAGAIN:  {"EAGAIN", "resource temporarily unavailable"},
+	ENOMEM:  {"ENOMEM", "cannot allocate memory"},
+	EACCES:  {"EACCES", "permission denied"},
+	EFAULT:  {"EFAULT", "bad address"},
+	EINVAL:  {"EINVAL", "invalid argument"},
+	EPIPE:   {"EPIPE", "broken pipe"},
+	EDEADLK: {"EDEADLK", "resource deadlock avoided"},
+}
+
+// Name is the symbolic name, e.g. "EBADF".
+func (e Errno) Name() string {
+	if n, ok := errnos[e]; ok {
+		return n[0]
+	}
+	return "E" + strconv.Itoa(int(e))
+}
+
+func (e Errno) Error() string {
+	if n, ok := errnos[e]; ok {
+		return n[1]
+	}
+	return "errno " + strconv.Itoa(int(e))
+}
+
+// errnoOf maps a kernel error to the code a system call returns for it.
+func errnoOf(err error) Errno {
+	var e Errno
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, ErrNotOwner), errors.Is(err, ErrNotLocked),
+		errors.Is(err, ErrPermission), errors.Is(err, fs.ErrNotOwner):
+		return EPERM
+	case errors.Is(err, ErrRelock):
+		return EDEADLK
+	case errors.Is(err, fs.ErrPermission):
+		return EACCES
+	case errors.Is(err, ErrNoProcess):
+		return ESRCH
+	case errors.Is(err, ErrBadFd):
+		return EBADF
+	case errors.Is(err, ErrBrokenPipe):
+		return EPIPE
+	case errors.Is(err, ErrSegv):
+		return EFAULT
+	case errors.Is(err, ErrNoFifo), errors.Is(err, ErrNoCgroup), errors.Is(err, fs.ErrNotExist):
+		return ENOENT
+	case errors.Is(err, ErrPidsLimit):
+		return EAGAIN
+	}
+	return EINVAL
+}
+
+// SyscallRecord is one line of a process's strace log. A call that blocks
+// is logged as unfinished when it is entered; a retried call is logged
+// again, as resumed, when it completes.
+type SyscallRecord struct {
+	Time    time.Duration // simulated clock at entry
+	Nr      Sysno
+	Args    string `json:",omitempty"`
+	Ret     int
+	Errno   Errno `json:",omitempty"`
+	Blocked bool  `json:",omitempty"`
+	Resumed bool  `json:",omitempty"`
+}
+
+// String formats r the way strace does, without the timestamp.
+func (r SyscallRecord) String() string {
+	var b strings.Builder
+	if r.Resumed {
+		fmt.Fprintf(&b, "<... %s resumed>", r.Nr)
+	} else {
+		fmt.Fprintf(&b, "%s(%s)", r.Nr, r.Args)
+	}
+	switch {
+	case r.Errno != 0:
+		fmt.Fprintf(&b, " = -1 %s (%s)", r.Errno.Name(), r.Errno)
+	case r.Blocked:
+		b.WriteString(" <unfinished ...>")
+	default:
+		fmt.Fprintf(&b, " = %d", r.Ret)
+	}
+	return b.String()
+}
+
+// straceLimit caps each process's log; older records are dropped.
+const straceLimit = 4096
+
+// SyscallStat totals one system call across all processes.
+type SyscallStat struct {
+	Nr     Sysno
+	Name   string
+	Calls  int
+	Errors int
+	Time   time.Duration // charged as its cost
+}
+
+type sysCount struct {
+	Calls  int
+	Errors int
+	Time   time.Duration
+}
+
+// sysResult is what a call gave back to the program that made it.
+type sysResult struct {
+	ret     int
+	data    string // what a read returned
+	read    bool   // data replaces what the process last read
+	blocked bool
+	fatal   bool // err terminates the caller
+	killed  bool // the caller was killed during the call
+	yield   bool // the caller gives up the CPU
+	exit    bool // the calling thread is done
+	err     error
+}
+
+// syscall is a process entering the kernel: every service a program or a
+// legacy behaviour uses goes through here. It validates op, charges its
+// cost, runs it and adds it to p's trace. Errors the kernel has always
+// treated as faults still terminate p with their own message; the rest
+// come back as error codes and the program carries on.
+func (s *Scheduler) syscall(p *Process, op Op) sysResult {
+	nr := Sysno(op.Kind)
+	resumed := p.sysOp != nil
+	p.sysOp = nil
+	if !resumed {
+		s.sysEnter(p, nr)
+	}
+
+	var r sysResult
+	if err := op.validate(); err != nil {
+		r.err = err
+	} else {
+		r = s.serve(p, op)
+	}
+	if r.blocked && syscalls[nr].retry && r.err == nil {
+		p.sysOp = &op
+	}
+	r.killed = s.sysExit(p, op, r, resumed)
+	if r.fatal {
+		s.fault(p, r.err)
+	}
+	return r
+}
+
+// sysEnter counts a call and charges its cost to the caller.
+func (s *Scheduler) sysEnter(p *Process, nr Sysno) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cost := s.sysCosts[nr]
+	s.now += cost
+	p.sysTime += cost
+	if p.sysCalls == nil {
+		p.sysCalls = make(map[Sysno]int)
+	}
+	p.sysCalls[nr]++
+	if nr.valid() {
+		s.sysStats[nr].Calls++
+		s.sysStats[nr].Time += cost
+	}
+}
+
+// sysExit logs a call's outcome and reports whether the caller was killed
+// while it was in the kernel.
+func (s *Scheduler) sysExit(p *Process, op Op, r sysResult, resumed bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resumed && r.blocked && r.err == nil {
+		return false // still waiting: the first entry already says so
+	}
+	rec := SyscallRecord{Time: s.now, Nr: Sysno(op.Kind), Ret: r.ret, Blocked: r.blocked, Resumed: resumed}
+	if !resumed {
+		rec.Args = op.args(p)
+	}
+	if r.err != nil {
+		rec.Errno, rec.Ret, rec.Blocked = errnoOf(r.err), -1, false
+		if rec.Nr.valid() {
+			s.sysStats[rec.Nr].Errors++
+		}
+		s.klogLocked(LogDebug, "syscall", p.ID, "%s", rec)
+	}
+	if len(p.strace) == straceLimit {
+		p.strace = append(p.strace[:0], p.strace[1:]...)
+	}
+	p.strace = append(p.strace, rec)
+	return p.exitReason != ""
+}
+
+// serve runs a validated call.
+func (s *Scheduler) serve(p *Process, op Op) sysResult {
+	var r sysResult
+	data := op.Data
+	if data == "" && op.Kind != OpCall {
+		data = p.acc
+	}
+	fault := func(err error) sysResult {
+		return sysResult{err: err, fatal: true}
+	}
+	switch op.Kind {
+	case OpLock:
+		var ok bool
+		ok, r.err = s.mutexLock(p, op.Name)
+		r.blocked = !ok && r.err == nil
+	case OpUnlock:
+		r.err = s.mutexUnlock(p, op.Name)
+	case OpSemWait:
+		r.blocked = !s.semWait(p, op.Name)
+	case OpSemPost:
+		s.semPost(p, op.Name)
+	case OpCondWait:
+		r.err = s.condWait(p, op.Name, op.Mutex)
+		r.blocked = r.err == nil
+	case OpCondSignal, OpCondBroadcast:
+		s.condSignal(op.Name, op.Kind == OpCondBroadcast)
+	case OpFlock:
+		r.blocked = !s.flock(p, op.Name, op.Mode)
+	case OpFunlock:
+		r.err = s.funlock(p, op.Name)
+	case OpWrite:
+		r.err, r.ret = s.fsWrite(p, op.Name, data), len(data)
+	case OpPipe:
+		s.pipeCreate(p, op.Fd, op.Fd2, op.Units)
+	case OpSpawn:
+		r.ret, r.err = s.spawnChild(p, op.Child)
+	case OpFdClose:
+		if err := s.fdClose(p, op.Fd); err != nil {
+			return fault(err)
+		}
+	case OpFdWrite:
+		done, err := s.fdWrite(p, op.Fd, data)
+		if err != nil {
+			return fault(err)
+		}
+		r.blocked, r.ret = !done, len(data)
+	case OpFdRead:
+		got, done, err := s.fdRead(p, op.Fd, op.Units)
+		if err != nil {
+			return fault(err)
+		}
+		r.blocked, r.data, r.read, r.ret = !done, got, done, len(got)
+	case OpMkfifo:
+		s.mkfifo(p, op.Name, op.Units)
+	case OpOpen:
+		if err := s.openFifo(p, op.Name, op.Fd, op.Write); errors.Is(err, fs.ErrPermission) {
+			return sysResult{err: err}
+		} else if err != nil {
+			return fault(err)
+		}
+		r.ret = op.Fd
+	case OpShmAttach:
+		s.shmAttach(p, op.Name, op.Units)
+	case OpShmDetach:
+		s.shmDetach(p, op.Name)
+	case OpShmWrite:
+		if err := s.shmWrite(p, op.Name, op.Offset, data); err != nil {
+			return fault(err)
+		}
+		r.ret = len(data)
+	case OpShmRead:
+		got, err := s.shmRead(p, op.Name, op.Offset, op.Units)
+		if err != nil {
+			return fault(err)
+		}
+		r.data, r.read, r.ret = got, true, len(got)
+	case OpSend:
+		seq, err := s.send(p, op, data)
+		r.ret, r.err = int(seq), err
+	case OpRecv:
+		m, ok := s.receive(p, recvFilter{typ: op.Name})
+		if !ok {
+			r.blocked = true
+			break
+		}
+		p.lastMsg = &m
+		r.data, r.read, r.ret = string(m.Payload), true, len(m.Payload)
+	case OpCall:
+		if p.callSeq == 0 {
+			seq, err := s.send(p, op, data)
+			if err != nil {
+				return sysResult{err: err}
+			}
+			if p.callSeq = seq; seq == 0 {
+				return fault(ErrNoProcess)
+			}
+		}
+		m, ok := s.receive(p, recvFilter{corr: p.callSeq})
+		if !ok {
+			r.blocked = true
+			break
+		}
+		p.callSeq = 0
+		p.lastMsg = &m
+		r.data, r.read, r.ret = string(m.Payload), true, len(m.Payload)
+	case OpJoin:
+		s.mu.Lock()
+		s.joinGroupLocked(p.proc().ID, op.Group)
+		s.mu.Unlock()
+	case OpLeave:
+		s.mu.Lock()
+		delete(s.groups[op.Group], p.proc().ID)
+		s.mu.Unlock()
+	case OpAlloc:
+		if !s.memAlloc(p, op.Units) {
+			r.err = ENOMEM
+		}
+	case OpSleep:
+		r.blocked = !s.sleep(p, op.Units)
+	case OpDiskRead, OpDiskWrite:
+		write := op.Kind == OpDiskWrite
+		want := fs.PermWrite
+		if !write {
+			data, want = "", fs.PermRead
+		}
+		if err := s.fsAccess(p, op.Name, want); err != nil {
+			return sysResult{err: err}
+		}
+		if !write && s.fs.Mounted(op.Name) {
+			got, err := s.procRead(p, op.Name)
+			return sysResult{ret: len(got), data: got, read: err == nil, err: err}
+		}
+		got, done := s.diskIO(p, op.Name, data, write)
+		r.blocked, r.ret = !done, len(data)
+		if !write {
+			r.data, r.read, r.ret = got, done, len(got)
+		}
+	case OpNetSend:
+		r.err, r.ret = s.netSend(p, op, data), len(data)
+	case OpTTYRead:
+		line, done := s.ttyRead(p)
+		r.blocked, r.data, r.read, r.ret = !done, line, done, len(line)
+	case OpKill:
+		r.err = s.killProc(p, op.Units)
+	case OpChmod:
+		r.err = s.chmod(p, op.Name, fs.Mode(op.Units))
+	case OpThread:
+		r.ret, r.err = s.threadCreate(p, op.Child)
+	case OpThreadJoin:
+		done, err := s.threadJoin(p, op.Name)
+		r.blocked, r.err = !done && err == nil, err
+	case OpThreadExit:
+		r.exit = true
+	case OpYield:
+		r.yield = true
+	}
+	return r
+}
+
+// validate checks op's arguments before the kernel acts on them.
+func (op Op) validate() error {
+	if !Sysno(op.Kind).valid() {
+		return EINVAL
+	}
+	switch op.Kind {
+	case OpFdRead, OpFdWrite, OpFdClose, OpOpen:
+		if op.Fd < 0 {
+			return EBADF
+		}
+	case OpPipe:
+		if op.Fd < 0 || op.Fd2 < 0 {
+			return EBADF
+		}
+		if op.Fd == op.Fd2 {
+			return EINVAL
+		}
+	case OpSpawn:
+		if op.Child == nil {
+			return EINVAL
+		}
+	case OpThread:
+		if op.Child == nil || op.Name == "" {
+			return EINVAL
+		}
+	case OpJoin, OpLeave:
+		if op.Group == "" {
+			return EINVAL
+		}
+	case OpChmod:
+		if op.Units > 0777 {
+			return EINVAL
+		}
+	}
+	switch op.Kind {
+	case OpLock, OpUnlock, OpSemWait, OpSemPost, OpCondSignal, OpCondBroadcast,
+		OpFlock, OpFunlock, OpWrite, OpMkfifo, OpOpen,
+		OpShmAttach, OpShmDetach, OpShmWrite, OpShmRead, OpDiskRead, OpDiskWrite, OpChmod, OpThreadJoin:
+		if op.Name == "" {
+			return EINVAL
+		}
+	case OpCondWait:
+		if op.Name == "" || op.Mutex == "" {
+			return EINVAL
+		}
+	}
+	if op.Offset < 0 || (op.Units < 0 && op.Kind != OpAlloc) {
+		return EINVAL
+	}
+	return nil
+}
+
+// args renders op's arguments for the trace, data as it was resolved.
+func (op Op) args(p *Process) string {
+	data := op.Data
+	if data == "" && op.Kind != OpCall {
+		data = p.acc
+	}
+	var a []string
+	add := func(v ...any) {
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				x = quote(s)
+			}
+			a = append(a, fmt.Sprint(x))
+		}
+	}
+	switch op.Kind {
+	case OpLock, OpUnlock, OpSemWait, OpSemPost, OpCondSignal, OpCondBroadcast,
+		OpFunlock, OpShmDetach, OpDiskRead, OpRecv, OpThread, OpThreadJoin:
+		add(op.Name)
+	case OpCondWait:
+		add(op.Name, op.Mutex)
+	case OpFlock:
+		mode := "LOCK_SH"
+		if op.Mode == LockExclusive {
+			mode = "LOCK_EX"
+		}
+		a = append(a, quote(op.Name), mode)
+	case OpWrite, OpDiskWrite:
+		add(op.Name, data)
+	case OpPipe:
+		a = append(a, fmt.Sprintf("[%d, %d]", op.Fd, op.Fd2), strconv.Itoa(op.Units))
+	case OpSpawn:
+		if op.Child != nil {
+			add(op.Child.Name)
+		}
+	case OpFdRead:
+		add(op.Fd, op.Units)
+	case OpFdWrite:
+		add(op.Fd, data)
+	case OpFdClose:
+		add(op.Fd)
+	case OpMkfifo, OpShmAttach:
+		add(op.Name, op.Units)
+	case OpOpen:
+		mode := "O_RDONLY"
+		if op.Write {
+			mode = "O_WRONLY"
+		}
+		a = append(a, quote(op.Name), strconv.Itoa(op.Fd), mode)
+	case OpShmWrite:
+		add(op.Name, op.Offset, data)
+	case OpShmRead:
+		add(op.Name, op.Offset, op.Units)
+	case OpSend, OpNetSend:
+		if op.Group != "" {
+			add(op.Group)
+		} else {
+			add(op.Units)
+		}
+		add(op.Name, data)
+	case OpCall:
+		add(op.Units, op.Name, data)
+	case OpJoin, OpLeave:
+		add(op.Group)
+	case OpAlloc, OpSleep, OpKill:
+		add(op.Units)
+	case OpChmod:
+		a = append(a, quote(op.Name), fmt.Sprintf("%#o", op.Units))
+	}
+	return strings.Join(a, ", ")
+}
+
+// quote is strconv.Quote cut at 32 bytes, as strace prints strings.
+func quote(s string) string {
+	if len(s) > 32 {
+		return strconv.Quote(s[:32]) + "..."
+	}
+	return strconv.Quote(s)
+}
+
+// Strace returns pid's system call log, oldest first.
+func (s *Scheduler) Strace(pid int) ([]SyscallRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.procs[pid]
+	if !ok {
+		return nil, ErrNoProcess
+	}
+	return append([]SyscallRecord(nil), p.strace...), nil
+}
+
+// SyscallStats totals the calls that have been made, by number.
+func (s *Scheduler) SyscallStats() []SyscallStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []SyscallStat
+	for n := Sysno(1); n < numSyscalls; n++ {
+		if st := s.sysStats[n]; st.Calls > 0 {
+			out = append(out, SyscallStat{Nr: n, Name: n.String(), Calls: st.Calls, Errors: st.Errors, Time: st.Time})
+		}
+	}
+	return out
+}
+
+// SyscallCosts reports what each call costs; calls not listed are free.
+func (s *Scheduler) SyscallCosts() map[Sysno]time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.sysCosts)
+}
@@ -0,0 +1,105 @@
+package sched
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func straceLines(t *testing.T, s *Scheduler, pid int) []string {
+	t.Helper()
+	recs, err := s.Strace(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, r := range recs {
+		out = append(out, r.String())
+	}
+	return out
+}
+
+func TestStraceShowsErrorsWithoutKillingTheCaller(t *testing.T) {
+	s := newTestScheduler()
+	pid := s.Spawn(&ProcessSpec{Name: "p", Program: []Op{
+		FileWrite("f", "hi"), MutexUnlock("m"), SemPost(""), Sleep(-1), MutexLock("m"),
+		MutexLock("m"), CondWait("c", "n"), FileUnlock("f"), FdClose(3),
+	}})
+	runToEnd(s)
+
+	want := []string{
+		`writefile("f", "hi") = 2`,
+		`mutex_unlock("m") = -1 EPERM (operation not permitted)`,
+		`sem_post("") = -1 EINVAL (invalid argument)`,
+		`nanosleep(-1) = -1 EINVAL (invalid argument)`,
+		`mutex_lock("m") = 0`,
+		`mutex_lock("m") = -1 EDEADLK (resource deadlock avoided)`,
+		`cond_wait("c", "n") = -1 EPERM (operation not permitted)`,
+		`funlock("f") = -1 EPERM (operation not permitted)`,
+		`close(3) = -1 EBADF (bad file descriptor)`,
+	}
+	if got := straceLines(t, s, pid); !slices.Equal(got, want) {
+		t.Errorf("strace:\n%q\nwant\n%q", got, want)
+	}
+	for _, st := range s.SyscallStats() {
+		if st.Name == "mutex_lock" && st.Errors != 1 {
+			t.Errorf("mutex_lock errors = %d, want the relock counted", st.Errors)
+		}
+	}
+	// Closing a descriptor that is not open has always been fatal.
+	if st := s.Stats()[pid-1]; st.ExitReason != ErrBadFd.Error() {
+		t.Errorf("exit reason %q", st.ExitReason)
+	}
+}
+
+func TestStraceResumesRetriedCalls(t *testing.T) {
+	s := newTestScheduler()
+	pid := s.Spawn(&ProcessSpec{Name: "p", Program: []Op{
+		PipeCreate(3, 4, 0),
+		SpawnChild(&ProcessSpec{Name: "reader", Program: []Op{FdClose(4), FdRead(3, 8)}}),
+		Compute(2),
+		FdWrite(4, "ok"),
+	}})
+	runToEnd(s)
+
+	if got, want := straceLines(t, s, pid+1), []string{
+		"close(4) = 0",
+		"read(3, 8) <unfinished ...>",
+		"<... read resumed> = 2",
+	}; !slices.Equal(got, want) {
+		t.Errorf("reader:\n%q\nwant\n%q", got, want)
+	}
+	if got, want := straceLines(t, s, pid), []string{
+		"pipe([3, 4], 0) = 0",
+		`fork("reader") = 2`,
+		`write(4, "ok") = 2`,
+	}; !slices.Equal(got, want) {
+		t.Errorf("parent:\n%q\nwant\n%q", got, want)
+	}
+	if _, err := s.Strace(99); err != ErrNoProcess {
+		t.Errorf("Strace(99) = %v", err)
+	}
+}
+
+func TestSyscallCostsAreCharged(t *testing.T) {
+	ms := time.Millisecond
+	s := NewScheduler(WithQuantum(ms), WithUnit(ms),
+		WithSyscallCosts(map[Sysno]time.Duration{Sysno(OpSemPost): ms, Sysno(OpSemWait): 2 * ms}))
+	pid := s.Spawn(&ProcessSpec{Name: "p", Program: append(Repeat(3, SemPost("s")), SemWait("s"), Join("g"))})
+	runToEnd(s)
+
+	if got := s.Now(); got != 5*ms {
+		t.Errorf("finished at %v, want 5ms", got)
+	}
+	st := s.Stats()[pid-1]
+	if st.SysTime != 5*ms {
+		t.Errorf("SysTime = %v", st.SysTime)
+	}
+	if want := map[string]int{"sem_post": 3, "sem_wait": 1, "join": 1}; !maps.Equal(st.Syscalls, want) {
+		t.Errorf("Syscalls = %v, want %v", st.Syscalls, want)
+	}
+	if got := s.SyscallStats(); len(got) != 3 || got[0].Name != "sem_wait" || got[1].Time != 3*ms || got[2].Time != 0 {
+		t.Errorf("SyscallStats = %+v", got)
+	}
+}
@@ -0,0 +1,283 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrThreadDeadlock ends a process whose user-level threads are all
+// waiting to join one another.
+var ErrThreadDeadlock = errors.New("user-level threads deadlocked")
+
+// ThreadModel is how a process's threads map onto what the kernel
+// schedules. With KernelThreads (1:1, the default) every thread is a task
+// of its own with a PID, dispatched like any process, so one thread
+// blocking leaves the others running. With UserThreads (N:1) a library
+// inside the process switches between them on ThreadJoin, Yield and exit:
+// the kernel sees a single process, and a blocking call stops them all.
+type ThreadModel int
+
+const (
+	KernelThreads ThreadModel = iota
+	UserThreads
+)
+
+func (m ThreadModel) String() string {
+	if m == UserThreads {
+		return "N:1"
+	}
+	return "1:1"
+}
+
+// ThreadStat is one thread of a process, the main thread first. TID is a
+// kernel thread's PID, or a user-level thread's index in its process.
+// Runs counts dispatches, or the library's switches to a user thread.
+type ThreadStat struct {
+	TID   int
+	Name  string
+	Model ThreadModel
+	State ProcState
+	CPU   time.Duration // simulated
+	Runs  int
+}
+
+// uthread is a user-level thread: the execution context the process holds
+// while it runs, saved here while another one does. Exported fields
+// because it is checkpointed as is.
+type uthread struct {
+	Name    string
+	Program []Op
+	PC      int
+	OpLeft  int
+	Acc     string
+	State   ProcState // ready, blocked joining, or exited
+	Joining string
+	CPU     time.Duration
+	Runs    int
+}
+
+// proc is the process p is a thread of, p itself for a main thread.
+func (p *Process) proc() *Process {
+	if p.leader != nil {
+		return p.leader
+	}
+	return p
+}
+
+// liveThreads counts p's process's threads that have not exited, the
+// main one included.
+func (p *Process) liveThreads() int {
+	lead := p.proc()
+	if lead.uts != nil {
+		n := 0
+		for _, t := range lead.uts {
+			if t.State != StateExited {
+				n++
+			}
+		}
+		if lead.state == StateExited {
+			return 0
+		}
+		return n
+	}
+	n := 0
+	for _, t := range append([]*Process{lead}, lead.threads...) {
+		if t.state != StateExited {
+			n++
+		}
+	}
+	return n
+}
+
+// threadCreate starts a kernel thread in p's process. It shares the
+// process's descriptors, mailbox and memory, and counts against its
+// cgroup's pids limit like a fork.
+func (s *Scheduler) threadCreate(p *Process, spec *ProcessSpec) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lead := p.proc()
+	t := NewProcess(s.nextPID+1, &ProcessSpec{Name: spec.Name, Priority: lead.Priority, Program: spec.Program})
+	err := s.setUserLocked(t, spec, lead.user)
+	var g *cgroup
+	if err == nil {
+		g, err = s.joinCgroupLocked(&ProcessSpec{Name: spec.Name}, lead.cgroup)
+	}
+	if err != nil {
+		s.klogLocked(LogNotice, "proc", lead.ID, "clone refused: %v", err)
+		return 0, err
+	}
+	s.nextPID++
+	t.leader, t.cgroup, t.parentID, t.spawnedAt = lead, g, lead.ID, s.now
+	t.fds = lead.fds
+	lead.threads = append(lead.threads, t)
+	s.placeLocked(t, false)
+	s.enqueueLocked(t)
+	s.procs[t.ID] = t
+	s.procEventLocked(t, Observer.OnSpawn)
+	s.klogLocked(LogDebug, "proc", t.ID, "thread %q started in PID %d", t.Name, lead.ID)
+	return t.ID, nil
+}
+
+// threadLocked finds the thread of p's process called name, preferring
+// one still alive.
+func (s *Scheduler) threadLocked(p *Process, name string) *Process {
+	lead := p.proc()
+	var found *Process
+	for _, t := range append([]*Process{lead}, lead.threads...) {
+		if t.Name == name && (found == nil || t.state != StateExited) {
+			found = t
+		}
+	}
+	return found
+}
+
+// threadJoin waits for a kernel thread of p's process to exit. Like a
+// receive it is entered again once woken.
+func (s *Scheduler) threadJoin(p *Process, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.threadLocked(p, name)
+	switch {
+	case t == nil:
+		return false, ErrNoProcess
+	case t == p:
+		return false, EINVAL
+	case t.state == StateExited:
+		return true, nil
+	}
+	s.block(p, "join:"+name)
+	return false, nil
+}
+
+// threadExitLocked wakes the threads joining p. When p is a main thread,
+// the rest of its process goes with it, like returning from main.
+func (s *Scheduler) threadExitLocked(p *Process) {
+	if p.leader == nil {
+		reason := p.exitReason
+		if reason == "" {
+			reason = "process exited"
+		}
+		for _, t := range p.threads {
+			if t.state != StateExited && t.exitReason == "" {
+				s.killLocked(t, reason)
+			}
+		}
+		return
+	}
+	for _, t := range append([]*Process{p.leader}, p.leader.threads...) {
+		if t.state == StateBlocked && t.waitingOn == "join:"+p.Name {
+			s.wake(t)
+		}
+	}
+}
+
+// userThreadOp runs a thread op of a UserThreads process in the process
+// itself, with no system call. It reports false if the process is done:
+// its main thread exited or every thread waits on another.
+func (s *Scheduler) userThreadOp(p *Process, op Op) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.uts == nil {
+		p.uts = []*uthread{{Name: p.Name, State: StateReady, Runs: 1}}
+	}
+	cur := p.uts[p.ucur]
+	switch op.Kind {
+	case OpThread:
+		p.pc++
+		p.uts = append(p.uts, &uthread{Name: op.Name, Program: op.Child.Program, State: StateReady})
+		units := programUnits(op.Child.Program)
+		p.WorkUnits += units
+		p.totalUnits += units
+		s.klogLocked(LogDebug, "proc", p.ID, "user thread %q started", op.Name)
+	case OpYield:
+		p.pc++
+		s.uswitchLocked(p)
+	case OpThreadJoin:
+		p.pc++
+		i := slices.IndexFunc(p.uts, func(t *uthread) bool { return t.Name == op.Name })
+		if i < 0 || i == p.ucur || p.uts[i].State == StateExited {
+			break
+		}
+		cur.State, cur.Joining = StateBlocked, op.Name
+		return s.uswitchLocked(p)
+	case OpThreadExit:
+		return s.uexitLocked(p)
+	}
+	return true
+}
+
+// uexitLocked ends the running user-level thread and switches to the
+// next; the main thread's end is the process's.
+func (s *Scheduler) uexitLocked(p *Process) bool {
+	if p.uts == nil || p.ucur == 0 {
+		for _, t := range p.uts {
+			t.State = StateExited
+		}
+		return false
+	}
+	cur := p.uts[p.ucur]
+	cur.State = StateExited
+	for _, t := range p.uts {
+		if t.State == StateBlocked && t.Joining == cur.Name {
+			t.State, t.Joining = StateReady, ""
+		}
+	}
+	return s.uswitchLocked(p)
+}
+
+// uswitchLocked saves the running thread's context and loads the next
+// ready one after it, round robin. It reports false if none is ready.
+func (s *Scheduler) uswitchLocked(p *Process) bool {
+	n := len(p.uts)
+	for i := 1; i <= n; i++ {
+		next := (p.ucur + i) % n
+		t := p.uts[next]
+		if t.State != StateReady {
+			continue
+		}
+		if next != p.ucur {
+			cur := p.uts[p.ucur]
+			cur.Program, cur.PC, cur.OpLeft, cur.Acc = p.Program, p.pc, p.opLeft, p.acc
+			p.Program, p.pc, p.opLeft, p.acc = t.Program, t.PC, t.OpLeft, t.Acc
+			p.ucur = next
+			t.Runs++
+		}
+		return true
+	}
+	p.exitReason = ErrThreadDeadlock.Error()
+	return false
+}
+
+// Threads lists the threads of pid's process, the main thread first.
+func (s *Scheduler) Threads(pid int) ([]ThreadStat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.procs[pid]
+	if !ok {
+		return nil, ErrNoProcess
+	}
+	lead := p.proc()
+	if lead.uts == nil {
+		out := []ThreadStat{}
+		for _, t := range append([]*Process{lead}, lead.threads...) {
+			out = append(out, ThreadStat{TID: t.ID, Name: t.Name, State: t.state, CPU: t.cpuTime, Runs: t.RunCount})
+		}
+		return out, nil
+	}
+	out := make([]ThreadStat, 0, len(lead.uts))
+	for i, t := range lead.uts {
+		st := t.State
+		if i == lead.ucur && st == StateReady || lead.state == StateExited {
+			st = lead.state // the library runs inside the process
+		}
+		out = append(out, ThreadStat{TID: i, Name: t.Name, Model: UserThreads, State: st, CPU: t.CPU, Runs: t.Runs})
+	}
+	return out, nil
+}
+
+// String is a thread as ps -L shows it.
+func (t ThreadStat) String() string {
+	return fmt.Sprintf("%d %s %s %s cpu=%v runs=%d", t.TID, t.Name, t.Model, t.State, t.CPU, t.Runs)
+}
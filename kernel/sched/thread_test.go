@@ -0,0 +1,116 @@
+package sched
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKernelThreadsShareTheProcess(t *testing.T) {
+	s := newTestScheduler()
+	s.Spawn(&ProcessSpec{Name: "srv", Memory: 512, Program: []Op{
+		PipeCreate(3, 4, 0), ShmAttach("buf", 16),
+		ThreadCreate("rx", Receive("job"), Alloc(256), ShmWrite("buf", 0, ""), FdWrite(4, "done")),
+		ThreadCreate("idle", Sleep(5)),
+		ThreadJoin("rx"), FdRead(3, 16), FileWrite("out", ""),
+		ShmRead("buf", 0, 3), FileWrite("shm", ""), ThreadJoin("idle"),
+		DiskRead(ProcDir + "/self/status"), FileWrite("status", ""),
+	}})
+	s.Spawn(&ProcessSpec{Name: "client", Program: []Op{Compute(2), Send(1, "job", "abc")}})
+
+	s.Step(4)
+	if st := s.Stats(); st[0].State != StateBlocked || st[0].WaitingOn != "join:rx" || st[2].TGID != 1 || st[2].Threads != 3 {
+		t.Errorf("main %v on %q, rx in %d with %d threads", st[0].State, st[0].WaitingOn, st[2].TGID, st[2].Threads)
+	}
+	if data, _ := s.ReadFile(ProcDir + "/3/status"); !strings.Contains(data, "Tgid:\t1\nPid:\t3\n") || !strings.Contains(data, "VmRSS:\t512 B\n") {
+		t.Errorf("/proc/3/status = %q", data)
+	}
+	runToEnd(s)
+
+	files := s.DumpFS()
+	if files["out"] != "done" || files["shm"] != "abc" {
+		t.Errorf("out=%q shm=%q, want the pipe and segment shared with the threads", files["out"], files["shm"])
+	}
+	if !strings.Contains(files["status"], "VmRSS:\t768 B\n") {
+		t.Errorf("status = %q, want the thread's Alloc charged to its process", files["status"])
+	}
+	for _, p := range s.Stats() {
+		if p.State != StateExited || p.ExitReason != "" {
+			t.Errorf("%s: %v %q", p.Name, p.State, p.ExitReason)
+		}
+	}
+	ts, err := s.Threads(3)
+	if err != nil || len(ts) != 3 || ts[0].TID != 1 || ts[1].Name != "rx" || ts[2].TID != 4 || ts[2].Runs == 0 {
+		t.Errorf("Threads(3) = %v, %v", ts, err)
+	}
+	trace, _ := s.Strace(1)
+	if trace[2].String() != `clone("rx") = 3` {
+		t.Errorf("strace = %v", trace)
+	}
+}
+
+func TestUserThreadsBlockTogether(t *testing.T) {
+	s := newTestScheduler()
+	Threading(s)
+	runToEnd(s)
+
+	exited := func(pid int) time.Duration {
+		log := s.Dmesg(LogFilter{PID: pid})
+		return log[len(log)-1].Time
+	}
+	if k, u := exited(1), exited(2); k >= u {
+		t.Errorf("kernel threads done at %v, user threads at %v: want the kernel's sleeps to overlap", k, u)
+	}
+	stats := s.Stats()
+	if len(stats) != 5 || stats[1].Threads != 0 || stats[1].Syscalls["clone"] != 0 || stats[1].Syscalls["nanosleep"] != 3 {
+		t.Errorf("uthreads: %+v, want three sleeps and no clone in a single task", stats[1])
+	}
+	ts, _ := s.Threads(2)
+	if len(ts) != 4 || ts[1].Model != UserThreads || ts[1].CPU != 5*time.Millisecond || ts[1].State != StateExited {
+		t.Errorf("Threads(2) = %v", ts)
+	}
+
+	s = newTestScheduler()
+	s.Spawn(&ProcessSpec{Name: "main", Threads: UserThreads, Program: []Op{
+		ThreadCreate("a", ThreadJoin("main")), ThreadJoin("a"),
+	}})
+	runToEnd(s)
+	if st := s.Stats()[0]; st.ExitReason != ErrThreadDeadlock.Error() {
+		t.Errorf("exit %q, want the threads' deadlock", st.ExitReason)
+	}
+}
+
+func TestMainThreadExitEndsProcess(t *testing.T) {
+	s := newTestScheduler()
+	_ = s.NewCgroup(CgroupSpec{Name: "small", PidsMax: 2})
+	s.Spawn(&ProcessSpec{Name: "main", Cgroup: "small", Program: []Op{
+		ThreadCreate("spin", Compute(50)), ThreadCreate("extra", Compute(1)),
+		ThreadJoin("main"), ThreadJoin("nobody"), Compute(1), ThreadExit(), Compute(9),
+	}})
+	s.Spawn(&ProcessSpec{Name: "victim", Program: []Op{ThreadCreate("w", Sleep(50)), ThreadJoin("w")}})
+	s.Step(3)
+	if !s.Kill(4) {
+		t.Fatal("could not kill thread 4")
+	}
+	runToEnd(s)
+
+	want := map[string]string{"main": "", "spin": "process exited", "victim": "killed", "w": "killed"}
+	for _, p := range s.Stats() {
+		if p.State != StateExited || p.ExitReason != want[p.Name] {
+			t.Errorf("%s: %v %q, want %q", p.Name, p.State, p.ExitReason, want[p.Name])
+		}
+	}
+	var errs []string
+	trace, _ := s.Strace(1)
+	for _, r := range trace {
+		if r.Errno != 0 {
+			errs = append(errs, r.Nr.String()+" "+r.Errno.Name())
+		}
+	}
+	if strings.Join(errs, ",") != "clone EAGAIN,thread_join EINVAL,thread_join ESRCH" {
+		t.Errorf("errors %q", errs)
+	}
+	if st := s.Stats()[0]; st.Remaining != 9 {
+		t.Errorf("main left %d units, want thread_exit to end it", st.Remaining)
+	}
+}
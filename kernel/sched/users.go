@@ -0,0 +1,152 @@
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"gosimos/fs"
+)
+
+var (
+	ErrNoUser     = errors.New("no such user")
+	ErrPermission = errors.New("operation not permitted")
+)
+
+// User is an account processes run as. The scheduler starts with root
+// (UID 0), which every permission check lets through; processes that do
+// not name a user run as their parent's, or as root.
+type User struct {
+	Name   string
+	UID    int
+	GID    int   // primary group
+	Groups []int `json:",omitempty"` // supplementary groups
+}
+
+func (u *User) cred() fs.Cred {
+	return fs.Cred{UID: u.UID, GID: u.GID, Groups: slices.Clone(u.Groups)}
+}
+
+var rootUser = User{Name: "root"}
+
+// AddUser creates an account. Names and UIDs must be unique.
+func (s *Scheduler) AddUser(u User) error {
+	s.exec.Lock()
+	defer s.exec.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case u.Name == "":
+		return errors.New("user needs a name")
+	case u.UID < 0 || u.GID < 0:
+		return fmt.Errorf("user %s: negative id", u.Name)
+	}
+	for _, v := range s.users {
+		if v.Name == u.Name || v.UID == u.UID {
+			return fmt.Errorf("user %s: %s already has UID %d", u.Name, v.Name, v.UID)
+		}
+	}
+	u.Groups = slices.Clone(u.Groups)
+	s.recordLocked(Event{Kind: EvUser, User: &u})
+	s.users = append(s.users, u)
+	s.klogLocked(LogInfo, "user", 0, "added %s (uid %d, gid %d)", u.Name, u.UID, u.GID)
+	return nil
+}
+
+// Users lists the accounts in creation order, root first.
+func (s *Scheduler) Users() []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		u.Groups = slices.Clone(u.Groups)
+		out = append(out, u)
+	}
+	return out
+}
+
+// UserName is the account with uid, or the bare number if there is none.
+func (s *Scheduler) UserName(uid int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userNameLocked(uid)
+}
+
+func (s *Scheduler) userLocked(name string) *User {
+	for i := range s.users {
+		if s.users[i].Name == name {
+			return &s.users[i]
+		}
+	}
+	return nil
+}
+
+// userNameLocked is the account with uid, or the bare number.
+func (s *Scheduler) userNameLocked(uid int) string {
+	for _, u := range s.users {
+		if u.UID == uid {
+			return u.Name
+		}
+	}
+	return fmt.Sprint(uid)
+}
+
+// setUserLocked makes p run as the user spec names, or as inherit when
+// it names none.
+func (s *Scheduler) setUserLocked(p *Process, spec *ProcessSpec, inherit string) error {
+	name := spec.User
+	if name == "" {
+		name = inherit
+	}
+	u := s.userLocked(name)
+	if u == nil {
+		return fmt.Errorf("%s: %w %q", spec.Name, ErrNoUser, name)
+	}
+	p.user, p.cred = u.Name, u.cred()
+	return nil
+}
+
+// maySignalLocked is the kill(2) rule, also applied to messages: root may
+// reach anyone, everyone else only processes running as the same user.
+// Senders that are not processes (the kernel, the operator) always may.
+func (s *Scheduler) maySignalLocked(from int, to *Process) bool {
+	p, ok := s.procs[from]
+	return !ok || p.cred.UID == 0 || p.cred.UID == to.cred.UID
+}
+
+// fsAccess checks p's permission on an existing file; a missing one is
+// left to the call itself.
+func (s *Scheduler) fsAccess(p *Process, name string, want fs.Mode) error {
+	if err := s.fs.Access(p.cred, name, want); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// killProc is one process terminating another.
+func (s *Scheduler) killProc(p *Process, pid int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.procs[pid]
+	if ok {
+		q = q.proc() // a signal is for the whole process
+	}
+	switch {
+	case !ok || q.state == StateExited || q.exitReason != "":
+		return ErrNoProcess
+	case !s.maySignalLocked(p.ID, q):
+		return ErrPermission
+	}
+	s.killLocked(q, fmt.Sprintf("killed by PID %d", p.ID))
+	return nil
+}
+
+func (s *Scheduler) chmod(p *Process, path string, mode fs.Mode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.fs.Chmod(p.cred, path, mode); err != nil {
+		return err
+	}
+	s.notifyLocked(func(o Observer) { o.OnFSOp(s.now, p.ID, "chmod", path) })
+	return nil
+}
@@ -0,0 +1,115 @@
+package sched
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gosimos/fs"
+)
+
+func addUsers(t *testing.T, s *Scheduler, users ...User) {
+	t.Helper()
+	for _, u := range users {
+		if err := s.AddUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFilePermissionsFollowModeBits(t *testing.T) {
+	s := newTestScheduler()
+	addUsers(t, s,
+		User{Name: "alice", UID: 1000, GID: 100},
+		User{Name: "bob", UID: 1001, GID: 100},
+		User{Name: "carol", UID: 1002, GID: 200})
+	s.Spawn(&ProcessSpec{Name: "alice", User: "alice", Program: []Op{
+		FileWrite("notes", "a"), Chmod("notes", 0o600),
+		FileWrite("shared", "a"), Chmod("shared", 0o660),
+	}})
+	runToEnd(s)
+	bob := s.Spawn(&ProcessSpec{Name: "bob", User: "bob", Program: []Op{
+		DiskRead("notes"), FileWrite("notes", "b"), FileWrite("shared", "b"), Chmod("shared", 0o666),
+	}})
+	carol := s.Spawn(&ProcessSpec{Name: "carol", User: "carol", Program: []Op{FileWrite("shared", "c")}})
+	root := s.Spawn(&ProcessSpec{Name: "root", Program: []Op{FileWrite("notes", "r"), Chmod("shared", 0o644)}})
+	runToEnd(s)
+
+	if got, want := straceLines(t, s, bob), []string{
+		`disk_read("notes") = -1 EACCES (permission denied)`,
+		`writefile("notes", "b") = -1 EACCES (permission denied)`,
+		`writefile("shared", "b") = 1`,
+		`chmod("shared", 0666) = -1 EPERM (operation not permitted)`,
+	}; !slices.Equal(got, want) {
+		t.Errorf("bob:\n%q\nwant\n%q", got, want)
+	}
+	if got := straceLines(t, s, carol); len(got) != 1 || !strings.HasSuffix(got[0], "EACCES (permission denied)") {
+		t.Errorf("carol = %q, want shared closed to other groups", got)
+	}
+	if got := straceLines(t, s, root); !slices.Equal(got, []string{`writefile("notes", "r") = 1`, `chmod("shared", 0644) = 0`}) {
+		t.Errorf("root = %q, want both calls let through", got)
+	}
+	files := s.DumpFS()
+	if files["notes"] != "r" || files["shared"] != "b" {
+		t.Errorf("files = %q", files)
+	}
+	if info, _ := s.fs.Stat("shared"); info.UID != 1000 || info.Mode != 0o644 {
+		t.Errorf("shared = %+v, want alice's, rw-r--r--", info)
+	}
+}
+
+func TestKillAndMessagesStayWithinAUser(t *testing.T) {
+	s := newTestScheduler()
+	addUsers(t, s, User{Name: "alice", UID: 1000}, User{Name: "bob", UID: 1001})
+	job := s.Spawn(&ProcessSpec{Name: "job", User: "alice", Program: []Op{Receive(""), Compute(50)}})
+	bob := s.Spawn(&ProcessSpec{Name: "bob", User: "bob", Program: []Op{
+		Send(job, "ping", "hi"), BroadcastMsg("ping", "all"), KillProc(job),
+	}})
+	runToEnd(s)
+	if got, want := straceLines(t, s, bob), []string{
+		`msgsnd(1, "ping", "hi") = -1 EPERM (operation not permitted)`,
+		`msgsnd("*", "ping", "all") = 0`,
+		`kill(1) = -1 EPERM (operation not permitted)`,
+	}; !slices.Equal(got, want) {
+		t.Errorf("bob:\n%q\nwant\n%q", got, want)
+	}
+	if st := s.Stats()[job-1]; st.State != StateBlocked {
+		t.Fatalf("job = %v, want still waiting for a message it may not get from bob", st.State)
+	}
+
+	admin := s.Spawn(&ProcessSpec{Name: "admin", Program: []Op{KillProc(job), KillProc(job)}})
+	runToEnd(s)
+	if got := straceLines(t, s, admin); !slices.Equal(got, []string{"kill(1) = 0", "kill(1) = -1 ESRCH (no such process)"}) {
+		t.Errorf("admin = %q", got)
+	}
+	if st := s.Stats()[job-1]; st.ExitReason != "killed by PID 3" || st.User != "alice" || st.UID != 1000 {
+		t.Errorf("job = %q as %s(%d)", st.ExitReason, st.User, st.UID)
+	}
+}
+
+func TestUsersAreUniqueAndInherited(t *testing.T) {
+	s := newTestScheduler()
+	addUsers(t, s, User{Name: "alice", UID: 1000, GID: 100, Groups: []int{200}})
+	for _, u := range []User{{Name: "alice", UID: 1001}, {Name: "eve", UID: 1000}, {Name: "", UID: 5}, {Name: "neg", UID: -1}} {
+		if err := s.AddUser(u); err == nil {
+			t.Errorf("AddUser(%+v) succeeded", u)
+		}
+	}
+	if _, err := s.TrySpawn(&ProcessSpec{Name: "x", User: "mallory"}); !errors.Is(err, ErrNoUser) {
+		t.Errorf("TrySpawn as a missing user = %v, want ErrNoUser", err)
+	}
+	parent := s.Spawn(&ProcessSpec{Name: "sh", User: "alice", Program: []Op{
+		SpawnChild(&ProcessSpec{Name: "child", Program: []Op{FileWrite("out", "x")}}),
+	}})
+	runToEnd(s)
+	if st := s.Stats()[parent]; st.User != "alice" {
+		t.Errorf("child runs as %q, want its parent's alice", st.User)
+	}
+	if info, _ := s.fs.Stat("out"); info != (fs.Info{UID: 1000, GID: 100, Mode: fs.DefaultMode}) {
+		t.Errorf("out = %+v", info)
+	}
+	if users := s.Users(); len(users) != 2 || users[0].Name != "root" || !slices.Equal(users[1].Groups, []int{200}) {
+		t.Errorf("Users = %+v", users)
+	}
+}